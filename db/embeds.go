@@ -15,12 +15,33 @@ const appID = 250256223
 
 // Migrations
 
+var (
+	//go:embed migrations/*
+	migrationsEFS   embed.FS
+	migrationsFS, _ = fs.Sub(migrationsEFS, "migrations")
+)
+
 var DomainEmbeds map[string]database.DomainEmbeds = map[string]database.DomainEmbeds{
 	"sessions": sessions.NewDomainEmbeds(),
+	"fluitans": {
+		MigrationsFS: migrationsFS,
+	},
 }
 
 var MigrationFiles []database.MigrationFile = []database.MigrationFile{
 	{Domain: "sessions", File: sessions.MigrationFiles[0]},
+	{Domain: "fluitans", File: "1-initialize-schema-v0.1.0"},
+	{Domain: "fluitans", File: "2-add-controller-health-history"},
+	{Domain: "fluitans", File: "3-add-controller-transport-settings"},
+	{Domain: "fluitans", File: "4-add-network-templates"},
+	{Domain: "fluitans", File: "5-add-network-tag-enums"},
+	{Domain: "fluitans", File: "6-add-network-history"},
+	{Domain: "fluitans", File: "7-add-device-metadata"},
+	{Domain: "fluitans", File: "8-add-join-policies"},
+	{Domain: "fluitans", File: "9-add-authorization-expirations"},
+	{Domain: "fluitans", File: "10-add-ip-reservations"},
+	{Domain: "fluitans", File: "11-add-join-authorized-members"},
+	{Domain: "fluitans", File: "12-add-rule-names"},
 }
 
 // Queries
@@ -0,0 +1 @@
+drop table ztcontrollers_controller;
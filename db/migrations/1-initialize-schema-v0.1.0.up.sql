@@ -0,0 +1,10 @@
+-- ZeroTier Controllers
+
+create table ztcontrollers_controller (
+  id                  integer primary key,
+  server              text    not null unique,
+  name                text    not null unique,
+  description         text    not null,
+  authtoken           text    not null,
+  network_cost_weight real    not null
+) strict;
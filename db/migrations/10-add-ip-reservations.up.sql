@@ -0,0 +1,12 @@
+-- ZeroTier Network IP Address Reservations
+
+-- IP addresses are reserved while they're being assigned to network members, so that concurrent
+-- assignments don't choose the same address
+create table ztdevices_reservation (
+  id              integer primary key,
+  network_id      text    not null,
+  ip_address      text    not null,
+  member_address  text    not null,
+  expiration_time integer not null,
+  unique (network_id, ip_address)
+) strict;
@@ -0,0 +1 @@
+drop table ztjoins_authorized_member;
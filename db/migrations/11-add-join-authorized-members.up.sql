@@ -0,0 +1,11 @@
+-- ZeroTier Network Authorized Members
+
+-- Devices which have been seen authorized in a network, so that join policies are only applied to
+-- devices which were never authorized, rather than to devices which were deauthorized later
+create table ztjoins_authorized_member (
+  id                       integer primary key,
+  network_id               text    not null,
+  member_address           text    not null,
+  first_authorization_time integer not null,
+  unique (network_id, member_address)
+) strict;
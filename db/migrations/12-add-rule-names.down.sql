@@ -0,0 +1,2 @@
+drop table zttags_capability;
+drop table zttags_tag;
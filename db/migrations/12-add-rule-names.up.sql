@@ -0,0 +1,19 @@
+-- ZeroTier Network Tag and Capability Names
+
+-- ZeroTier controllers don't store the names given to tags and capabilities in the rules
+-- language, so they're stored here
+create table zttags_tag (
+  id         integer primary key,
+  network_id text    not null,
+  tag_id     integer not null,
+  name       text    not null,
+  unique (network_id, tag_id)
+) strict;
+
+create table zttags_capability (
+  id            integer primary key,
+  network_id    text    not null,
+  capability_id integer not null,
+  name          text    not null,
+  unique (network_id, capability_id)
+) strict;
@@ -0,0 +1,2 @@
+drop index ztcontrollers_controller_event_idx_controller_id_observation_time;
+drop table ztcontrollers_controller_event;
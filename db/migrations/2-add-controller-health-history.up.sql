@@ -0,0 +1,17 @@
+-- ZeroTier Controller Health
+
+create table ztcontrollers_controller_event (
+  id               integer primary key,
+  controller_id    integer not null,
+  observation_time integer not null,
+  reachable        integer not null,
+  online           integer not null,
+  version          text    not null,
+  address          text    not null,
+  description      text    not null,
+  foreign key (controller_id) references ztcontrollers_controller(id)
+    on update cascade on delete cascade
+) strict;
+
+create index ztcontrollers_controller_event_idx_controller_id_observation_time
+on ztcontrollers_controller_event (controller_id, observation_time);
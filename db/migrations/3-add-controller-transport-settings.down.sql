@@ -0,0 +1,5 @@
+alter table ztcontrollers_controller drop column transport_proxy_url;
+alter table ztcontrollers_controller drop column transport_client_key;
+alter table ztcontrollers_controller drop column transport_client_cert;
+alter table ztcontrollers_controller drop column transport_ca_bundle;
+alter table ztcontrollers_controller drop column transport_timeout;
@@ -0,0 +1,16 @@
+-- ZeroTier Controller Transport Settings
+
+alter table ztcontrollers_controller
+add column transport_timeout integer not null default 30000;
+
+alter table ztcontrollers_controller
+add column transport_ca_bundle text not null default '';
+
+alter table ztcontrollers_controller
+add column transport_client_cert text not null default '';
+
+alter table ztcontrollers_controller
+add column transport_client_key text not null default '';
+
+alter table ztcontrollers_controller
+add column transport_proxy_url text not null default '';
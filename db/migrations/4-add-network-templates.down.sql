@@ -0,0 +1 @@
+drop table zttemplates_template;
@@ -0,0 +1,24 @@
+-- ZeroTier Network Templates
+
+create table zttemplates_template (
+  id             integer primary key,
+  name           text    not null unique,
+  description    text    not null,
+  network_config text    not null,
+  name_by_dns    integer not null
+) strict;
+
+-- This template reproduces the configuration which Fluitans previously gave to every new network
+insert into zttemplates_template (name, description, network_config, name_by_dns)
+values (
+  'default',
+  'A private network with 6PLANE addressing which only allows IPv4, ARP, and IPv6 traffic.',
+  '{"private":true,"v6AssignMode":{"6plane":true},"rules":[' ||
+    '{"type":"MATCH_ETHERTYPE","etherType":2048,"not":true},' ||
+    '{"type":"MATCH_ETHERTYPE","etherType":2054,"not":true},' ||
+    '{"type":"MATCH_ETHERTYPE","etherType":34525,"not":true},' ||
+    '{"type":"ACTION_DROP"},' ||
+    '{"type":"ACTION_ACCEPT"}' ||
+  ']}',
+  0
+);
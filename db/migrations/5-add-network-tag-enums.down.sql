@@ -0,0 +1 @@
+drop table zttags_enum;
@@ -0,0 +1,13 @@
+-- ZeroTier Network Tag Enums
+
+-- ZeroTier controllers only store the ids and default values of tags, so the names of tag values
+-- are stored here
+create table zttags_enum (
+  id         integer primary key,
+  network_id text    not null,
+  tag_id     integer not null,
+  value      integer not null,
+  name       text    not null,
+  unique (network_id, tag_id, value),
+  unique (network_id, tag_id, name)
+) strict;
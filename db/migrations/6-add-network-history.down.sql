@@ -0,0 +1 @@
+drop table zthistory_snapshot;
@@ -0,0 +1,11 @@
+-- ZeroTier Network Configuration History
+
+create table zthistory_snapshot (
+  id             integer primary key,
+  network_id     text    not null,
+  revision       integer not null,
+  snapshot_time  integer not null,
+  author         text    not null,
+  network_config text    not null,
+  unique (network_id, revision)
+) strict;
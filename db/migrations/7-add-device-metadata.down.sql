@@ -0,0 +1 @@
+drop table ztdevices_device;
@@ -0,0 +1,15 @@
+-- ZeroTier Network Device Metadata
+
+-- ZeroTier controllers don't store any human-friendly information about network members, so it's
+-- stored here
+create table ztdevices_device (
+  id             integer primary key,
+  network_id     text    not null,
+  member_address text    not null,
+  display_name   text    not null,
+  description    text    not null,
+  owner          text    not null,
+  asset_tag      text    not null,
+  notes          text    not null,
+  unique (network_id, member_address)
+) strict;
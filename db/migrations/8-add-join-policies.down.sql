@@ -0,0 +1,2 @@
+drop table ztjoins_decision;
+drop table ztjoins_policy;
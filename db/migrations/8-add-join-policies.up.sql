@@ -0,0 +1,27 @@
+-- ZeroTier Network Join Policies
+
+-- Policies for automatically authorizing devices which request to join a network
+create table ztjoins_policy (
+  id                 integer primary key,
+  network_id         text    not null,
+  require_approval   integer not null,
+  max_auto_approvals integer not null,
+  auto_approvals     integer not null,
+  enrollment_start   integer not null,
+  enrollment_end     integer not null,
+  -- JSON array of member addresses and identities which are automatically authorized
+  allowlist          text    not null,
+  unique (network_id)
+) strict;
+
+-- The latest decision on whether to automatically authorize each device which requested to join a
+-- network
+create table ztjoins_decision (
+  id             integer primary key,
+  network_id     text    not null,
+  member_address text    not null,
+  decision_time  integer not null,
+  accepted       integer not null,
+  reason         text    not null,
+  unique (network_id, member_address)
+) strict;
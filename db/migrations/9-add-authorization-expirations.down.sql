@@ -0,0 +1,2 @@
+drop index ztdevices_expiration_idx_expiration_time;
+drop table ztdevices_expiration;
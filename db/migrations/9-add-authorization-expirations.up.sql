@@ -0,0 +1,13 @@
+-- ZeroTier Network Device Authorization Expirations
+
+-- ZeroTier controllers only authorize network members permanently, so the times when temporary
+-- authorizations should be revoked are stored here
+create table ztdevices_expiration (
+  id              integer primary key,
+  network_id      text    not null,
+  member_address  text    not null,
+  expiration_time integer not null,
+  unique (network_id, member_address)
+) strict;
+create index ztdevices_expiration_idx_expiration_time
+on ztdevices_expiration (expiration_time);
@@ -17,6 +17,8 @@ require (
 	github.com/unrolled/secure v1.13.0
 	go4.org/netipx v0.0.0-20230125063823-8449b0a6169f
 	golang.org/x/sync v0.1.0
+	gopkg.in/yaml.v3 v3.0.1
+	zombiezen.com/go/sqlite v0.12.0
 )
 
 require (
@@ -55,5 +57,4 @@ require (
 	modernc.org/mathutil v1.5.0 // indirect
 	modernc.org/memory v1.4.0 // indirect
 	modernc.org/sqlite v1.20.0 // indirect
-	zombiezen.com/go/sqlite v0.12.0 // indirect
 )
@@ -41,6 +41,7 @@ func RequireHTTPAuthz(ss *session.Store) echo.MiddlewareFunc {
 			if err = a.RequireHTTPAuthz(); err != nil {
 				return err
 			}
+			setRequestIdentity(c, a.Identity)
 			return next(c)
 		}
 	}
@@ -2,6 +2,7 @@
 package auth
 
 import (
+	"context"
 	"encoding/gob"
 	"net/http"
 
@@ -54,6 +55,21 @@ func GetIdentity(s sessions.Session) (identity Identity, err error) {
 	return identity, nil
 }
 
+type identityContextKey struct{}
+
+// WithIdentity returns a copy of the context which carries the identity of the user making the
+// request, so that clients can attribute changes to the user.
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityContextKey{}, identity)
+}
+
+// IdentityFromContext returns the identity carried by the context, or a zero value for Identity if
+// the context doesn't carry an identity.
+func IdentityFromContext(ctx context.Context) Identity {
+	identity, _ := ctx.Value(identityContextKey{}).(Identity)
+	return identity
+}
+
 // CSRF
 
 type CSRFBehavior struct {
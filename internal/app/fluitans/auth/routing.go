@@ -26,6 +26,7 @@ func HandleHTTP(h HTTPHandlerFunc, ss *session.Store) echo.HandlerFunc {
 		if err != nil {
 			return err
 		}
+		setRequestIdentity(c, a.Identity)
 		return h(c, a)
 	}
 }
@@ -36,10 +37,15 @@ func HandleHTTPWithSession(h HTTPHandlerFuncWithSession, ss *session.Store) echo
 		if err != nil {
 			return err
 		}
+		setRequestIdentity(c, a.Identity)
 		return h(c, a, sess)
 	}
 }
 
+func setRequestIdentity(c echo.Context, identity Identity) {
+	c.SetRequest(c.Request().WithContext(WithIdentity(c.Request().Context(), identity)))
+}
+
 // HTTPRouter is a routing adapter between echo.HandlerFunc and this package's HTTPHandlerFunc, by
 // automatically extracting auth data from the session of the request.
 type HTTPRouter struct {
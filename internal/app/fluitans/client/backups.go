@@ -0,0 +1,255 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
+	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/pkg/desec"
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+// NetworksBackupVersion is the version of the format of exported network configurations.
+const NetworksBackupVersion = 1
+
+// Backup Documents
+
+// NetworksBackup is a document describing the configurations of networks, for export and import.
+type NetworksBackup struct {
+	Version    int             `json:"version"`
+	Exported   time.Time       `json:"exported"`
+	Controller string          `json:"controller,omitempty"`
+	DomainName string          `json:"domainName,omitempty"`
+	Networks   []NetworkBackup `json:"networks"`
+}
+
+type NetworkBackup struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// DNSName is the subname which named the network by DNS, if the network was named by DNS
+	DNSName string                     `json:"dnsName,omitempty"`
+	Config  zerotier.ControllerNetwork `json:"config"`
+	Members []MemberBackup             `json:"members,omitempty"`
+}
+
+type MemberBackup struct {
+	Address      string `json:"address"`
+	Authorized   bool   `json:"authorized"`
+	ActiveBridge bool   `json:"activeBridge,omitempty"`
+	// IPAssignments only includes explicitly-assigned IP addresses, not 6PLANE or RFC4193 addresses
+	IPAssignments []string `json:"ipAssignments,omitempty"`
+	// Names are the member's DNS names relative to the network's domain name, e.g. "foo" for a
+	// member with domain name "foo.d.network.example.com"
+	Names []string `json:"names,omitempty"`
+}
+
+// NewMemberRequest makes the request body to configure a network member as described by the backup.
+func (b MemberBackup) NewMemberRequest() zerotier.SetControllerNetworkMemberJSONRequestBody {
+	authorized := b.Authorized
+	activeBridge := b.ActiveBridge
+	ipAssignments := make([]string, len(b.IPAssignments))
+	copy(ipAssignments, b.IPAssignments)
+	return zerotier.SetControllerNetworkMemberJSONRequestBody{
+		Authorized:    &authorized,
+		ActiveBridge:  &activeBridge,
+		IpAssignments: &ipAssignments,
+	}
+}
+
+// NewNetworkRequest makes the request body to generate a network as described by the backup,
+// with the provided name.
+func (b NetworkBackup) NewNetworkRequest(
+	name string,
+) zerotier.GenerateControllerNetworkJSONRequestBody {
+	body := b.Config
+	body.Name = &name
+	return body
+}
+
+func newNetworkConfig(network zerotier.ControllerNetwork) zerotier.ControllerNetwork {
+	// Fields which are determined by the controller can't be carried over to another network
+	network.Id = nil
+	network.Nwid = nil
+	network.Name = nil
+	network.Objtype = nil
+	network.Revision = nil
+	network.CreationTime = nil
+	return network
+}
+
+func newMemberBackup(member Member, networkDomainName string) MemberBackup {
+	zerotierMember := member.ZerotierMember
+	b := MemberBackup{
+		Address:       *zerotierMember.Address,
+		Authorized:    zerotierMember.Authorized != nil && *zerotierMember.Authorized,
+		ActiveBridge:  zerotierMember.ActiveBridge != nil && *zerotierMember.ActiveBridge,
+		IPAssignments: make([]string, 0),
+		Names:         make([]string, 0, len(member.DomainNames)),
+	}
+	ndpAddresses := NewStringSet(member.NDPAddresses)
+	if zerotierMember.IpAssignments != nil {
+		for _, address := range *zerotierMember.IpAssignments {
+			if _, isNDP := ndpAddresses[address]; !isNDP {
+				b.IPAssignments = append(b.IPAssignments, address)
+			}
+		}
+	}
+	if networkDomainName != "" {
+		memberSuffix := ".d." + networkDomainName
+		for _, domainName := range member.DomainNames {
+			if strings.HasSuffix(domainName, memberSuffix) {
+				b.Names = append(b.Names, strings.TrimSuffix(domainName, memberSuffix))
+			}
+		}
+	}
+	return b
+}
+
+// NewNetworkBackup describes the network's configuration, its members, and its DNS names.
+func NewNetworkBackup(
+	ctx context.Context, zoneDomainName string, controller ztcontrollers.Controller,
+	network zerotier.ControllerNetwork, subnameRRsets map[string][]desec.RRset,
+	c *ztc.Client,
+) (NetworkBackup, error) {
+	b := NetworkBackup{
+		ID:     *network.Id,
+		Config: newNetworkConfig(network),
+	}
+	if network.Name != nil {
+		b.Name = *network.Name
+	}
+	var networkDomainName string
+	if NetworkNamedByDNS(b.ID, b.Name, zoneDomainName, subnameRRsets) {
+		networkDomainName = b.Name
+		b.DNSName = strings.TrimSuffix(b.Name, "."+zoneDomainName)
+	}
+
+	memberAddresses, err := c.GetNetworkMemberAddresses(ctx, controller, b.ID)
+	if err != nil {
+		return NetworkBackup{}, errors.Wrapf(err, "couldn't list members of network %s", b.ID)
+	}
+	members, err := GetMemberRecords(
+		ctx, zoneDomainName, controller, network, memberAddresses, subnameRRsets, c,
+	)
+	if err != nil {
+		return NetworkBackup{}, errors.Wrapf(err, "couldn't get members of network %s", b.ID)
+	}
+	sort.Strings(memberAddresses)
+	b.Members = make([]MemberBackup, 0, len(memberAddresses))
+	for _, address := range memberAddresses {
+		b.Members = append(b.Members, newMemberBackup(members[address], networkDomainName))
+	}
+	return b, nil
+}
+
+// Encoding
+
+const (
+	BackupFormatJSON = "json"
+	BackupFormatYAML = "yaml"
+)
+
+// EncodeNetworksBackup serializes the backup in the specified format, either JSON or YAML.
+func EncodeNetworksBackup(b NetworksBackup, format string) ([]byte, error) {
+	encoded, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't serialize backup as json")
+	}
+	switch format {
+	default:
+		return nil, errors.Errorf("unknown backup format %s", format)
+	case BackupFormatJSON:
+		return encoded, nil
+	case BackupFormatYAML:
+		// We go through JSON so that the YAML document uses the same field names as the JSON document
+		decoder := json.NewDecoder(bytes.NewReader(encoded))
+		decoder.UseNumber()
+		var document interface{}
+		if err := decoder.Decode(&document); err != nil {
+			return nil, errors.Wrap(err, "couldn't deserialize backup from json")
+		}
+		var buffer bytes.Buffer
+		encoder := yaml.NewEncoder(&buffer)
+		const indent = 2
+		encoder.SetIndent(indent)
+		if err := encoder.Encode(normalizeJSONNumbers(document)); err != nil {
+			return nil, errors.Wrap(err, "couldn't serialize backup as yaml")
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, errors.Wrap(err, "couldn't serialize backup as yaml")
+		}
+		return buffer.Bytes(), nil
+	}
+}
+
+// normalizeJSONNumbers replaces json.Number values with integers where possible (and floats
+// otherwise), so that large integers in rules aren't serialized in exponent notation.
+func normalizeJSONNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	default:
+		return v
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		for i := range v {
+			v[i] = normalizeJSONNumbers(v[i])
+		}
+		return v
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = normalizeJSONNumbers(v[key])
+		}
+		return v
+	}
+}
+
+var (
+	networkIDParser     = regexp.MustCompile(`^[0-9a-f]{16}$`)
+	memberAddressParser = regexp.MustCompile(`^[0-9a-f]{10}$`)
+)
+
+// DecodeNetworksBackup deserializes a backup from a JSON or YAML document.
+func DecodeNetworksBackup(document []byte) (b NetworksBackup, err error) {
+	// JSON is (almost entirely) a subset of YAML, so we can parse both formats as YAML
+	var parsed interface{}
+	if err = yaml.Unmarshal(document, &parsed); err != nil {
+		return NetworksBackup{}, errors.Wrap(err, "couldn't parse backup document")
+	}
+	encoded, err := json.Marshal(parsed)
+	if err != nil {
+		return NetworksBackup{}, errors.Wrap(err, "couldn't convert backup document to json")
+	}
+	if err = json.Unmarshal(encoded, &b); err != nil {
+		return NetworksBackup{}, errors.Wrap(err, "backup document has an invalid structure")
+	}
+	if b.Version != NetworksBackupVersion {
+		return NetworksBackup{}, errors.Errorf("unsupported backup document version %d", b.Version)
+	}
+	for _, network := range b.Networks {
+		if !networkIDParser.MatchString(network.ID) {
+			return NetworksBackup{}, errors.Errorf("backup document has invalid network id %s", network.ID)
+		}
+		for _, member := range network.Members {
+			if !memberAddressParser.MatchString(member.Address) {
+				return NetworksBackup{}, errors.Errorf(
+					"backup document has invalid address %s for a member of network %s",
+					member.Address, network.ID,
+				)
+			}
+		}
+	}
+	return b, nil
+}
@@ -2,6 +2,8 @@ package client
 
 import (
 	"context"
+	"sort"
+	"strings"
 
 	"github.com/pkg/errors"
 
@@ -77,3 +79,111 @@ func GetRecordsOfType(
 	}
 	return records, nil
 }
+
+// Device Records
+
+// GetDeviceRecordKeys finds the keys of the A and AAAA rrsets for the domain names which the
+// network manages for the members; records are only found if the network is named by DNS with the
+// provided subname.
+func GetDeviceRecordKeys(
+	members map[string]Member, subname, zoneDomainName string,
+	subnameRRsets map[string][]desec.RRset,
+) []desecc.RRsetKey {
+	keys := make([]desecc.RRsetKey, 0)
+	if subname == "" {
+		return keys
+	}
+	deviceSubnames := make(map[string]struct{})
+	for _, member := range members {
+		for _, domainName := range member.DomainNames {
+			deviceSubname := strings.TrimSuffix(domainName, "."+zoneDomainName)
+			if strings.HasSuffix(deviceSubname, ".d."+subname) {
+				deviceSubnames[deviceSubname] = struct{}{}
+			}
+		}
+	}
+	for deviceSubname := range deviceSubnames {
+		for _, rrset := range subnameRRsets[deviceSubname] {
+			if rrset.Type == "A" || rrset.Type == "AAAA" {
+				keys = append(keys, desecc.NewRRsetKey(rrset))
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Subname != keys[j].Subname {
+			return keys[i].Subname < keys[j].Subname
+		}
+		return keys[i].Type < keys[j].Type
+	})
+	return keys
+}
+
+// GetMemberRecordKeys returns the keys of the DNS records of each member's domain names, or no keys
+// if the network isn't named by DNS.
+func GetMemberRecordKeys(
+	ctx context.Context, controller ztcontrollers.Controller, network zerotier.ControllerNetwork,
+	memberAddresses []string, subnameRRsets map[string][]desec.RRset,
+	c *ztc.Client, dc *desecc.Client,
+) (map[string][]desecc.RRsetKey, error) {
+	memberKeys := make(map[string][]desecc.RRsetKey)
+	if network.Id == nil || network.Name == nil || !NetworkNamedByDNS(
+		*network.Id, *network.Name, dc.Config.DomainName, subnameRRsets,
+	) {
+		return memberKeys, nil
+	}
+	subname := strings.TrimSuffix(*network.Name, "."+dc.Config.DomainName)
+	members, err := GetMemberRecords(
+		ctx, dc.Config.DomainName, controller, network, memberAddresses, subnameRRsets, c,
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't get network %s member records", *network.Id)
+	}
+	for memberAddress, member := range members {
+		keys := GetDeviceRecordKeys(
+			map[string]Member{memberAddress: member}, subname, dc.Config.DomainName, subnameRRsets,
+		)
+		if len(keys) > 0 {
+			memberKeys[memberAddress] = keys
+		}
+	}
+	return memberKeys, nil
+}
+
+// DeleteRecordKeys deletes the DNS records of the members in a single batch.
+func DeleteRecordKeys(
+	ctx context.Context, networkID string, memberKeys map[string][]desecc.RRsetKey,
+	dc *desecc.Client,
+) error {
+	keys := make([]desecc.RRsetKey, 0, len(memberKeys))
+	for _, memberKeys := range memberKeys {
+		keys = append(keys, memberKeys...)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Subname != keys[j].Subname {
+			return keys[i].Subname < keys[j].Subname
+		}
+		return keys[i].Type < keys[j].Type
+	})
+	if err := dc.DeleteRRsets(ctx, keys...); err != nil {
+		return errors.Wrapf(err, "couldn't delete DNS records of network %s members", networkID)
+	}
+	return nil
+}
+
+// DeleteMemberRecords deletes the DNS records of the members' domain names in a single batch.
+func DeleteMemberRecords(
+	ctx context.Context, controller ztcontrollers.Controller, network zerotier.ControllerNetwork,
+	memberAddresses []string, subnameRRsets map[string][]desec.RRset,
+	c *ztc.Client, dc *desecc.Client,
+) error {
+	memberKeys, err := GetMemberRecordKeys(
+		ctx, controller, network, memberAddresses, subnameRRsets, c, dc,
+	)
+	if err != nil {
+		return err
+	}
+	return DeleteRecordKeys(ctx, *network.Id, memberKeys, dc)
+}
@@ -16,12 +16,20 @@ import (
 	"github.com/sargassum-world/fluitans/internal/clients/desec"
 	"github.com/sargassum-world/fluitans/internal/clients/zerotier"
 	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/internal/clients/ztdevices"
+	"github.com/sargassum-world/fluitans/internal/clients/zthistory"
+	"github.com/sargassum-world/fluitans/internal/clients/ztjoins"
+	"github.com/sargassum-world/fluitans/internal/clients/zttags"
+	"github.com/sargassum-world/fluitans/internal/clients/zttemplates"
+	"github.com/sargassum-world/fluitans/pkg/secrets"
 )
 
 type Globals struct {
 	Config conf.Config
 	Cache  clientcache.Cache
 	DB     *database.DB
+	// Secrets seals secrets (such as authtokens) which are stored in the DB or cache
+	Secrets *secrets.Keyring
 
 	Sessions        *session.Store
 	SessionsBacking *sqlitestore.SqliteStore
@@ -35,6 +43,11 @@ type Globals struct {
 	Desec         *desec.Client
 	Zerotier      *zerotier.Client
 	ZTControllers *ztcontrollers.Client
+	ZTTemplates   *zttemplates.Client
+	ZTTags        *zttags.Client
+	ZTHistory     *zthistory.Client
+	ZTDevices     *ztdevices.Client
+	ZTJoins       *ztjoins.Client
 
 	Logger godest.Logger
 }
@@ -76,6 +89,18 @@ func NewGlobals(persistenceEmbeds database.Embeds, l godest.Logger) (g *Globals,
 	g.ACSigner = actioncable.NewSigner(acsConfig)
 	g.TSBroker = turbostreams.NewBroker(l)
 
+	// The secrets keyring is set up after the other keys, so that a missing secrets key doesn't
+	// prevent the other generated keys from being printed
+	secretsConfig, err := secrets.GetConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't set up secrets config")
+	}
+	if g.Secrets, err = secrets.NewKeyring(
+		secretsConfig.Key, secretsConfig.PreviousKeys...,
+	); err != nil {
+		return nil, errors.Wrap(err, "couldn't set up secrets keyring")
+	}
+
 	desecConfig, err := desec.GetConfig(g.Config.DomainName)
 	if err != nil {
 		return nil, errors.Wrap(err, "couldn't set up desec config")
@@ -90,7 +115,13 @@ func NewGlobals(persistenceEmbeds database.Embeds, l godest.Logger) (g *Globals,
 	if err != nil {
 		return nil, errors.Wrap(err, "couldn't set up zerotier controllers config")
 	}
-	g.ZTControllers = ztcontrollers.NewClient(ztcConfig, g.Cache, l)
+	g.ZTControllers = ztcontrollers.NewClient(ztcConfig, g.Cache, g.DB, g.Secrets, l)
+	g.ZTTemplates = zttemplates.NewClient(g.DB, l)
+	g.ZTTags = zttags.NewClient(g.DB, l)
+	g.ZTHistory = zthistory.NewClient(g.DB, l)
+	g.ZTDevices = ztdevices.NewClient(g.DB, l)
+	g.ZTJoins = ztjoins.NewClient(g.DB, l)
+	g.Zerotier.Recorder = NetworkHistoryRecorder{History: g.ZTHistory}
 
 	g.Logger = l
 	return g, nil
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/auth"
+	"github.com/sargassum-world/fluitans/internal/clients/zthistory"
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+// NetworkHistoryRecorder records the configurations of networks in their history, attributing
+// changes to the user whose identity is carried by the context of the change.
+type NetworkHistoryRecorder struct {
+	History *zthistory.Client
+}
+
+func (r NetworkHistoryRecorder) RecordNetwork(
+	ctx context.Context, network zerotier.ControllerNetwork, changed bool,
+) error {
+	author := ""
+	if changed {
+		author = auth.IdentityFromContext(ctx).User
+	}
+	return r.History.RecordNetwork(ctx, network, author)
+}
+
+// NewRollbackRequest makes the request body to restore the network's configuration from the
+// snapshot. The network's name isn't restored, since it must stay consistent with the DNS records
+// which refer to the network.
+func NewRollbackRequest(snapshot zthistory.Snapshot) zerotier.SetControllerNetworkJSONRequestBody {
+	body := newNetworkConfig(snapshot.Network)
+	// Settings which were absent from the snapshot must be cleared, rather than left unchanged
+	if body.Capabilities == nil {
+		body.Capabilities = &[]map[string]interface{}{}
+	}
+	if body.Tags == nil {
+		body.Tags = &[]map[string]interface{}{}
+	}
+	if body.Routes == nil {
+		body.Routes = &[]zerotier.Route{}
+	}
+	if body.IpAssignmentPools == nil {
+		body.IpAssignmentPools = &[]zerotier.IpAssignmentPool{}
+	}
+	if body.Dns == nil {
+		domain := ""
+		body.Dns = &zerotier.DNS{Domain: &domain, Servers: &[]string{}}
+	}
+	return body
+}
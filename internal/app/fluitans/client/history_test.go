@@ -0,0 +1,66 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sargassum-world/fluitans/internal/clients/zthistory"
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+func TestNewRollbackRequest(t *testing.T) {
+	id := "8056c2e21c000001"
+	name := "example.com"
+	revision := 5
+	private := true
+	domain := "example.com"
+	servers := []string{"10.0.0.1"}
+	routes := []zerotier.Route{}
+	snapshot := zthistory.Snapshot{
+		NetworkID: id,
+		Revision:  int64(revision),
+		Network: zerotier.ControllerNetwork{
+			Id:       &id,
+			Nwid:     &id,
+			Name:     &name,
+			Revision: &revision,
+			Private:  &private,
+			Dns:      &zerotier.DNS{Domain: &domain, Servers: &servers},
+			Routes:   &routes,
+		},
+	}
+
+	body := NewRollbackRequest(snapshot)
+	if body.Id != nil || body.Nwid != nil || body.Name != nil || body.Revision != nil {
+		t.Errorf("rollback request %+v restores fields determined by the controller", body)
+	}
+	if body.Private == nil || !*body.Private {
+		t.Errorf("rollback request doesn't restore the access control setting")
+	}
+	if body.Dns == nil || !reflect.DeepEqual(*body.Dns.Servers, servers) {
+		t.Errorf("rollback request doesn't restore the dns settings")
+	}
+	// Settings which were absent from the snapshot must be cleared
+	if body.Capabilities == nil || len(*body.Capabilities) != 0 {
+		t.Errorf("rollback request doesn't clear capabilities: %v", body.Capabilities)
+	}
+	if body.Tags == nil || len(*body.Tags) != 0 {
+		t.Errorf("rollback request doesn't clear tags: %v", body.Tags)
+	}
+	if body.IpAssignmentPools == nil || len(*body.IpAssignmentPools) != 0 {
+		t.Errorf("rollback request doesn't clear ip assignment pools: %v", body.IpAssignmentPools)
+	}
+
+	// The snapshot itself must not be modified
+	if snapshot.Network.Id == nil || snapshot.Network.Capabilities != nil {
+		t.Errorf("making a rollback request modified the snapshot: %+v", snapshot.Network)
+	}
+}
+
+func TestNewRollbackRequestClearsDNS(t *testing.T) {
+	body := NewRollbackRequest(zthistory.Snapshot{})
+	if body.Dns == nil || body.Dns.Domain == nil || *body.Dns.Domain != "" ||
+		body.Dns.Servers == nil || len(*body.Dns.Servers) != 0 {
+		t.Errorf("rollback request doesn't clear dns settings: %+v", body.Dns)
+	}
+}
@@ -0,0 +1,331 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+
+	"github.com/pkg/errors"
+	"go4.org/netipx"
+	"golang.org/x/sync/errgroup"
+
+	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
+	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+// IP Address Allocations
+
+type IPAllocationKind string
+
+const (
+	IPAllocationRoute IPAllocationKind = "route"
+	IPAllocationPool  IPAllocationKind = "pool"
+)
+
+// IPAllocation is a range of IP addresses used by a network, either as a managed route or as an IP
+// assignment pool.
+type IPAllocation struct {
+	Controller string
+	Network    zerotier.ControllerNetwork
+	Kind       IPAllocationKind
+	Range      netipx.IPRange
+}
+
+func (a IPAllocation) NetworkID() string {
+	if a.Network.Id == nil {
+		return ""
+	}
+	return *a.Network.Id
+}
+
+// Description formats the range as a prefix if the range is exactly a prefix.
+func (a IPAllocation) Description() string {
+	if prefix, ok := a.Range.Prefix(); ok {
+		return prefix.String()
+	}
+	return fmt.Sprintf("%s-%s", a.Range.From(), a.Range.To())
+}
+
+// NewIPAllocations lists the IP address ranges used by the network. Routes via gateways are
+// excluded, since they refer to addresses outside the network, and so are assignment pools within
+// the network's managed routes, since the routes already cover them.
+func NewIPAllocations(
+	controllerName string, network zerotier.ControllerNetwork,
+) ([]IPAllocation, error) {
+	allocations := make([]IPAllocation, 0)
+	if network.Routes != nil {
+		for _, route := range *network.Routes {
+			if route.Target == nil {
+				return nil, errors.New("couldn't find target in zerotier managed route")
+			}
+			if route.Via != nil && *route.Via != "" {
+				continue
+			}
+			prefix, err := netip.ParsePrefix(*route.Target)
+			if err != nil {
+				return nil, errors.Wrapf(err, "couldn't parse zerotier managed route %s", *route.Target)
+			}
+			allocations = append(allocations, IPAllocation{
+				Controller: controllerName,
+				Network:    network,
+				Kind:       IPAllocationRoute,
+				Range:      netipx.RangeOfPrefix(prefix.Masked()),
+			})
+		}
+	}
+	routes := len(allocations)
+	if network.IpAssignmentPools != nil {
+		for _, pool := range *network.IpAssignmentPools {
+			if pool.IpRangeStart == nil || pool.IpRangeEnd == nil {
+				return nil, errors.New("couldn't find bounds of zerotier ip assignment pool")
+			}
+			poolRange, err := netipx.ParseIPRange(*pool.IpRangeStart + "-" + *pool.IpRangeEnd)
+			if err != nil {
+				return nil, errors.Wrapf(
+					err, "couldn't parse zerotier ip assignment pool %s-%s",
+					*pool.IpRangeStart, *pool.IpRangeEnd,
+				)
+			}
+			covered := false
+			for _, route := range allocations[:routes] {
+				if route.Range.From().Compare(poolRange.From()) <= 0 &&
+					poolRange.To().Compare(route.Range.To()) <= 0 {
+					covered = true
+					break
+				}
+			}
+			if covered {
+				continue
+			}
+			allocations = append(allocations, IPAllocation{
+				Controller: controllerName,
+				Network:    network,
+				Kind:       IPAllocationPool,
+				Range:      poolRange,
+			})
+		}
+	}
+	return allocations, nil
+}
+
+// ListIPAllocations lists the IP address ranges used by the networks of the controllers, sorted by
+// their starting addresses.
+func ListIPAllocations(
+	controllers []ztcontrollers.Controller, networkIDs [][]string,
+	networks []map[string]zerotier.ControllerNetwork,
+) ([]IPAllocation, error) {
+	if len(controllers) != len(networkIDs) || len(controllers) != len(networks) {
+		return nil, errors.Errorf("lists of controllers, ids, and networks must have the same length")
+	}
+
+	allocations := make([]IPAllocation, 0)
+	for i, controller := range controllers {
+		for _, id := range networkIDs[i] {
+			network, ok := networks[i][id]
+			if !ok {
+				continue
+			}
+			networkAllocations, err := NewIPAllocations(controller.Name, network)
+			if err != nil {
+				return nil, errors.Wrapf(err, "couldn't list ip addresses used by network %s", id)
+			}
+			allocations = append(allocations, networkAllocations...)
+		}
+	}
+	SortIPAllocations(allocations)
+	return allocations, nil
+}
+
+// GetIPAllocations lists the IP address ranges used by all networks of all the controllers.
+func GetIPAllocations(
+	ctx context.Context, controllers []ztcontrollers.Controller,
+	c *ztc.Client, cc *ztcontrollers.Client,
+) ([]IPAllocation, error) {
+	networkIDs, err := c.GetAllNetworkIDs(ctx, controllers, cc)
+	if err != nil {
+		return nil, err
+	}
+	networks, err := c.GetAllNetworks(ctx, controllers, networkIDs)
+	if err != nil {
+		return nil, err
+	}
+	return ListIPAllocations(controllers, networkIDs, networks)
+}
+
+// SortIPAllocations sorts the allocations by the starting addresses of their ranges.
+func SortIPAllocations(allocations []IPAllocation) {
+	sort.SliceStable(allocations, func(i, j int) bool {
+		return allocations[i].Range.From().Less(allocations[j].Range.From())
+	})
+}
+
+// Overlapping Allocations
+
+// IPOverlap is a pair of IP address ranges, used by different networks, which overlap.
+type IPOverlap struct {
+	First  IPAllocation
+	Second IPAllocation
+}
+
+// FindIPOverlaps finds all overlaps between ranges used by different networks. The allocations
+// must be sorted.
+func FindIPOverlaps(allocations []IPAllocation) []IPOverlap {
+	overlaps := make([]IPOverlap, 0)
+	for i, first := range allocations {
+		for _, second := range allocations[i+1:] {
+			if first.Range.To().Less(second.Range.From()) {
+				// Since the allocations are sorted, no later allocations overlap with the first one
+				break
+			}
+			if first.NetworkID() == second.NetworkID() {
+				continue
+			}
+			overlaps = append(overlaps, IPOverlap{First: first, Second: second})
+		}
+	}
+	return overlaps
+}
+
+// NextFreePrefix finds the first prefix with the specified length in the supernet which doesn't
+// overlap with any of the allocations.
+func NextFreePrefix(
+	supernet netip.Prefix, bits int, allocations []IPAllocation,
+) (netip.Prefix, error) {
+	supernet = supernet.Masked()
+	if bits < supernet.Bits() || bits > supernet.Addr().BitLen() {
+		return netip.Prefix{}, errors.Errorf(
+			"prefix length %d must be between %d and %d for supernet %s",
+			bits, supernet.Bits(), supernet.Addr().BitLen(), supernet,
+		)
+	}
+
+	candidate := netip.PrefixFrom(supernet.Addr(), bits)
+	for supernet.Contains(candidate.Addr()) {
+		candidateRange := netipx.RangeOfPrefix(candidate)
+		var blocker *netipx.IPRange
+		for _, allocation := range allocations {
+			if allocation.Range.Overlaps(candidateRange) {
+				blocker = &allocation.Range
+				break
+			}
+		}
+		if blocker == nil {
+			return candidate, nil
+		}
+
+		// Skip to the first prefix which starts after the range blocking the candidate
+		next := blocker.To().Next()
+		if !next.IsValid() {
+			break
+		}
+		candidate = netip.PrefixFrom(next, bits).Masked()
+		if candidate.Addr() != next {
+			if next = netipx.PrefixLastIP(candidate).Next(); !next.IsValid() {
+				break
+			}
+			candidate = netip.PrefixFrom(next, bits)
+		}
+	}
+	return netip.Prefix{}, errors.Errorf("no free /%d prefixes remain in %s", bits, supernet)
+}
+
+// Duplicate IP Address Assignments
+
+// IPAssignee is a network member which was assigned an IP address.
+type IPAssignee struct {
+	Controller    string
+	NetworkID     string
+	MemberAddress string
+}
+
+// DuplicateIPAssignment is an IP address which was assigned to multiple network members.
+type DuplicateIPAssignment struct {
+	Address   netip.Addr
+	Assignees []IPAssignee
+}
+
+func getIPAssignees(
+	ctx context.Context, controller ztcontrollers.Controller, networkID string, c *ztc.Client,
+) (map[netip.Addr][]IPAssignee, error) {
+	memberAddresses, err := c.GetNetworkMemberAddresses(ctx, controller, networkID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := c.GetNetworkMembers(ctx, controller, networkID, memberAddresses)
+	if err != nil {
+		return nil, err
+	}
+
+	assignees := make(map[netip.Addr][]IPAssignee)
+	for memberAddress, member := range members {
+		if member.IpAssignments == nil {
+			continue
+		}
+		for _, rawAddress := range *member.IpAssignments {
+			address, err := netip.ParseAddr(rawAddress)
+			if err != nil {
+				return nil, errors.Wrapf(
+					err, "couldn't parse ip address %s of member %s", rawAddress, memberAddress,
+				)
+			}
+			assignees[address] = append(assignees[address], IPAssignee{
+				Controller:    controller.Name,
+				NetworkID:     networkID,
+				MemberAddress: memberAddress,
+			})
+		}
+	}
+	return assignees, nil
+}
+
+// FindDuplicateIPAssignments finds the IP addresses which were assigned to multiple members of
+// the networks, whether in the same network or in different networks.
+func FindDuplicateIPAssignments(
+	ctx context.Context, controllers []ztcontrollers.Controller, networkIDs [][]string,
+	c *ztc.Client,
+) ([]DuplicateIPAssignment, error) {
+	if len(controllers) != len(networkIDs) {
+		return nil, errors.Errorf("lists of controllers and ids must have the same length")
+	}
+
+	eg, egctx := errgroup.WithContext(ctx)
+	allAssignees := make([][]map[netip.Addr][]IPAssignee, len(controllers))
+	for i, controller := range controllers {
+		allAssignees[i] = make([]map[netip.Addr][]IPAssignee, len(networkIDs[i]))
+		for j, networkID := range networkIDs[i] {
+			eg.Go(func(i, j int, controller ztcontrollers.Controller, networkID string) func() error {
+				return func() (err error) {
+					allAssignees[i][j], err = getIPAssignees(egctx, controller, networkID, c)
+					return err
+				}
+			}(i, j, controller, networkID))
+		}
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	assignees := make(map[netip.Addr][]IPAssignee)
+	for _, controllerAssignees := range allAssignees {
+		for _, networkAssignees := range controllerAssignees {
+			for address, addressAssignees := range networkAssignees {
+				assignees[address] = append(assignees[address], addressAssignees...)
+			}
+		}
+	}
+	duplicates := make([]DuplicateIPAssignment, 0)
+	for address, addressAssignees := range assignees {
+		if len(addressAssignees) > 1 {
+			duplicates = append(duplicates, DuplicateIPAssignment{
+				Address:   address,
+				Assignees: addressAssignees,
+			})
+		}
+	}
+	sort.Slice(duplicates, func(i, j int) bool {
+		return duplicates[i].Address.Less(duplicates[j].Address)
+	})
+	return duplicates, nil
+}
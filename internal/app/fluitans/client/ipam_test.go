@@ -0,0 +1,365 @@
+package client
+
+import (
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go4.org/netipx"
+
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+// newTestAllocation makes an allocation from either a prefix or a range of addresses.
+func newTestAllocation(networkID string, kind IPAllocationKind, rawRange string) IPAllocation {
+	id := networkID
+	allocation := IPAllocation{
+		Network: zerotier.ControllerNetwork{Id: &id},
+		Kind:    kind,
+	}
+	if strings.Contains(rawRange, "/") {
+		allocation.Range = netipx.RangeOfPrefix(netip.MustParsePrefix(rawRange))
+	} else {
+		allocation.Range = netipx.MustParseIPRange(rawRange)
+	}
+	return allocation
+}
+
+func describeIPAllocations(allocations []IPAllocation) []string {
+	descriptions := make([]string, len(allocations))
+	for i, allocation := range allocations {
+		descriptions[i] = string(allocation.Kind) + " " + allocation.Description()
+	}
+	return descriptions
+}
+
+func TestNewIPAllocations(t *testing.T) {
+	const networkID = "8056c2e21c000001"
+	newRoute := func(target, via string) zerotier.Route {
+		route := zerotier.Route{Target: &target}
+		if via != "" {
+			route.Via = &via
+		}
+		return route
+	}
+	newPool := func(start, end string) zerotier.IpAssignmentPool {
+		return zerotier.IpAssignmentPool{IpRangeStart: &start, IpRangeEnd: &end}
+	}
+	testCases := []struct {
+		name   string
+		routes []zerotier.Route
+		pools  []zerotier.IpAssignmentPool
+		want   []string
+	}{
+		{
+			"no routes or pools",
+			nil, nil,
+			[]string{},
+		},
+		{
+			"route via gateway",
+			[]zerotier.Route{newRoute("10.0.0.0/24", ""), newRoute("192.168.0.0/16", "10.0.0.1")},
+			nil,
+			[]string{"route 10.0.0.0/24"},
+		},
+		{
+			"unmasked route",
+			[]zerotier.Route{newRoute("10.0.0.5/24", "")},
+			nil,
+			[]string{"route 10.0.0.0/24"},
+		},
+		{
+			"pool covered by route",
+			[]zerotier.Route{newRoute("10.0.0.0/24", "")},
+			[]zerotier.IpAssignmentPool{newPool("10.0.0.1", "10.0.0.254")},
+			[]string{"route 10.0.0.0/24"},
+		},
+		{
+			"pool exactly covered by route",
+			[]zerotier.Route{newRoute("10.0.0.0/24", "")},
+			[]zerotier.IpAssignmentPool{newPool("10.0.0.0", "10.0.0.255")},
+			[]string{"route 10.0.0.0/24"},
+		},
+		{
+			"pool extending past route",
+			[]zerotier.Route{newRoute("10.0.0.0/24", "")},
+			[]zerotier.IpAssignmentPool{newPool("10.0.0.128", "10.0.1.127")},
+			[]string{"route 10.0.0.0/24", "pool 10.0.0.128-10.0.1.127"},
+		},
+		{
+			"pool covered by second route",
+			[]zerotier.Route{newRoute("10.0.0.0/24", ""), newRoute("10.0.1.0/24", "")},
+			[]zerotier.IpAssignmentPool{newPool("10.0.1.1", "10.0.1.254")},
+			[]string{"route 10.0.0.0/24", "route 10.0.1.0/24"},
+		},
+		{
+			"pool covered only by route via gateway",
+			[]zerotier.Route{newRoute("10.0.0.0/24", "10.0.1.1")},
+			[]zerotier.IpAssignmentPool{newPool("10.0.0.1", "10.0.0.254")},
+			[]string{"pool 10.0.0.1-10.0.0.254"},
+		},
+		{
+			"mixed ipv4 and ipv6",
+			[]zerotier.Route{newRoute("10.0.0.0/24", ""), newRoute("fd00::/64", "")},
+			[]zerotier.IpAssignmentPool{
+				newPool("10.0.0.1", "10.0.0.254"),
+				newPool("fd00::1", "fd00::ffff"),
+				newPool("fd01::", "fd01::ff"),
+			},
+			[]string{"route 10.0.0.0/24", "route fd00::/64", "pool fd01::/120"},
+		},
+	}
+	for _, testCase := range testCases {
+		network := zerotier.ControllerNetwork{}
+		if testCase.routes != nil {
+			network.Routes = &testCase.routes
+		}
+		if testCase.pools != nil {
+			network.IpAssignmentPools = &testCase.pools
+		}
+		allocations, err := NewIPAllocations("controller", network)
+		if err != nil {
+			t.Errorf("%s: couldn't list allocations: %s", testCase.name, err)
+			continue
+		}
+		if got := describeIPAllocations(allocations); !reflect.DeepEqual(got, testCase.want) {
+			t.Errorf("%s: listed allocations %v, want %v", testCase.name, got, testCase.want)
+		}
+		for _, allocation := range allocations {
+			if allocation.Controller != "controller" {
+				t.Errorf("%s: allocation %+v isn't of the controller", testCase.name, allocation)
+			}
+		}
+	}
+
+	id := networkID
+	target := "10.0.0.0"
+	start := "10.0.0.1"
+	invalidCases := []struct {
+		name    string
+		network zerotier.ControllerNetwork
+	}{
+		{
+			"route without target",
+			zerotier.ControllerNetwork{Id: &id, Routes: &[]zerotier.Route{{}}},
+		},
+		{
+			"route without prefix length",
+			zerotier.ControllerNetwork{Id: &id, Routes: &[]zerotier.Route{{Target: &target}}},
+		},
+		{
+			"pool without end",
+			zerotier.ControllerNetwork{
+				Id: &id, IpAssignmentPools: &[]zerotier.IpAssignmentPool{{IpRangeStart: &start}},
+			},
+		},
+		{
+			"pool with reversed bounds",
+			zerotier.ControllerNetwork{
+				Id: &id, IpAssignmentPools: &[]zerotier.IpAssignmentPool{newPool("10.0.0.9", "10.0.0.1")},
+			},
+		},
+	}
+	for _, testCase := range invalidCases {
+		if _, err := NewIPAllocations("controller", testCase.network); err == nil {
+			t.Errorf("%s: listed allocations without an error", testCase.name)
+		}
+	}
+}
+
+func TestFindIPOverlaps(t *testing.T) {
+	const (
+		first  = "8056c2e21c000001"
+		second = "8056c2e21c000002"
+		third  = "8056c2e21c000003"
+	)
+	testCases := []struct {
+		name        string
+		allocations []IPAllocation
+		want        [][2]string
+	}{
+		{
+			"adjacent ranges",
+			[]IPAllocation{
+				newTestAllocation(first, IPAllocationRoute, "10.0.0.0/24"),
+				newTestAllocation(second, IPAllocationRoute, "10.0.1.0/24"),
+			},
+			[][2]string{},
+		},
+		{
+			"ranges sharing one address",
+			[]IPAllocation{
+				newTestAllocation(first, IPAllocationPool, "10.0.0.0-10.0.1.0"),
+				newTestAllocation(second, IPAllocationRoute, "10.0.1.0/24"),
+			},
+			[][2]string{{"10.0.0.0-10.0.1.0", "10.0.1.0/24"}},
+		},
+		{
+			"ranges of the same network",
+			[]IPAllocation{
+				newTestAllocation(first, IPAllocationRoute, "10.0.0.0/24"),
+				newTestAllocation(first, IPAllocationPool, "10.0.0.128-10.0.1.127"),
+			},
+			[][2]string{},
+		},
+		{
+			"range overlapping several later ranges",
+			[]IPAllocation{
+				newTestAllocation(first, IPAllocationRoute, "10.0.0.0/16"),
+				newTestAllocation(second, IPAllocationRoute, "10.0.1.0/24"),
+				newTestAllocation(first, IPAllocationPool, "10.0.2.0-10.0.2.255"),
+				newTestAllocation(third, IPAllocationRoute, "10.0.3.0/24"),
+				newTestAllocation(third, IPAllocationRoute, "10.1.0.0/24"),
+			},
+			[][2]string{{"10.0.0.0/16", "10.0.1.0/24"}, {"10.0.0.0/16", "10.0.3.0/24"}},
+		},
+		{
+			"mixed ipv4 and ipv6",
+			[]IPAllocation{
+				newTestAllocation(first, IPAllocationRoute, "10.0.0.0/8"),
+				newTestAllocation(second, IPAllocationRoute, "10.1.0.0/16"),
+				newTestAllocation(first, IPAllocationRoute, "fd00::/64"),
+				newTestAllocation(second, IPAllocationRoute, "fd00::/80"),
+				newTestAllocation(third, IPAllocationRoute, "fd01::/64"),
+			},
+			[][2]string{{"10.0.0.0/8", "10.1.0.0/16"}, {"fd00::/64", "fd00::/80"}},
+		},
+	}
+	for _, testCase := range testCases {
+		overlaps := FindIPOverlaps(testCase.allocations)
+		got := make([][2]string, len(overlaps))
+		for i, overlap := range overlaps {
+			got[i] = [2]string{overlap.First.Description(), overlap.Second.Description()}
+		}
+		if !reflect.DeepEqual(got, testCase.want) {
+			t.Errorf("%s: found overlaps %v, want %v", testCase.name, got, testCase.want)
+		}
+	}
+}
+
+func TestNextFreePrefix(t *testing.T) {
+	const (
+		first  = "8056c2e21c000001"
+		second = "8056c2e21c000002"
+	)
+	testCases := []struct {
+		name        string
+		supernet    string
+		bits        int
+		allocations []IPAllocation
+		want        string
+	}{
+		{
+			"no allocations",
+			"10.0.0.0/16", 24, nil,
+			"10.0.0.0/24",
+		},
+		{
+			"unmasked supernet",
+			"10.0.5.7/16", 24, nil,
+			"10.0.0.0/24",
+		},
+		{
+			"blocker ending with the candidate",
+			"10.0.0.0/16", 25,
+			[]IPAllocation{newTestAllocation(first, IPAllocationRoute, "10.0.0.0/25")},
+			"10.0.0.128/25",
+		},
+		{
+			"blocker ending mid-candidate",
+			"10.0.0.0/16", 25,
+			[]IPAllocation{newTestAllocation(first, IPAllocationPool, "10.0.0.0-10.0.0.130")},
+			"10.0.1.0/25",
+		},
+		{
+			"blocker starting mid-candidate",
+			"10.0.0.0/16", 24,
+			[]IPAllocation{newTestAllocation(first, IPAllocationPool, "10.0.0.200-10.0.0.210")},
+			"10.0.1.0/24",
+		},
+		{
+			"chain of blockers",
+			"10.0.0.0/16", 24,
+			[]IPAllocation{
+				newTestAllocation(first, IPAllocationRoute, "10.0.0.0/24"),
+				newTestAllocation(second, IPAllocationPool, "10.0.1.0-10.0.1.5"),
+				newTestAllocation(second, IPAllocationPool, "10.0.2.255-10.0.3.0"),
+			},
+			"10.0.4.0/24",
+		},
+		{
+			"blocker outside the supernet",
+			"10.0.0.0/16", 24,
+			[]IPAllocation{newTestAllocation(first, IPAllocationRoute, "10.1.0.0/24")},
+			"10.0.0.0/24",
+		},
+		{
+			"supernet covered by a larger allocation",
+			"10.0.0.0/24", 24,
+			[]IPAllocation{newTestAllocation(first, IPAllocationRoute, "10.0.0.0/16")},
+			"",
+		},
+		{
+			"exhausted supernet",
+			"10.0.0.0/24", 25,
+			[]IPAllocation{
+				newTestAllocation(first, IPAllocationRoute, "10.0.0.0/25"),
+				newTestAllocation(second, IPAllocationPool, "10.0.0.128-10.0.0.129"),
+			},
+			"",
+		},
+		{
+			"exhausted address space",
+			"255.255.255.0/24", 25,
+			[]IPAllocation{newTestAllocation(first, IPAllocationPool, "255.255.255.0-255.255.255.255")},
+			"",
+		},
+		{
+			"ipv6 supernet with ipv4 allocations",
+			"fd00::/64", 80,
+			[]IPAllocation{
+				newTestAllocation(first, IPAllocationRoute, "0.0.0.0/0"),
+				newTestAllocation(second, IPAllocationRoute, "fd00::/80"),
+			},
+			"fd00::1:0:0:0/80",
+		},
+		{
+			"ipv4 supernet with ipv6 allocations",
+			"10.0.0.0/16", 24,
+			[]IPAllocation{
+				newTestAllocation(first, IPAllocationRoute, "::/0"),
+				newTestAllocation(second, IPAllocationRoute, "10.0.0.0/23"),
+			},
+			"10.0.2.0/24",
+		},
+		{
+			"prefix shorter than supernet",
+			"10.0.0.0/16", 8, nil,
+			"",
+		},
+		{
+			"prefix longer than address",
+			"10.0.0.0/16", 33, nil,
+			"",
+		},
+	}
+	for _, testCase := range testCases {
+		prefix, err := NextFreePrefix(
+			netip.MustParsePrefix(testCase.supernet), testCase.bits, testCase.allocations,
+		)
+		if testCase.want == "" {
+			if err == nil {
+				t.Errorf("%s: found prefix %s, want an error", testCase.name, prefix)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: couldn't find a prefix: %s", testCase.name, err)
+			continue
+		}
+		if prefix != netip.MustParsePrefix(testCase.want) {
+			t.Errorf("%s: found prefix %s, want %s", testCase.name, prefix, testCase.want)
+		}
+	}
+}
@@ -10,6 +10,12 @@ func MakeNetworkIDRecord(networkID string) string {
 	return fmt.Sprintf("\"zerotier-net-id=%s\"", networkID)
 }
 
+// MakeNetworkTombstoneRecord makes a record which marks a domain name as having pointed to a
+// network which was deleted.
+func MakeNetworkTombstoneRecord(networkID string) string {
+	return fmt.Sprintf("\"zerotier-net-deleted=%s\"", networkID)
+}
+
 var networkIDRecordParser regexp.Regexp = *regexp.MustCompile(
 	`"zerotier-net-id=([0-9a-fA-F]{16})"`,
 )
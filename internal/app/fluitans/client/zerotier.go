@@ -10,6 +10,7 @@ import (
 
 	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
 	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/internal/clients/ztdevices"
 	"github.com/sargassum-world/fluitans/pkg/desec"
 	"github.com/sargassum-world/fluitans/pkg/zerotier"
 )
@@ -108,6 +109,10 @@ type Member struct {
 	DomainNames    []string
 	ExpectedRRsets []desec.RRset
 	DNSUpdates     map[string][]DNSUpdate
+	Peer           *zerotier.Peer // nil if the member isn't currently a peer of the controller
+	Metadata       ztdevices.Device
+	// Expiration is when the member's authorization will be revoked, or nil if it's permanent
+	Expiration *ztdevices.Expiration
 }
 
 func IdentifyAddressDomainNames(
@@ -211,15 +216,79 @@ func GetMemberRecords(
 	return members, nil
 }
 
+// AddMemberPeers annotates the members with how the controller's ZeroTier node is currently
+// connected to each of them. Peers are only supplementary information, so if they can't be
+// retrieved, the error is logged and the members are left without peers.
+func AddMemberPeers(
+	ctx context.Context, controller ztcontrollers.Controller, members map[string]Member,
+	c *ztc.Client,
+) {
+	peers, err := c.GetPeers(ctx, controller)
+	if err != nil {
+		c.Logger.Error(errors.Wrapf(err, "couldn't get peers of controller %s", controller.Name))
+		return
+	}
+	for _, peer := range peers {
+		if peer.Address == nil {
+			continue
+		}
+		member, ok := members[*peer.Address]
+		if !ok {
+			continue
+		}
+		peer := peer
+		member.Peer = &peer
+		members[*peer.Address] = member
+	}
+}
+
+// AddMemberMetadata annotates the members with the human-friendly information stored about them,
+// and with the expirations of their authorizations.
+func AddMemberMetadata(
+	ctx context.Context, networkID string, members map[string]Member, dc *ztdevices.Client,
+) error {
+	devices, err := dc.GetDevices(ctx, networkID)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't get devices of network %s", networkID)
+	}
+	expirations, err := dc.GetExpirations(ctx, networkID)
+	if err != nil {
+		return err
+	}
+	for address, member := range members {
+		member.Metadata = ztdevices.Device{NetworkID: networkID, MemberAddress: address}
+		if device, ok := devices[address]; ok {
+			member.Metadata = device
+		}
+		member.Expiration = nil
+		if expiration, ok := expirations[address]; ok {
+			member.Expiration = &expiration
+		}
+		members[address] = member
+	}
+	return nil
+}
+
+// SortNetworkMembers sorts members with display names before other members, ordered by their
+// display names; other members are ordered by their domain names and then by their addresses.
 func SortNetworkMembers(members map[string]Member) (addresses []string, sorted []Member) {
 	addresses = make([]string, 0, len(members))
 	for address := range members {
 		addresses = append(addresses, address)
 	}
 	sort.Slice(addresses, func(i, j int) bool {
+		first := members[addresses[i]]
+		second := members[addresses[j]]
+		firstName := strings.ToLower(first.Metadata.DisplayName)
+		secondName := strings.ToLower(second.Metadata.DisplayName)
+		if firstName != secondName {
+			if firstName == "" || secondName == "" {
+				return secondName == ""
+			}
+			return firstName < secondName
+		}
 		return CompareSubnamesAndAddresses(
-			members[addresses[i]].DomainNames, addresses[i],
-			members[addresses[j]].DomainNames, addresses[j],
+			first.DomainNames, addresses[i], second.DomainNames, addresses[j],
 		)
 	})
 	sorted = make([]Member, 0, len(addresses))
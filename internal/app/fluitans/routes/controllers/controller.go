@@ -6,24 +6,30 @@ import (
 	"net/http"
 
 	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
 
 	"github.com/sargassum-world/fluitans/internal/app/fluitans/auth"
 	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
 	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/internal/clients/zttemplates"
 	"github.com/sargassum-world/fluitans/pkg/zerotier"
 )
 
 type ControllerViewData struct {
 	Controller       ztcontrollers.Controller
+	Reachable        bool
+	Health           ControllerHealth
 	Status           zerotier.Status
 	ControllerStatus zerotier.ControllerStatus
 	Networks         map[string]zerotier.ControllerNetwork
+	Templates        []zttemplates.Template
 }
 
 func getControllerViewData(
-	ctx context.Context, name string, cc *ztcontrollers.Client, c *ztc.Client,
+	ctx context.Context, name string,
+	cc *ztcontrollers.Client, c *ztc.Client, tc *zttemplates.Client,
 ) (vd ControllerViewData, err error) {
-	controller, err := cc.FindController(name)
+	controller, err := cc.FindController(ctx, name)
 	if err != nil {
 		return ControllerViewData{}, err
 	}
@@ -33,11 +39,19 @@ func getControllerViewData(
 		)
 	}
 	vd.Controller = *controller
+	if vd.Health, err = getControllerHealth(ctx, *controller, cc); err != nil {
+		return ControllerViewData{}, err
+	}
 
 	status, controllerStatus, networkIDs, err := c.GetControllerInfo(ctx, *controller, cc)
 	if err != nil {
-		return ControllerViewData{}, err
+		if errors.Is(err, context.Canceled) {
+			return ControllerViewData{}, err
+		}
+		// We still show the controller so that its settings can be fixed, or so it can be removed
+		return vd, nil
 	}
+	vd.Reachable = true
 	vd.Status = *status
 	vd.ControllerStatus = *controllerStatus
 
@@ -48,6 +62,9 @@ func getControllerViewData(
 		return ControllerViewData{}, err
 	}
 	vd.Networks = networks[0]
+	if vd.Templates, err = tc.GetTemplates(ctx); err != nil {
+		return ControllerViewData{}, err
+	}
 
 	return vd, nil
 }
@@ -60,15 +77,94 @@ func (h *Handlers) HandleControllerGet() auth.HTTPHandlerFunc {
 		name := c.Param("name")
 
 		// Run queries
-		controllerViewData, err := getControllerViewData(c.Request().Context(), name, h.ztcc, h.ztc)
+		controllerViewData, err := getControllerViewData(
+			c.Request().Context(), name, h.ztcc, h.ztc, h.ztt,
+		)
 		if err != nil {
 			return err
 		}
 
 		// Produce output
 		// Zero out clocks before computing etag for client-side caching
-		*controllerViewData.Status.Clock = 0
-		*controllerViewData.ControllerStatus.Clock = 0
+		if controllerViewData.Reachable {
+			*controllerViewData.Status.Clock = 0
+			*controllerViewData.ControllerStatus.Clock = 0
+		}
 		return h.r.CacheablePage(c.Response(), c.Request(), t, controllerViewData, a)
 	}
 }
+
+func (h *Handlers) findController(ctx context.Context, name string) (
+	*ztcontrollers.Controller, error,
+) {
+	controller, err := h.ztcc.FindController(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if controller == nil {
+		return nil, echo.NewHTTPError(
+			http.StatusNotFound, fmt.Sprintf("zerotier controller %s not found", name),
+		)
+	}
+	return controller, nil
+}
+
+func (h *Handlers) HandleControllerPost() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// Parse params
+		name := c.Param("name")
+		state := c.FormValue("state")
+
+		// Run queries
+		ctx := c.Request().Context()
+		controller, err := h.findController(ctx, name)
+		if err != nil {
+			return err
+		}
+		switch state {
+		default:
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+				"invalid zerotier controller state %s", state,
+			))
+		case "deleted":
+			// Networks hosted by the controller are left untouched on the controller itself; Fluitans
+			// just stops managing them
+			if err = h.ztcc.DeleteController(ctx, controller.ID); err != nil {
+				return err
+			}
+
+			// Redirect user
+			return c.Redirect(http.StatusSeeOther, "/controllers")
+		}
+	}
+}
+
+func (h *Handlers) HandleControllerSettingsPost() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// Parse params
+		name := c.Param("name")
+		ctx := c.Request().Context()
+		prevController, err := h.findController(ctx, name)
+		if err != nil {
+			return err
+		}
+		controller, err := parseController(c, *prevController)
+		if err != nil {
+			return err
+		}
+
+		// Run queries
+		if err = checkConflicts(ctx, controller, h.ztcc); err != nil {
+			return err
+		}
+		if _, err = h.ztcc.CheckController(ctx, controller); err != nil {
+			return err
+		}
+		if err = h.ztcc.UpdateController(ctx, controller); err != nil {
+			return err
+		}
+
+		// Redirect user
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/controllers/%s", controller.Name))
+	}
+}
@@ -1,22 +1,188 @@
 package controllers
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/labstack/echo/v4"
 
 	"github.com/sargassum-world/fluitans/internal/app/fluitans/auth"
+	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/pkg/secrets"
+	"github.com/sargassum-world/fluitans/pkg/transport"
 )
 
+const defaultNetworkCostWeight = 1.0
+
+type ControllersViewData struct {
+	Controllers   []ztcontrollers.Controller
+	NewController ztcontrollers.Controller
+}
+
 func (h *Handlers) HandleControllersGet() auth.HTTPHandlerFunc {
 	t := "controllers/controllers.page.tmpl"
 	h.r.MustHave(t)
 	return func(c echo.Context, a auth.Auth) error {
 		// Run queries
-		controllers, err := h.ztcc.GetControllers()
+		controllers, err := h.ztcc.GetControllers(c.Request().Context())
 		if err != nil {
 			return err
 		}
 
 		// Produce output
-		return h.r.CacheablePage(c.Response(), c.Request(), t, controllers, a)
+		return h.r.CacheablePage(c.Response(), c.Request(), t, ControllersViewData{
+			Controllers: controllers,
+			NewController: ztcontrollers.Controller{
+				NetworkCostWeight: defaultNetworkCostWeight,
+				Transport:         transport.NewDefaultSettings(),
+			},
+		}, a)
+	}
+}
+
+func parseController(c echo.Context, prevController ztcontrollers.Controller) (
+	controller ztcontrollers.Controller, err error,
+) {
+	controller = prevController
+	controller.Name = strings.TrimSpace(c.FormValue("name"))
+	if controller.Name == "" {
+		return ztcontrollers.Controller{}, echo.NewHTTPError(
+			http.StatusBadRequest, "zerotier controller name is required",
+		)
+	}
+	if strings.Contains(controller.Name, "/") {
+		return ztcontrollers.Controller{}, echo.NewHTTPError(
+			http.StatusBadRequest, "zerotier controller name can't contain slashes",
+		)
+	}
+
+	controller.Server = strings.TrimSuffix(strings.TrimSpace(c.FormValue("server")), "/")
+	serverURL, err := url.Parse(controller.Server)
+	if err != nil || (serverURL.Scheme != "http" && serverURL.Scheme != "https") ||
+		serverURL.Host == "" {
+		return ztcontrollers.Controller{}, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"invalid zerotier controller server url %s", controller.Server,
+		))
+	}
+
+	controller.Description = strings.TrimSpace(c.FormValue("description"))
+
+	// An empty authtoken leaves the previous authtoken unchanged, so that the authtoken never needs
+	// to be sent back to the browser for editing
+	if authtoken := strings.TrimSpace(c.FormValue("authtoken")); authtoken != "" {
+		controller.Authtoken = secrets.Redacted(authtoken)
+	}
+	if controller.Authtoken == "" {
+		return ztcontrollers.Controller{}, echo.NewHTTPError(
+			http.StatusBadRequest, "zerotier controller authtoken is required",
+		)
+	}
+
+	controller.NetworkCostWeight = defaultNetworkCostWeight
+	if rawWeight := strings.TrimSpace(c.FormValue("network-cost-weight")); rawWeight != "" {
+		const floatBitSize = 32
+		weight, err := strconv.ParseFloat(rawWeight, floatBitSize)
+		if err != nil || weight < 0 {
+			return ztcontrollers.Controller{}, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+				"invalid network cost weight %s", rawWeight,
+			))
+		}
+		controller.NetworkCostWeight = float32(weight)
+	}
+
+	if controller.Transport, err = parseTransport(c, prevController.Transport); err != nil {
+		return ztcontrollers.Controller{}, err
+	}
+	return controller, nil
+}
+
+func parseTransport(c echo.Context, prevSettings transport.Settings) (
+	settings transport.Settings, err error,
+) {
+	settings.Timeout = transport.DefaultTimeout
+	if rawTimeout := strings.TrimSpace(c.FormValue("request-timeout")); rawTimeout != "" {
+		const floatBitSize = 64
+		timeout, err := strconv.ParseFloat(rawTimeout, floatBitSize)
+		if err != nil || timeout < 0 {
+			return transport.Settings{}, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+				"invalid request timeout %s", rawTimeout,
+			))
+		}
+		settings.Timeout = time.Duration(timeout * float64(time.Second))
+	}
+	settings.ProxyURL = strings.TrimSpace(c.FormValue("proxy-url"))
+	settings.CABundle = strings.TrimSpace(c.FormValue("ca-bundle"))
+	settings.ClientCert = strings.TrimSpace(c.FormValue("client-cert"))
+
+	// As with the authtoken, an empty client key leaves the previous client key unchanged - unless
+	// the client certificate was removed, in which case its key is useless
+	settings.ClientKey = prevSettings.ClientKey
+	if clientKey := strings.TrimSpace(c.FormValue("client-key")); clientKey != "" {
+		settings.ClientKey = secrets.Redacted(clientKey)
+	}
+	if settings.ClientCert == "" {
+		settings.ClientKey = ""
+	}
+
+	if err = settings.Validate(); err != nil {
+		return transport.Settings{}, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"invalid connection settings: %s", err,
+		))
+	}
+	return settings, nil
+}
+
+func checkConflicts(
+	ctx context.Context, controller ztcontrollers.Controller, cc *ztcontrollers.Client,
+) error {
+	controllers, err := cc.GetControllers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, existing := range controllers {
+		if existing.ID == controller.ID {
+			continue
+		}
+		if existing.Name == controller.Name {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+				"zerotier controller with name %s already exists", controller.Name,
+			))
+		}
+		if existing.Server == controller.Server {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+				"zerotier controller with server %s already exists", controller.Server,
+			))
+		}
+	}
+	return nil
+}
+
+func (h *Handlers) HandleControllersPost() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// Parse params
+		controller, err := parseController(c, ztcontrollers.Controller{})
+		if err != nil {
+			return err
+		}
+
+		// Run queries
+		ctx := c.Request().Context()
+		if err = checkConflicts(ctx, controller, h.ztcc); err != nil {
+			return err
+		}
+		if _, err = h.ztcc.CheckController(ctx, controller); err != nil {
+			return err
+		}
+		if _, err = h.ztcc.AddController(ctx, controller); err != nil {
+			return err
+		}
+
+		// Redirect user
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/controllers/%s", controller.Name))
 	}
 }
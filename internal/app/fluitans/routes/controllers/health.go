@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sargassum-world/godest/handling"
+	"github.com/sargassum-world/godest/turbostreams"
+
+	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+)
+
+const (
+	healthPartial       = "controllers/controller-health.partial.tmpl"
+	healthHistoryWindow = 7 * 24 * time.Hour
+)
+
+type ControllerHealth struct {
+	Latest            *ztcontrollers.ControllerEvent
+	RecentEvents      []ztcontrollers.ControllerEvent // sorted from newest to oldest
+	Uptime            float64
+	MonitoredHours    float64
+	HistoryWindowDays float64
+}
+
+func getControllerHealth(
+	ctx context.Context, controller ztcontrollers.Controller, cc *ztcontrollers.Client,
+) (h ControllerHealth, err error) {
+	end := time.Now()
+	start := end.Add(-healthHistoryWindow)
+	events, err := cc.GetControllerEvents(ctx, controller.ID, start)
+	if err != nil {
+		return ControllerHealth{}, err
+	}
+
+	const hoursPerDay = 24
+	h.HistoryWindowDays = healthHistoryWindow.Hours() / hoursPerDay
+	if len(events) == 0 {
+		return h, nil
+	}
+	h.Latest = &events[len(events)-1]
+	var monitored time.Duration
+	h.Uptime, monitored = ztcontrollers.ComputeUptime(events, start, end)
+	h.MonitoredHours = monitored.Hours()
+	h.RecentEvents = make([]ztcontrollers.ControllerEvent, len(events))
+	for i, event := range events {
+		h.RecentEvents[len(events)-1-i] = event
+	}
+	return h, nil
+}
+
+func replaceHealthStream(
+	controller ztcontrollers.Controller, health ControllerHealth,
+) turbostreams.Message {
+	return turbostreams.Message{
+		Action:   turbostreams.ActionReplace,
+		Target:   fmt.Sprintf("/controllers/%s/health", controller.Name),
+		Template: healthPartial,
+		Data: map[string]interface{}{
+			"Controller": controller,
+			"Health":     health,
+		},
+	}
+}
+
+func (h *Handlers) HandleHealthSub() turbostreams.HandlerFunc {
+	return func(c *turbostreams.Context) error {
+		// Parse params
+		name := c.Param("name")
+
+		// Run queries
+		if _, err := h.findController(c.Context(), name); err != nil {
+			return errors.Wrapf(err, "couldn't find controller %s", name)
+		}
+
+		// Allow subscription
+		return nil
+	}
+}
+
+func (h *Handlers) HandleHealthPub() turbostreams.HandlerFunc {
+	h.r.MustHave(healthPartial)
+	return func(c *turbostreams.Context) error {
+		// Make change trackers
+		initialized := false
+		var prevEventID int64
+
+		// Parse params
+		name := c.Param("name")
+
+		// Publish periodically
+		const pubInterval = 5 * time.Second
+		return handling.RepeatImmediate(c.Context(), pubInterval, func() (done bool, err error) {
+			// Check for changes
+			ctx := c.Context()
+			controller, err := h.findController(ctx, name)
+			if err != nil {
+				return false, errors.Wrapf(err, "couldn't find controller %s", name)
+			}
+			latest, err := h.ztcc.GetLatestControllerEvent(ctx, controller.ID)
+			if err != nil {
+				return false, errors.Wrapf(err, "couldn't check controller %s health for changes", name)
+			}
+			var eventID int64
+			if latest != nil {
+				eventID = latest.ID
+			}
+			if initialized && eventID == prevEventID {
+				return false, nil
+			}
+			prevEventID = eventID
+			if !initialized {
+				// We just started publishing because a page added a subscription, so there's no need to
+				// send the health history again - that page already has the latest version
+				initialized = true
+				return false, nil
+			}
+
+			// Publish changes
+			health, err := getControllerHealth(ctx, *controller, h.ztcc)
+			if err != nil {
+				return false, errors.Wrapf(err, "couldn't get controller %s health", name)
+			}
+			c.Publish(replaceHealthStream(*controller, health))
+			return false, nil
+		})
+	}
+}
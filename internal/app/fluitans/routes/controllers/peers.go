@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/auth"
+	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
+	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+type PeerMembership struct {
+	Network zerotier.ControllerNetwork
+}
+
+type PeerViewData struct {
+	Peer        *zerotier.Peer
+	Memberships []PeerMembership
+}
+
+type PeersViewData struct {
+	Controller ztcontrollers.Controller
+	Peers      []PeerViewData
+}
+
+// getNetworkMemberships returns the networks hosted by the controller, keyed by the addresses of
+// their members.
+func getNetworkMemberships(
+	ctx context.Context, controller ztcontrollers.Controller,
+	c *ztc.Client, cc *ztcontrollers.Client,
+) (map[string][]PeerMembership, error) {
+	networkIDs, err := c.GetNetworkIDs(ctx, controller, cc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't get network ids of controller %s", controller.Name)
+	}
+	sort.Strings(networkIDs)
+
+	eg, egctx := errgroup.WithContext(ctx)
+	var networks map[string]zerotier.ControllerNetwork
+	memberAddresses := make([][]string, len(networkIDs))
+	eg.Go(func() (err error) {
+		networks, err = c.GetNetworks(egctx, controller, networkIDs)
+		return err
+	})
+	for i, id := range networkIDs {
+		eg.Go(func(i int, id string) func() error {
+			return func() (err error) {
+				memberAddresses[i], err = c.GetNetworkMemberAddresses(egctx, controller, id)
+				return err
+			}
+		}(i, id))
+	}
+	if err = eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	memberships := make(map[string][]PeerMembership)
+	for i, id := range networkIDs {
+		network, ok := networks[id]
+		if !ok {
+			continue
+		}
+		for _, address := range memberAddresses[i] {
+			memberships[address] = append(memberships[address], PeerMembership{Network: network})
+		}
+	}
+	return memberships, nil
+}
+
+func getPeersViewData(
+	ctx context.Context, controller ztcontrollers.Controller,
+	c *ztc.Client, cc *ztcontrollers.Client,
+) (vd PeersViewData, err error) {
+	vd.Controller = controller
+
+	eg, egctx := errgroup.WithContext(ctx)
+	var peers []zerotier.Peer
+	var memberships map[string][]PeerMembership
+	eg.Go(func() (err error) {
+		peers, err = c.GetPeers(egctx, controller)
+		return errors.Wrapf(err, "couldn't get peers of controller %s", controller.Name)
+	})
+	eg.Go(func() (err error) {
+		memberships, err = getNetworkMemberships(egctx, controller, c, cc)
+		return err
+	})
+	if err = eg.Wait(); err != nil {
+		return PeersViewData{}, err
+	}
+
+	vd.Peers = make([]PeerViewData, len(peers))
+	for i := range peers {
+		vd.Peers[i] = PeerViewData{Peer: &peers[i]}
+		if peers[i].Address != nil {
+			vd.Peers[i].Memberships = memberships[*peers[i].Address]
+		}
+	}
+	return vd, nil
+}
+
+func (h *Handlers) HandlePeersGet() auth.HTTPHandlerFunc {
+	t := "controllers/peers.page.tmpl"
+	h.r.MustHave(t)
+	return func(c echo.Context, a auth.Auth) error {
+		// Parse params
+		name := c.Param("name")
+
+		// Run queries
+		ctx := c.Request().Context()
+		controller, err := h.findController(ctx, name)
+		if err != nil {
+			return err
+		}
+		peersViewData, err := getPeersViewData(ctx, *controller, h.ztc, h.ztcc)
+		if err != nil {
+			return err
+		}
+
+		// Produce output
+		return h.r.CacheablePage(c.Response(), c.Request(), t, peersViewData, a)
+	}
+}
@@ -4,10 +4,13 @@ package controllers
 import (
 	"github.com/sargassum-world/godest"
 	"github.com/sargassum-world/godest/session"
+	"github.com/sargassum-world/godest/turbostreams"
 
 	"github.com/sargassum-world/fluitans/internal/app/fluitans/auth"
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/handling"
 	"github.com/sargassum-world/fluitans/internal/clients/zerotier"
 	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/internal/clients/zttemplates"
 )
 
 type Handlers struct {
@@ -15,20 +18,32 @@ type Handlers struct {
 
 	ztcc *ztcontrollers.Client
 	ztc  *zerotier.Client
+	ztt  *zttemplates.Client
 }
 
 func New(
-	r godest.TemplateRenderer, ztcc *ztcontrollers.Client, ztc *zerotier.Client,
+	r godest.TemplateRenderer,
+	ztcc *ztcontrollers.Client, ztc *zerotier.Client, ztt *zttemplates.Client,
 ) *Handlers {
 	return &Handlers{
 		r:    r,
 		ztcc: ztcc,
 		ztc:  ztc,
+		ztt:  ztt,
 	}
 }
 
-func (h *Handlers) Register(er godest.EchoRouter, ss *session.Store) {
+func (h *Handlers) Register(er godest.EchoRouter, tsr turbostreams.Router, ss *session.Store) {
 	ar := auth.NewHTTPRouter(er, ss)
+	haz := auth.RequireHTTPAuthz(ss)
+	tsaz := auth.RequireTSAuthz(ss)
 	ar.GET("/controllers", h.HandleControllersGet())
+	er.POST("/controllers", h.HandleControllersPost(), haz)
 	ar.GET("/controllers/:name", h.HandleControllerGet())
+	er.POST("/controllers/:name", h.HandleControllerPost(), haz)
+	er.POST("/controllers/:name/settings", h.HandleControllerSettingsPost(), haz)
+	ar.GET("/controllers/:name/peers", h.HandlePeersGet())
+	tsr.SUB("/controllers/:name/health", h.HandleHealthSub(), tsaz)
+	tsr.PUB("/controllers/:name/health", h.HandleHealthPub())
+	tsr.MSG("/controllers/:name/health", handling.HandleTSMsg(h.r, ss), tsaz)
 }
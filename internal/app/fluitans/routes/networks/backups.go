@@ -0,0 +1,555 @@
+package networks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/auth"
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/client"
+	desecc "github.com/sargassum-world/fluitans/internal/clients/desec"
+	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
+	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/pkg/desec"
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+// Export
+
+func exportNetworks(
+	ctx context.Context, controller ztcontrollers.Controller, ids []string,
+	c *ztc.Client, dc *desecc.Client,
+) (b client.NetworksBackup, err error) {
+	eg, egctx := errgroup.WithContext(ctx)
+	var networks map[string]zerotier.ControllerNetwork
+	var subnameRRsets map[string][]desec.RRset
+	eg.Go(func() (err error) {
+		networks, err = c.GetNetworks(egctx, controller, ids)
+		return err
+	})
+	eg.Go(func() (err error) {
+		subnameRRsets, err = dc.GetRRsets(egctx)
+		return err
+	})
+	if err = eg.Wait(); err != nil {
+		return client.NetworksBackup{}, err
+	}
+
+	b = client.NetworksBackup{
+		Version:    client.NetworksBackupVersion,
+		Exported:   time.Now(),
+		Controller: controller.Name,
+		DomainName: dc.Config.DomainName,
+		Networks:   make([]client.NetworkBackup, 0, len(networks)),
+	}
+	sortedIDs := make([]string, 0, len(networks))
+	for id := range networks {
+		sortedIDs = append(sortedIDs, id)
+	}
+	sort.Strings(sortedIDs)
+	for _, id := range sortedIDs {
+		network, err := client.NewNetworkBackup(
+			ctx, dc.Config.DomainName, controller, networks[id], subnameRRsets, c,
+		)
+		if err != nil {
+			return client.NetworksBackup{}, errors.Wrapf(err, "couldn't export network %s", id)
+		}
+		b.Networks = append(b.Networks, network)
+	}
+	return b, nil
+}
+
+func parseBackupFormat(c echo.Context) (string, error) {
+	switch format := c.QueryParam("format"); format {
+	default:
+		return "", echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"invalid export format %s", format,
+		))
+	case "", client.BackupFormatJSON:
+		return client.BackupFormatJSON, nil
+	case client.BackupFormatYAML:
+		return client.BackupFormatYAML, nil
+	}
+}
+
+func writeBackup(c echo.Context, b client.NetworksBackup, format, filename string) error {
+	document, err := client.EncodeNetworksBackup(b, format)
+	if err != nil {
+		return err
+	}
+	contentType := echo.MIMEApplicationJSONCharsetUTF8
+	if format == client.BackupFormatYAML {
+		contentType = "application/yaml; charset=UTF-8"
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(
+		"attachment; filename=%q", filename+"."+format,
+	))
+	return c.Blob(http.StatusOK, contentType, document)
+}
+
+func (h *Handlers) HandleNetworkExportGet() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// Parse params
+		id := c.Param("id")
+		address := ztc.GetControllerAddress(id)
+		format, err := parseBackupFormat(c)
+		if err != nil {
+			return err
+		}
+
+		// Run queries
+		ctx := c.Request().Context()
+		controller, err := h.ztcc.FindControllerByAddress(ctx, address)
+		if err != nil {
+			return err
+		}
+		if controller == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "controller not found")
+		}
+		backup, err := exportNetworks(ctx, *controller, []string{id}, h.ztc, h.dc)
+		if err != nil {
+			return err
+		}
+		if len(backup.Networks) == 0 {
+			return echo.NewHTTPError(http.StatusNotFound, "zerotier network not found")
+		}
+
+		// Produce output
+		return writeBackup(c, backup, format, fmt.Sprintf("network-%s", id))
+	}
+}
+
+func (h *Handlers) HandleNetworksExportGet() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// Parse params
+		name := c.QueryParam("controller")
+		if name == "" {
+			return echo.NewHTTPError(
+				http.StatusBadRequest, "zerotier controller name not specified",
+			)
+		}
+		format, err := parseBackupFormat(c)
+		if err != nil {
+			return err
+		}
+
+		// Run queries
+		ctx := c.Request().Context()
+		controller, err := h.ztcc.FindController(ctx, name)
+		if err != nil {
+			return err
+		}
+		if controller == nil {
+			return echo.NewHTTPError(
+				http.StatusNotFound, fmt.Sprintf("zerotier controller %s not found", name),
+			)
+		}
+		ids, err := h.ztc.GetNetworkIDs(ctx, *controller, h.ztcc)
+		if err != nil {
+			return err
+		}
+		backup, err := exportNetworks(ctx, *controller, ids, h.ztc, h.dc)
+		if err != nil {
+			return err
+		}
+
+		// Produce output
+		return writeBackup(c, backup, format, fmt.Sprintf("networks-%s", controller.Name))
+	}
+}
+
+// Import Planning
+
+type MemberImportPlan struct {
+	Backup      client.MemberBackup
+	Subnames    []string
+	DomainNames []string
+}
+
+type NetworkImportPlan struct {
+	Backup client.NetworkBackup
+	Name   string
+	// DNSName is the subname which will name the network by DNS, or empty if the network won't be
+	// named by DNS
+	DNSName  string
+	Members  []MemberImportPlan
+	Warnings []string
+}
+
+func planMemberImport(
+	b client.MemberBackup, dnsName, domainName string, subnameRRsets map[string][]desec.RRset,
+) (plan MemberImportPlan, warnings []string) {
+	plan = MemberImportPlan{
+		Backup:      b,
+		Subnames:    make([]string, 0, len(b.Names)),
+		DomainNames: make([]string, 0, len(b.Names)),
+	}
+	if dnsName == "" {
+		if len(b.Names) > 0 {
+			warnings = append(warnings, fmt.Sprintf(
+				"DNS names of device %s won't be restored, since the network won't be named by DNS",
+				b.Address,
+			))
+		}
+		return plan, warnings
+	}
+
+	for _, name := range b.Names {
+		subname := fmt.Sprintf("%s.d.%s", name, dnsName)
+		plan.Subnames = append(plan.Subnames, subname)
+		plan.DomainNames = append(plan.DomainNames, subname+"."+domainName)
+		for _, rrset := range subnameRRsets[subname] {
+			if rrset.Type == "AAAA" || rrset.Type == "A" {
+				warnings = append(warnings, fmt.Sprintf(
+					"existing DNS records at %s.%s will be replaced", subname, domainName,
+				))
+				break
+			}
+		}
+	}
+	return plan, warnings
+}
+
+func planNetworkImport(
+	b client.NetworkBackup, domainName string, subnameRRsets map[string][]desec.RRset,
+	networkIDs map[string]string, claimedDNSNames map[string]bool,
+) (plan NetworkImportPlan) {
+	plan = NetworkImportPlan{
+		Backup:  b,
+		Name:    b.Name,
+		Members: make([]MemberImportPlan, 0, len(b.Members)),
+	}
+	switch {
+	case b.DNSName == "":
+	case networkIDs[b.DNSName] != "":
+		// The network's name was its domain name, which now belongs to another network
+		plan.Name = ""
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf(
+			"%s.%s already names network %s, so the network won't be named by DNS",
+			b.DNSName, domainName, networkIDs[b.DNSName],
+		))
+	case claimedDNSNames[b.DNSName]:
+		plan.Name = ""
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf(
+			"%s.%s names another network in this import, so the network won't be named by DNS",
+			b.DNSName, domainName,
+		))
+	default:
+		plan.DNSName = b.DNSName
+		plan.Name = b.DNSName + "." + domainName
+		claimedDNSNames[b.DNSName] = true
+	}
+
+	for _, member := range b.Members {
+		memberPlan, warnings := planMemberImport(member, plan.DNSName, domainName, subnameRRsets)
+		plan.Members = append(plan.Members, memberPlan)
+		plan.Warnings = append(plan.Warnings, warnings...)
+	}
+	return plan
+}
+
+func planNetworksImport(
+	ctx context.Context, b client.NetworksBackup, dc *desecc.Client,
+) ([]NetworkImportPlan, error) {
+	subnameRRsets, err := dc.GetRRsets(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't get dns records")
+	}
+	networkIDs := client.GetNetworkIDs(subnameRRsets)
+	claimedDNSNames := make(map[string]bool)
+	plans := make([]NetworkImportPlan, len(b.Networks))
+	for i, network := range b.Networks {
+		plans[i] = planNetworkImport(
+			network, dc.Config.DomainName, subnameRRsets, networkIDs, claimedDNSNames,
+		)
+	}
+	return plans, nil
+}
+
+// Import Execution
+
+// NetworkImportResult describes what was created by importing a network, so that an import which
+// fails partway can be reported.
+type NetworkImportResult struct {
+	BackupID        string
+	NetworkID       string
+	MemberAddresses []string
+}
+
+func (r NetworkImportResult) String() string {
+	if len(r.MemberAddresses) == 0 {
+		return fmt.Sprintf("network %s (replacing %s) without devices", r.NetworkID, r.BackupID)
+	}
+	return fmt.Sprintf(
+		"network %s (replacing %s) with devices %s",
+		r.NetworkID, r.BackupID, strings.Join(r.MemberAddresses, ", "),
+	)
+}
+
+// importNetworkMembers configures the members of the network, returning the addresses of the
+// members which were configured even if other members couldn't be configured.
+func importNetworkMembers(
+	ctx context.Context, controller ztcontrollers.Controller, networkID string,
+	plans []MemberImportPlan, c *ztc.Client,
+) (memberAddresses []string, err error) {
+	eg, egctx := errgroup.WithContext(ctx)
+	configured := make([]bool, len(plans))
+	for i, plan := range plans {
+		eg.Go(func(i int, plan MemberImportPlan) func() error {
+			return func() error {
+				if err := c.UpdateMember(
+					egctx, controller, networkID, plan.Backup.Address, plan.Backup.NewMemberRequest(),
+				); err != nil {
+					return errors.Wrapf(
+						err, "couldn't configure network %s member %s", networkID, plan.Backup.Address,
+					)
+				}
+				configured[i] = true
+				return nil
+			}
+		}(i, plan))
+	}
+	err = eg.Wait()
+	memberAddresses = make([]string, 0, len(plans))
+	for i, plan := range plans {
+		if configured[i] {
+			memberAddresses = append(memberAddresses, plan.Backup.Address)
+		}
+	}
+	return memberAddresses, err
+}
+
+func newImportedMemberRRsets(
+	network zerotier.ControllerNetwork, plans []MemberImportPlan, ttl int,
+) ([]desec.RRset, error) {
+	v6AssignMode := zerotier.V6AssignMode{}
+	if network.V6AssignMode != nil {
+		v6AssignMode = *network.V6AssignMode
+	}
+	rrsets := make([]desec.RRset, 0)
+	for _, plan := range plans {
+		if len(plan.Subnames) == 0 {
+			continue
+		}
+
+		address := plan.Backup.Address
+		member := zerotier.ControllerNetworkMember{
+			Address:       &address,
+			IpAssignments: &plan.Backup.IPAssignments,
+		}
+		ipAddresses, _, err := ztc.CalculateIPAddresses(*network.Id, v6AssignMode, member)
+		if err != nil {
+			return nil, errors.Wrapf(
+				err, "couldn't determine ip addresses for network %s member %s", *network.Id, address,
+			)
+		}
+		member.IpAssignments = &ipAddresses
+		for _, subname := range plan.Subnames {
+			memberRRsets, err := client.NewMemberNameRRsets(member, subname, ttl)
+			if err != nil {
+				return nil, errors.Wrapf(
+					err, "couldn't make AAAA and A rrsets for network %s member %s", *network.Id, address,
+				)
+			}
+			rrsets = append(rrsets, memberRRsets...)
+		}
+	}
+	return rrsets, nil
+}
+
+// importNetwork creates and configures a network as planned. The result describes whatever was
+// created, even if the import failed partway.
+func importNetwork(
+	ctx context.Context, controller ztcontrollers.Controller, plan NetworkImportPlan,
+	c *ztc.Client, cc *ztcontrollers.Client, dc *desecc.Client,
+) (result NetworkImportResult, err error) {
+	result.BackupID = plan.Backup.ID
+	// Networks which will be named by DNS only get their names once their DNS records exist
+	name := plan.Name
+	if plan.DNSName != "" {
+		name = ""
+	}
+	network, err := c.CreateNetworkWithConfig(
+		ctx, controller, plan.Backup.NewNetworkRequest(name), cc,
+	)
+	if err != nil {
+		return result, errors.Wrapf(err, "couldn't create network to replace %s", plan.Backup.ID)
+	}
+	if network == nil || network.Id == nil {
+		return result, errors.Errorf(
+			"status of network created to replace %s is unknown", plan.Backup.ID,
+		)
+	}
+	id := *network.Id
+	result.NetworkID = id
+
+	if plan.DNSName != "" {
+		if network, err = nameNetwork(ctx, controller, id, plan.DNSName, c, dc); err != nil {
+			return result, errors.Wrapf(err, "couldn't name network %s as %s", id, plan.DNSName)
+		}
+	}
+	result.MemberAddresses, err = importNetworkMembers(ctx, controller, id, plan.Members, c)
+	if err != nil {
+		return result, err
+	}
+
+	rrsets, err := newImportedMemberRRsets(*network, plan.Members, int(c.Config.DNS.DeviceTTL))
+	if err != nil {
+		return result, err
+	}
+	if len(rrsets) == 0 {
+		return result, nil
+	}
+	if _, err = dc.UpsertRRsets(ctx, rrsets...); err != nil {
+		return result, errors.Wrapf(err, "couldn't upsert AAAA and/or A records for network %s", id)
+	}
+	return result, nil
+}
+
+// newNetworksImportError reports the failed import of a network along with everything which was
+// created before the import failed, since it isn't rolled back.
+func newNetworksImportError(err error, backupID string, results []NetworkImportResult) error {
+	created := make([]string, 0, len(results))
+	for _, result := range results {
+		if result.NetworkID != "" {
+			created = append(created, result.String())
+		}
+	}
+	if len(created) == 0 {
+		return errors.Wrapf(err, "couldn't import network %s, and no networks were created", backupID)
+	}
+	return errors.Wrapf(
+		err, "couldn't import network %s, after creating %s", backupID, strings.Join(created, "; "),
+	)
+}
+
+// Import Handlers
+
+type NetworksImportViewData struct {
+	Controllers []ztcontrollers.Controller
+	Controller  string
+	Document    string
+	DomainName  string
+	Previewed   bool
+	Backup      client.NetworksBackup
+	Networks    []NetworkImportPlan
+}
+
+func getNetworksImportViewData(
+	ctx context.Context, cc *ztcontrollers.Client, dc *desecc.Client,
+) (vd NetworksImportViewData, err error) {
+	if vd.Controllers, err = cc.GetControllers(ctx); err != nil {
+		return NetworksImportViewData{}, err
+	}
+	vd.DomainName = dc.Config.DomainName
+	return vd, nil
+}
+
+func parseNetworksImport(
+	c echo.Context, cc *ztcontrollers.Client,
+) (*ztcontrollers.Controller, client.NetworksBackup, error) {
+	name := c.FormValue("controller")
+	if name == "" {
+		return nil, client.NetworksBackup{}, echo.NewHTTPError(
+			http.StatusBadRequest, "zerotier controller name not specified",
+		)
+	}
+	controller, err := cc.FindController(c.Request().Context(), name)
+	if err != nil {
+		return nil, client.NetworksBackup{}, err
+	}
+	if controller == nil {
+		return nil, client.NetworksBackup{}, echo.NewHTTPError(
+			http.StatusNotFound, fmt.Sprintf("zerotier controller %s not found", name),
+		)
+	}
+
+	backup, err := client.DecodeNetworksBackup([]byte(c.FormValue("document")))
+	if err != nil {
+		return nil, client.NetworksBackup{}, echo.NewHTTPError(
+			http.StatusBadRequest, fmt.Sprintf("invalid network configurations document: %s", err),
+		)
+	}
+	return controller, backup, nil
+}
+
+func (h *Handlers) HandleNetworksImportGet() auth.HTTPHandlerFunc {
+	t := "networks/import.page.tmpl"
+	h.r.MustHave(t)
+	return func(c echo.Context, a auth.Auth) error {
+		// Run queries
+		importViewData, err := getNetworksImportViewData(c.Request().Context(), h.ztcc, h.dc)
+		if err != nil {
+			return err
+		}
+		importViewData.Controller = c.QueryParam("controller")
+
+		// Produce output
+		return h.r.CacheablePage(c.Response(), c.Request(), t, importViewData, a)
+	}
+}
+
+func (h *Handlers) HandleNetworksImportPreviewPost() auth.HTTPHandlerFunc {
+	t := "networks/import.page.tmpl"
+	h.r.MustHave(t)
+	return func(c echo.Context, a auth.Auth) error {
+		// Parse params
+		controller, backup, err := parseNetworksImport(c, h.ztcc)
+		if err != nil {
+			return err
+		}
+
+		// Run queries
+		ctx := c.Request().Context()
+		importViewData, err := getNetworksImportViewData(ctx, h.ztcc, h.dc)
+		if err != nil {
+			return err
+		}
+		importViewData.Controller = controller.Name
+		importViewData.Document = c.FormValue("document")
+		importViewData.Previewed = true
+		importViewData.Backup = backup
+		if importViewData.Networks, err = planNetworksImport(ctx, backup, h.dc); err != nil {
+			return err
+		}
+
+		// Produce output
+		return h.r.Page(c.Response(), c.Request(), http.StatusOK, t, importViewData, a)
+	}
+}
+
+func (h *Handlers) HandleNetworksImportPost() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// Parse params
+		controller, backup, err := parseNetworksImport(c, h.ztcc)
+		if err != nil {
+			return err
+		}
+
+		// Run queries
+		ctx := c.Request().Context()
+		// We plan the import again, since DNS records may have changed since the preview
+		plans, err := planNetworksImport(ctx, backup, h.dc)
+		if err != nil {
+			return err
+		}
+		results := make([]NetworkImportResult, 0, len(plans))
+		for _, plan := range plans {
+			result, err := importNetwork(ctx, *controller, plan, h.ztc, h.ztcc, h.dc)
+			results = append(results, result)
+			if err != nil {
+				return newNetworksImportError(err, plan.Backup.ID, results)
+			}
+		}
+
+		// Redirect user
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/controllers/%s", controller.Name))
+	}
+}
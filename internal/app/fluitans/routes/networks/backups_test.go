@@ -0,0 +1,76 @@
+package networks
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/client"
+)
+
+func TestPlanNetworkImportNames(t *testing.T) {
+	const domainName = "example.com"
+	networkIDs := map[string]string{"taken": "8056c2e21c000001"}
+	claimedDNSNames := map[string]bool{"claimed": true}
+	testCases := []struct {
+		name        string
+		backup      client.NetworkBackup
+		wantName    string
+		wantDNSName string
+	}{
+		{
+			"not named by dns",
+			client.NetworkBackup{ID: "a", Name: "lab"},
+			"lab", "",
+		},
+		{
+			"available dns name",
+			client.NetworkBackup{ID: "b", Name: "free.example.com", DNSName: "free"},
+			"free.example.com", "free",
+		},
+		{
+			"dns name of another network",
+			client.NetworkBackup{ID: "c", Name: "taken.example.com", DNSName: "taken"},
+			"", "",
+		},
+		{
+			"dns name claimed in the import",
+			client.NetworkBackup{ID: "d", Name: "claimed.example.com", DNSName: "claimed"},
+			"", "",
+		},
+	}
+	for _, testCase := range testCases {
+		plan := planNetworkImport(testCase.backup, domainName, nil, networkIDs, claimedDNSNames)
+		if plan.Name != testCase.wantName || plan.DNSName != testCase.wantDNSName {
+			t.Errorf(
+				"%s: planned name %q and dns name %q, want %q and %q", testCase.name,
+				plan.Name, plan.DNSName, testCase.wantName, testCase.wantDNSName,
+			)
+		}
+	}
+}
+
+func TestNewNetworksImportError(t *testing.T) {
+	cause := errors.New("controller unreachable")
+	results := []NetworkImportResult{
+		{BackupID: "a", NetworkID: "8056c2e21c000001", MemberAddresses: []string{"0123456789"}},
+		{BackupID: "b", NetworkID: "8056c2e21c000002"},
+		{BackupID: "c"},
+	}
+	message := newNetworksImportError(cause, "c", results).Error()
+	for _, want := range []string{
+		"couldn't import network c",
+		"network 8056c2e21c000001 (replacing a) with devices 0123456789",
+		"network 8056c2e21c000002 (replacing b) without devices",
+		"controller unreachable",
+	} {
+		if !strings.Contains(message, want) {
+			t.Errorf("error %q doesn't contain %q", message, want)
+		}
+	}
+
+	message = newNetworksImportError(cause, "a", results[2:]).Error()
+	if !strings.Contains(message, "no networks were created") {
+		t.Errorf("error %q doesn't report that no networks were created", message)
+	}
+}
@@ -0,0 +1,125 @@
+package networks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/sargassum-world/godest/turbostreams"
+
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/auth"
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/client"
+	desecc "github.com/sargassum-world/fluitans/internal/clients/desec"
+	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
+	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/internal/clients/ztdevices"
+)
+
+// Device Deletion
+
+// getMemberRecordKeys returns the keys of the DNS records of each member's domain names.
+func getMemberRecordKeys(
+	ctx context.Context, controller ztcontrollers.Controller, networkID string,
+	memberAddresses []string, c *ztc.Client, dc *desecc.Client,
+) (map[string][]desecc.RRsetKey, error) {
+	network, _, subnameRRsets, err := getNamedNetwork(ctx, controller, networkID, c, dc)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetMemberRecordKeys(
+		ctx, controller, *network, memberAddresses, subnameRRsets, c, dc,
+	)
+}
+
+// deleteMemberRecords deletes the DNS records of the members' domain names in a single batch.
+// The DNS records should be deleted before the members, so that the members are left intact if the
+// deletions are rejected (e.g. by the write rate limit for the DNS server).
+func deleteMemberRecords(
+	ctx context.Context, controller ztcontrollers.Controller, networkID string,
+	memberAddresses []string, c *ztc.Client, dc *desecc.Client,
+) error {
+	network, _, subnameRRsets, err := getNamedNetwork(ctx, controller, networkID, c, dc)
+	if err != nil {
+		return err
+	}
+	return client.DeleteMemberRecords(
+		ctx, controller, *network, memberAddresses, subnameRRsets, c, dc,
+	)
+}
+
+// deleteMember deletes the member from the network, along with any information stored about it.
+func deleteMember(
+	ctx context.Context, controller ztcontrollers.Controller, networkID, memberAddress string,
+	c *ztc.Client, mc *ztdevices.Client,
+) error {
+	if err := c.DeleteMember(ctx, controller, networkID, memberAddress); err != nil {
+		return errors.Wrapf(err, "couldn't delete network %s member %s", networkID, memberAddress)
+	}
+	if err := mc.DeleteDevice(ctx, networkID, memberAddress); err != nil {
+		return err
+	}
+	return mc.DeleteExpiration(ctx, networkID, memberAddress)
+}
+
+// cancelDeviceStreams stops publishing changes to the deleted members, since they can no longer be
+// looked up.
+func (h *Handlers) cancelDeviceStreams(networkID string, memberAddresses []string) {
+	for _, memberAddress := range memberAddresses {
+		h.tsh.Cancel("/networks/" + networkID + "/devices/" + memberAddress)
+	}
+}
+
+func (h *Handlers) HandleDevicePost() auth.HTTPHandlerFunc {
+	t := devicesListPartial
+	h.r.MustHave(t)
+	return func(c echo.Context, a auth.Auth) error {
+		// Parse params
+		networkID := c.Param("id")
+		controllerAddress := ztc.GetControllerAddress(networkID)
+		memberAddress := c.Param("address")
+		state := c.FormValue("state")
+
+		// Run queries
+		ctx := c.Request().Context()
+		switch state {
+		default:
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+				"invalid device state %s", state,
+			))
+		case "deleted":
+			controller, err := h.ztcc.FindControllerByAddress(ctx, controllerAddress)
+			if err != nil {
+				return errors.Wrapf(err, "couldn't find controller %s", controllerAddress)
+			}
+			if err = deleteMemberRecords(
+				ctx, *controller, networkID, []string{memberAddress}, h.ztc, h.dc,
+			); err != nil {
+				return err
+			}
+			if err = deleteMember(ctx, *controller, networkID, memberAddress, h.ztc, h.ztd); err != nil {
+				return err
+			}
+			h.cancelDeviceStreams(networkID, []string{memberAddress})
+		}
+
+		// Render Turbo Stream if accepted
+		if turbostreams.Accepted(c.Request().Header) {
+			message, err := replaceDevicesListStream(
+				ctx, controllerAddress, networkID, a, h.ztc, h.ztcc, h.dc, h.ztg, h.ztd,
+			)
+			if err != nil {
+				return errors.Wrapf(
+					err, "couldn't generate turbo streams update for network %s members list", networkID,
+				)
+			}
+			return h.r.TurboStream(c.Response(), message)
+		}
+
+		// Redirect user
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf(
+			"/networks/%s#/networks/%s/devices", networkID, networkID,
+		))
+	}
+}
@@ -0,0 +1,226 @@
+package networks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/sargassum-world/godest/turbostreams"
+	"go4.org/netipx"
+
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/auth"
+	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
+	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+// Device IP Address Assignment
+
+// ipReservationTTL is how long an IP address stays reserved after it's chosen for a device, which
+// should be long enough for the controller to report the address as assigned to the device.
+const ipReservationTTL = 5 * time.Minute
+
+// nextFreeIPAddress finds the lowest address of the IP version in the pools which isn't used.
+func nextFreeIPAddress(
+	pools []AssignmentPool, is4 bool, used map[netip.Addr]bool,
+) (next netip.Addr, ok bool) {
+	for _, pool := range pools {
+		if pool.Range.From().Is4() != is4 {
+			continue
+		}
+		for addr := pool.Range.From(); addr.IsValid() && addr.Compare(pool.Range.To()) <= 0; {
+			// The first address of a subnet identifies the subnet, and the last address of an IPv4
+			// subnet is its broadcast address, so neither should be assigned
+			isSubnetAddr := pool.ExactPrefix && addr == pool.Prefix.Masked().Addr()
+			isBroadcastAddr := pool.ExactPrefix && is4 && addr == netipx.PrefixLastIP(pool.Prefix)
+			if !used[addr] && !isSubnetAddr && !isBroadcastAddr {
+				if !next.IsValid() || addr.Less(next) {
+					next = addr
+				}
+				break
+			}
+			addr = addr.Next()
+		}
+	}
+	return next, next.IsValid()
+}
+
+// getUsedIPAddresses finds the IP addresses assigned to any of the network's members.
+func getUsedIPAddresses(
+	members map[string]zerotier.ControllerNetworkMember,
+) map[netip.Addr]bool {
+	used := make(map[netip.Addr]bool)
+	for _, member := range members {
+		if member.IpAssignments == nil {
+			continue
+		}
+		for _, rawAddr := range *member.IpAssignments {
+			addr, err := netip.ParseAddr(strings.TrimSpace(rawAddr))
+			if err != nil {
+				// Addresses which can't be parsed can't collide with the addresses we'd choose
+				continue
+			}
+			used[addr] = true
+		}
+	}
+	return used
+}
+
+func parseIPVersion(raw string) (is4 bool, err error) {
+	switch raw {
+	default:
+		return false, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"invalid ip version %s", raw,
+		))
+	case "4":
+		return true, nil
+	case "6":
+		return false, nil
+	}
+}
+
+// assignNextIPAddress assigns the lowest free address of the IP version from the network's
+// assignment pools and managed routes to the member, in addition to the member's current
+// addresses. If reserve is true, the chosen address is reserved until it's assigned, so that
+// concurrent assignments don't choose the same address.
+func (h *Handlers) assignNextIPAddress(
+	ctx context.Context, controller ztcontrollers.Controller, networkID, memberAddress string,
+	is4, reserve bool,
+) (ipAddress string, err error) {
+	network, err := h.ztc.GetNetwork(ctx, controller, networkID)
+	if err != nil {
+		return "", errors.Wrapf(err, "couldn't get network %s", networkID)
+	}
+	if network == nil {
+		return "", echo.NewHTTPError(http.StatusNotFound, "zerotier network not found")
+	}
+	pools, err := parseAssignmentPools(*network.Routes, *network.IpAssignmentPools)
+	if err != nil {
+		return "", err
+	}
+	memberAddresses, err := h.ztc.GetNetworkMemberAddresses(ctx, controller, networkID)
+	if err != nil {
+		return "", errors.Wrapf(err, "couldn't get network %s member addresses", networkID)
+	}
+	members, err := h.ztc.GetNetworkMembers(ctx, controller, networkID, memberAddresses)
+	if err != nil {
+		return "", errors.Wrapf(err, "couldn't get network %s members", networkID)
+	}
+	member, ok := members[memberAddress]
+	if !ok {
+		return "", echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf(
+			"network %s has no member %s", networkID, memberAddress,
+		))
+	}
+	used := getUsedIPAddresses(members)
+
+	choose := func(reserved map[string]bool) (string, error) {
+		for rawAddr := range reserved {
+			if addr, err := netip.ParseAddr(rawAddr); err == nil {
+				used[addr] = true
+			}
+		}
+		next, ok := nextFreeIPAddress(pools, is4, used)
+		if !ok {
+			version := "IPv6"
+			if is4 {
+				version = "IPv4"
+			}
+			return "", echo.NewHTTPError(http.StatusConflict, fmt.Sprintf(
+				"network %s has no free %s addresses in its assignment pools", networkID, version,
+			))
+		}
+		return next.String(), nil
+	}
+	if !reserve {
+		// Addresses reserved for other devices must still be avoided, even if the chosen address won't
+		// be reserved
+		reserved, err := h.ztd.GetReservedIPAddresses(ctx, networkID, time.Now())
+		if err != nil {
+			return "", err
+		}
+		if ipAddress, err = choose(reserved); err != nil {
+			return "", err
+		}
+	} else {
+		reservation, err := h.ztd.ReserveIPAddress(
+			ctx, networkID, memberAddress, time.Now().Add(ipReservationTTL), choose,
+		)
+		if err != nil {
+			return "", err
+		}
+		ipAddress = reservation.IPAddress
+	}
+
+	ipAddresses := make([]string, 0)
+	if member.IpAssignments != nil {
+		ipAddresses = append(ipAddresses, *member.IpAssignments...)
+	}
+	if err = setDeviceIPAddresses(
+		ctx, controller, networkID, memberAddress, append(ipAddresses, ipAddress), h.ztc,
+	); err != nil {
+		if reserve {
+			// The address should be available to other devices if it couldn't be assigned
+			if rerr := h.ztd.DeleteReservation(ctx, networkID, ipAddress); rerr != nil {
+				h.ztd.Logger.Error(rerr)
+			}
+		}
+		return "", err
+	}
+	return ipAddress, nil
+}
+
+func (h *Handlers) HandleDeviceIPNextPost() auth.HTTPHandlerFunc {
+	for _, partial := range devicePartials {
+		h.r.MustHave(partial)
+	}
+	return func(c echo.Context, a auth.Auth) error {
+		// Parse params
+		networkID := c.Param("id")
+		controllerAddress := ztc.GetControllerAddress(networkID)
+		memberAddress := c.Param("address")
+		is4, err := parseIPVersion(c.FormValue("ip-version"))
+		if err != nil {
+			return err
+		}
+		reserve := strings.ToLower(c.FormValue("reserve")) == checkboxTrueValue
+
+		// Run queries
+		ctx := c.Request().Context()
+		controller, err := h.ztcc.FindControllerByAddress(ctx, controllerAddress)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't find controller %s", controllerAddress)
+		}
+		if _, err = h.assignNextIPAddress(
+			ctx, *controller, networkID, memberAddress, is4, reserve,
+		); err != nil {
+			return errors.Wrapf(
+				err, "couldn't assign next ip address to network %s member %s", networkID, memberAddress,
+			)
+		}
+
+		// Render Turbo Stream if accepted
+		if turbostreams.Accepted(c.Request().Header) {
+			messages, err := replaceDeviceStream(
+				ctx, controllerAddress, networkID, memberAddress, a, h.ztc, h.ztcc, h.dc, h.ztg, h.ztd,
+			)
+			if err != nil {
+				return errors.Wrapf(
+					err, "couldn't generate turbo streams update for network %s member %s",
+					networkID, memberAddress,
+				)
+			}
+			return h.r.TurboStream(c.Response(), messages...)
+		}
+
+		// Redirect user
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf(
+			"/networks/%s#/networks/%s/devices/%s/ip", networkID, networkID, memberAddress,
+		))
+	}
+}
@@ -0,0 +1,59 @@
+package networks
+
+import (
+	"net/netip"
+	"testing"
+
+	"go4.org/netipx"
+)
+
+func TestNextFreeIPAddress(t *testing.T) {
+	prefixPool := func(prefix string) AssignmentPool {
+		p := netip.MustParsePrefix(prefix)
+		return AssignmentPool{Range: netipx.RangeOfPrefix(p), ExactPrefix: true, Prefix: p}
+	}
+	rangePool := AssignmentPool{Range: netipx.MustParseIPRange("10.0.1.0-10.0.1.1")}
+	used := func(addrs ...string) map[netip.Addr]bool {
+		result := make(map[netip.Addr]bool)
+		for _, addr := range addrs {
+			result[netip.MustParseAddr(addr)] = true
+		}
+		return result
+	}
+
+	testCases := []struct {
+		name  string
+		pools []AssignmentPool
+		is4   bool
+		used  map[netip.Addr]bool
+		want  string // empty if no address is free
+	}{
+		{"subnet address", []AssignmentPool{prefixPool("10.0.0.0/30")}, true, used(), "10.0.0.1"},
+		{
+			"broadcast address",
+			[]AssignmentPool{prefixPool("10.0.0.0/30")},
+			true,
+			used("10.0.0.1", "10.0.0.2"), "",
+		},
+		{"inexact range", []AssignmentPool{rangePool}, true, used("10.0.1.0"), "10.0.1.1"},
+		{
+			"ipv6 last address",
+			[]AssignmentPool{prefixPool("fd00::/127")},
+			false, used(),
+			"fd00::1",
+		},
+		{"other version", []AssignmentPool{prefixPool("fd00::/64")}, true, used(), ""},
+	}
+	for _, testCase := range testCases {
+		next, ok := nextFreeIPAddress(testCase.pools, testCase.is4, testCase.used)
+		if testCase.want == "" {
+			if ok {
+				t.Errorf("%s: got %s, want no free address", testCase.name, next)
+			}
+			continue
+		}
+		if !ok || next.String() != testCase.want {
+			t.Errorf("%s: got %s (%t), want %s", testCase.name, next, ok, testCase.want)
+		}
+	}
+}
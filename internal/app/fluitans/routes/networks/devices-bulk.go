@@ -0,0 +1,248 @@
+package networks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/sargassum-world/godest/turbostreams"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/auth"
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/client"
+	desecc "github.com/sargassum-world/fluitans/internal/clients/desec"
+	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
+	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+)
+
+// Bulk Device Operations
+
+type BulkDeviceAction string
+
+const (
+	BulkDeviceAuthorize   BulkDeviceAction = "authorize"
+	BulkDeviceDeauthorize BulkDeviceAction = "deauthorize"
+	BulkDeviceDelete      BulkDeviceAction = "delete"
+	BulkDeviceClearIPs    BulkDeviceAction = "clear-ips"
+)
+
+// Description describes the result of successfully applying the action to a device.
+func (a BulkDeviceAction) Description() string {
+	switch a {
+	default:
+		return string(a)
+	case BulkDeviceAuthorize:
+		return "Authorized"
+	case BulkDeviceDeauthorize:
+		return "Deauthorized"
+	case BulkDeviceDelete:
+		return "Deleted"
+	case BulkDeviceClearIPs:
+		return "Cleared IP addresses"
+	}
+}
+
+func parseBulkDeviceAction(rawAction string) (BulkDeviceAction, error) {
+	switch action := BulkDeviceAction(rawAction); action {
+	default:
+		return "", echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"invalid bulk device action %s", rawAction,
+		))
+	case BulkDeviceAuthorize, BulkDeviceDeauthorize, BulkDeviceDelete, BulkDeviceClearIPs:
+		return action, nil
+	}
+}
+
+// BulkDeviceResult is the outcome of applying a bulk action to one device.
+type BulkDeviceResult struct {
+	MemberAddress string
+	Err           error // nil if the action succeeded
+}
+
+// BulkDeviceSummary is the outcome of applying a bulk action to each of the selected devices.
+type BulkDeviceSummary struct {
+	Action  BulkDeviceAction
+	Results []BulkDeviceResult
+	// RecordsErr is the error from deleting the DNS records of deleted devices, whose records were
+	// left in place
+	RecordsErr error
+}
+
+func (s BulkDeviceSummary) Failures() []BulkDeviceResult {
+	failures := make([]BulkDeviceResult, 0, len(s.Results))
+	for _, result := range s.Results {
+		if result.Err != nil {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+func (s BulkDeviceSummary) Err() error {
+	failures := s.Failures()
+	if len(failures) == 0 {
+		return s.RecordsErr
+	}
+	messages := make([]string, len(failures))
+	for i, failure := range failures {
+		messages[i] = fmt.Sprintf("%s: %s", failure.MemberAddress, failure.Err)
+	}
+	if s.RecordsErr != nil {
+		messages = append(messages, s.RecordsErr.Error())
+	}
+	return errors.Errorf(
+		"couldn't %s %d of %d devices (%s)",
+		s.Action, len(failures), len(s.Results), strings.Join(messages, "; "),
+	)
+}
+
+// bulkDeviceConcurrency limits the number of concurrent requests to the controller for a bulk
+// action, so that large rollouts don't overwhelm the controller.
+const bulkDeviceConcurrency = 8
+
+// runBulkDeviceOperation applies the operation to each member, with bounded concurrency. Failures
+// for some members don't stop the operation from being applied to the other members.
+func runBulkDeviceOperation(
+	ctx context.Context, memberAddresses []string,
+	operation func(ctx context.Context, memberAddress string) error,
+) []BulkDeviceResult {
+	results := make([]BulkDeviceResult, len(memberAddresses))
+	eg := errgroup.Group{}
+	eg.SetLimit(bulkDeviceConcurrency)
+	for i, memberAddress := range memberAddresses {
+		eg.Go(func(i int, memberAddress string) func() error {
+			return func() error {
+				results[i] = BulkDeviceResult{
+					MemberAddress: memberAddress,
+					Err:           operation(ctx, memberAddress),
+				}
+				return nil
+			}
+		}(i, memberAddress))
+	}
+	_ = eg.Wait() // the operations' errors are reported in the results instead
+	return results
+}
+
+func (h *Handlers) applyBulkDeviceAction(
+	ctx context.Context, controller ztcontrollers.Controller, networkID string,
+	action BulkDeviceAction, memberAddresses []string,
+) (BulkDeviceSummary, error) {
+	var operation func(ctx context.Context, memberAddress string) error
+	var memberKeys map[string][]desecc.RRsetKey
+	switch action {
+	default:
+		return BulkDeviceSummary{}, errors.Errorf("unknown bulk device action %s", action)
+	case BulkDeviceAuthorize, BulkDeviceDeauthorize:
+		authorized := action == BulkDeviceAuthorize
+		operation = func(ctx context.Context, memberAddress string) error {
+			return setMemberAuthorization(
+				ctx, controller, networkID, memberAddress, authorized, time.Time{}, h.ztc, h.ztd,
+			)
+		}
+	case BulkDeviceClearIPs:
+		operation = func(ctx context.Context, memberAddress string) error {
+			return setDeviceIPAddresses(ctx, controller, networkID, memberAddress, []string{}, h.ztc)
+		}
+	case BulkDeviceDelete:
+		// The DNS records of the devices must be found before the devices are deleted, since they're
+		// identified by the devices' IP addresses
+		var err error
+		if memberKeys, err = getMemberRecordKeys(
+			ctx, controller, networkID, memberAddresses, h.ztc, h.dc,
+		); err != nil {
+			return BulkDeviceSummary{}, err
+		}
+		operation = func(ctx context.Context, memberAddress string) error {
+			return deleteMember(ctx, controller, networkID, memberAddress, h.ztc, h.ztd)
+		}
+	}
+
+	summary := BulkDeviceSummary{
+		Action:  action,
+		Results: runBulkDeviceOperation(ctx, memberAddresses, operation),
+	}
+	if action == BulkDeviceDelete {
+		deleted := make([]string, 0, len(summary.Results))
+		deletedKeys := make(map[string][]desecc.RRsetKey)
+		for _, result := range summary.Results {
+			if result.Err == nil {
+				deleted = append(deleted, result.MemberAddress)
+				deletedKeys[result.MemberAddress] = memberKeys[result.MemberAddress]
+			}
+		}
+		h.cancelDeviceStreams(networkID, deleted)
+		// The DNS records of the deleted devices are deleted together, since the DNS server limits
+		// the rate of writes; devices which couldn't be deleted keep their DNS records
+		summary.RecordsErr = client.DeleteRecordKeys(ctx, networkID, deletedKeys, h.dc)
+	}
+	return summary, nil
+}
+
+func (h *Handlers) HandleDevicesBulkPost() auth.HTTPHandlerFunc {
+	t := devicesListPartial
+	h.r.MustHave(t)
+	return func(c echo.Context, a auth.Auth) error {
+		// Parse params
+		networkID := c.Param("id")
+		controllerAddress := ztc.GetControllerAddress(networkID)
+		formParams, err := c.FormParams()
+		if err != nil {
+			return errors.Wrap(err, "couldn't parse form params")
+		}
+		memberAddresses := make([]string, 0, len(formParams["addresses"]))
+		for memberAddress := range client.NewStringSet(formParams["addresses"]) {
+			memberAddresses = append(memberAddresses, memberAddress)
+		}
+		sort.Strings(memberAddresses)
+		if len(memberAddresses) == 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "no devices were selected")
+		}
+		action, err := parseBulkDeviceAction(c.FormValue("action"))
+		if err != nil {
+			return err
+		}
+
+		// Run queries
+		ctx := c.Request().Context()
+		controller, err := h.ztcc.FindControllerByAddress(ctx, controllerAddress)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't find controller %s", controllerAddress)
+		}
+		summary, err := h.applyBulkDeviceAction(ctx, *controller, networkID, action, memberAddresses)
+		if err != nil {
+			return err
+		}
+
+		// Render Turbo Stream if accepted
+		if turbostreams.Accepted(c.Request().Header) {
+			networkViewData, err := getNetworkViewData(
+				ctx, controllerAddress, networkID, h.ztc, h.ztcc, h.dc, h.ztg, h.ztd,
+			)
+			if err != nil {
+				return errors.Wrapf(err, "couldn't get network %s data", networkID)
+			}
+			data := newDevicesListData(networkViewData, a)
+			data["BulkSummary"] = summary
+			return h.r.TurboStream(c.Response(), turbostreams.Message{
+				Action:   turbostreams.ActionReplace,
+				Target:   "/networks/" + networkID + "/devices",
+				Template: t,
+				Data:     data,
+			})
+		}
+
+		// Redirect user
+		if err = summary.Err(); err != nil {
+			return err
+		}
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf(
+			"/networks/%s#/networks/%s/devices", networkID, networkID,
+		))
+	}
+}
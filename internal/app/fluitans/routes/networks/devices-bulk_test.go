@@ -0,0 +1,86 @@
+package networks
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRunBulkDeviceOperation(t *testing.T) {
+	memberAddresses := []string{"0000000001", "0000000002", "0000000003"}
+	results := runBulkDeviceOperation(
+		context.Background(), memberAddresses,
+		func(ctx context.Context, memberAddress string) error {
+			if memberAddress == "0000000002" {
+				return errors.New("unreachable")
+			}
+			return nil
+		},
+	)
+	for i, result := range results {
+		if result.MemberAddress != memberAddresses[i] {
+			t.Errorf("result %d is for %s, want %s", i, result.MemberAddress, memberAddresses[i])
+		}
+		if failed := result.Err != nil; failed != (i == 1) {
+			t.Errorf("result for %s has error %v", result.MemberAddress, result.Err)
+		}
+	}
+}
+
+func TestBulkDeviceSummaryErr(t *testing.T) {
+	recordsErr := errors.New("rate limited")
+	testCases := []struct {
+		name    string
+		summary BulkDeviceSummary
+		want    []string // substrings of the error, or nil for no error
+	}{
+		{
+			"success",
+			BulkDeviceSummary{Action: BulkDeviceDelete, Results: []BulkDeviceResult{{"a", nil}}},
+			nil,
+		},
+		{
+			"failed device",
+			BulkDeviceSummary{
+				Action:  BulkDeviceDelete,
+				Results: []BulkDeviceResult{{"a", nil}, {"b", errors.New("unreachable")}},
+			},
+			[]string{"couldn't delete 1 of 2 devices", "b: unreachable"},
+		},
+		{
+			"records of deleted devices",
+			BulkDeviceSummary{
+				Action: BulkDeviceDelete, Results: []BulkDeviceResult{{"a", nil}}, RecordsErr: recordsErr,
+			},
+			[]string{"rate limited"},
+		},
+		{
+			"failed device and records",
+			BulkDeviceSummary{
+				Action:     BulkDeviceDelete,
+				Results:    []BulkDeviceResult{{"a", nil}, {"b", errors.New("unreachable")}},
+				RecordsErr: recordsErr,
+			},
+			[]string{"b: unreachable", "rate limited"},
+		},
+	}
+	for _, testCase := range testCases {
+		err := testCase.summary.Err()
+		if testCase.want == nil {
+			if err != nil {
+				t.Errorf("%s: got error %s", testCase.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: got no error", testCase.name)
+			continue
+		}
+		for _, want := range testCase.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: error %q doesn't contain %q", testCase.name, err, want)
+			}
+		}
+	}
+}
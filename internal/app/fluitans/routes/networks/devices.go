@@ -18,6 +18,8 @@ import (
 	desecc "github.com/sargassum-world/fluitans/internal/clients/desec"
 	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
 	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/internal/clients/ztdevices"
+	"github.com/sargassum-world/fluitans/internal/clients/zttags"
 	"github.com/sargassum-world/fluitans/pkg/desec"
 	"github.com/sargassum-world/fluitans/pkg/zerotier"
 )
@@ -28,9 +30,12 @@ const devicesListPartial = "networks/devices-list.partial.tmpl"
 
 func replaceDevicesListStream(
 	ctx context.Context, controllerAddress, networkID string, a auth.Auth,
-	c *ztc.Client, cc *ztcontrollers.Client, dc *desecc.Client,
+	c *ztc.Client, cc *ztcontrollers.Client, dc *desecc.Client, tc *zttags.Client,
+	mc *ztdevices.Client,
 ) (turbostreams.Message, error) {
-	networkViewData, err := getNetworkViewData(ctx, controllerAddress, networkID, c, cc, dc)
+	networkViewData, err := getNetworkViewData(
+		ctx, controllerAddress, networkID, c, cc, dc, tc, mc,
+	)
 	if err != nil {
 		return turbostreams.Message{}, errors.Wrapf(err, "couldn't get network %s data", networkID)
 	}
@@ -38,15 +43,21 @@ func replaceDevicesListStream(
 		Action:   turbostreams.ActionReplace,
 		Target:   "/networks/" + networkID + "/devices",
 		Template: devicesListPartial,
-		Data: map[string]interface{}{
-			"Members":    networkViewData.Members,
-			"Network":    networkViewData.Network,
-			"NetworkDNS": networkViewData.NetworkDNS,
-			"Auth":       a,
-		},
+		Data:     newDevicesListData(networkViewData, a),
 	}, nil
 }
 
+func newDevicesListData(networkViewData NetworkViewData, a auth.Auth) map[string]interface{} {
+	return map[string]interface{}{
+		"Members":             networkViewData.Members,
+		"Network":             networkViewData.Network,
+		"NetworkDNS":          networkViewData.NetworkDNS,
+		"NetworkCapabilities": networkViewData.Capabilities,
+		"NetworkTags":         networkViewData.Tags,
+		"Auth":                a,
+	}
+}
+
 func (h *Handlers) HandleDevicesSub() turbostreams.HandlerFunc {
 	return func(c *turbostreams.Context) error {
 		// Parse params
@@ -133,7 +144,7 @@ func (h *Handlers) HandleDevicesPub() turbostreams.HandlerFunc {
 			// whether there's at least one device in the network, and this is the simplest solution which
 			// handles all edge cases.
 			message, err := replaceDevicesListStream(
-				c.Context(), controllerAddress, networkID, auth.Auth{}, h.ztc, h.ztcc, h.dc,
+				c.Context(), controllerAddress, networkID, auth.Auth{}, h.ztc, h.ztcc, h.dc, h.ztg, h.ztd,
 			)
 			if err != nil {
 				return false, errors.Wrapf(
@@ -162,7 +173,7 @@ func (h *Handlers) HandleDevicesPost() auth.HTTPHandlerFunc {
 			return errors.Wrapf(err, "couldn't find controller %s", controllerAddress)
 		}
 		if err = setMemberAuthorization(
-			ctx, *controller, networkID, memberAddress, true, h.ztc,
+			ctx, *controller, networkID, memberAddress, true, time.Time{}, h.ztc, h.ztd,
 		); err != nil {
 			return errors.Wrapf(
 				err, "couldn't authorize network %s member %s", networkID, memberAddress,
@@ -175,7 +186,7 @@ func (h *Handlers) HandleDevicesPost() auth.HTTPHandlerFunc {
 			// whether there's at least one device in the network, and this is the simplest solution which
 			// handles all edge cases.
 			message, err := replaceDevicesListStream(
-				c.Request().Context(), controllerAddress, networkID, a, h.ztc, h.ztcc, h.dc,
+				c.Request().Context(), controllerAddress, networkID, a, h.ztc, h.ztcc, h.dc, h.ztg, h.ztd,
 			)
 			if err != nil {
 				return errors.Wrapf(
@@ -195,14 +206,17 @@ func (h *Handlers) HandleDevicesPost() auth.HTTPHandlerFunc {
 // Device
 
 type DeviceViewData struct {
-	Member          client.Member
-	Network         zerotier.ControllerNetwork
-	NetworkDNSNamed bool
+	Member              client.Member
+	Network             zerotier.ControllerNetwork
+	NetworkDNSNamed     bool
+	NetworkCapabilities []zerotier.Capability
+	NetworkTags         []NetworkTag
 }
 
 func getDeviceViewData(
 	ctx context.Context, controllerAddress, networkID, memberAddress string,
-	c *ztc.Client, cc *ztcontrollers.Client, dc *desecc.Client,
+	c *ztc.Client, cc *ztcontrollers.Client, dc *desecc.Client, tc *zttags.Client,
+	mc *ztdevices.Client,
 ) (vd DeviceViewData, err error) {
 	controller, err := cc.FindControllerByAddress(ctx, controllerAddress)
 	if err != nil {
@@ -233,6 +247,12 @@ func getDeviceViewData(
 	vd.NetworkDNSNamed = client.NetworkNamedByDNS(
 		networkID, *network.Name, dc.Config.DomainName, subnameRRsets,
 	)
+	if vd.NetworkCapabilities, err = getNetworkCapabilities(*network); err != nil {
+		return DeviceViewData{}, err
+	}
+	if vd.NetworkTags, err = getNetworkTags(ctx, *network, tc); err != nil {
+		return DeviceViewData{}, err
+	}
 
 	members, err := client.GetMemberRecords(
 		ctx, dc.Config.DomainName, *controller, *network, []string{memberAddress}, subnameRRsets, c,
@@ -242,6 +262,10 @@ func getDeviceViewData(
 			err, "couldn't get network %s member %s records", networkID, memberAddress,
 		)
 	}
+	client.AddMemberPeers(ctx, *controller, members, c)
+	if err = client.AddMemberMetadata(ctx, networkID, members, mc); err != nil {
+		return DeviceViewData{}, err
+	}
 	var ok bool
 	if vd.Member, ok = members[memberAddress]; !ok {
 		return DeviceViewData{}, echo.NewHTTPError(
@@ -253,25 +277,28 @@ func getDeviceViewData(
 }
 
 const (
-	deviceHeaderPartial   = "networks/device-header.partial.tmpl"
-	deviceBasicsPartial   = "networks/device-basics.partial.tmpl"
-	deviceIPPartial       = "networks/device-ip.partial.tmpl"
-	deviceAdvancedPartial = "networks/device-advanced.partial.tmpl"
+	deviceHeaderPartial       = "networks/device-header.partial.tmpl"
+	deviceBasicsPartial       = "networks/device-basics.partial.tmpl"
+	deviceIPPartial           = "networks/device-ip.partial.tmpl"
+	deviceCapabilitiesPartial = "networks/device-capabilities.partial.tmpl"
+	deviceAdvancedPartial     = "networks/device-advanced.partial.tmpl"
 )
 
-var devicePartials [4]string = [4]string{
+var devicePartials [5]string = [5]string{
 	deviceHeaderPartial,
 	deviceBasicsPartial,
 	deviceIPPartial,
+	deviceCapabilitiesPartial,
 	deviceAdvancedPartial,
 }
 
 func replaceDeviceStream(
 	ctx context.Context, controllerAddress, networkID, memberAddress string, a auth.Auth,
-	c *ztc.Client, cc *ztcontrollers.Client, dc *desecc.Client,
+	c *ztc.Client, cc *ztcontrollers.Client, dc *desecc.Client, tc *zttags.Client,
+	mc *ztdevices.Client,
 ) ([]turbostreams.Message, error) {
 	deviceViewData, err := getDeviceViewData(
-		ctx, controllerAddress, networkID, memberAddress, c, cc, dc,
+		ctx, controllerAddress, networkID, memberAddress, c, cc, dc, tc, mc,
 	)
 	if err != nil {
 		return nil, errors.Wrapf(
@@ -279,10 +306,12 @@ func replaceDeviceStream(
 		)
 	}
 	data := map[string]interface{}{
-		"Member":          deviceViewData.Member,
-		"Network":         deviceViewData.Network,
-		"NetworkDNSNamed": deviceViewData.NetworkDNSNamed,
-		"Auth":            a,
+		"Member":              deviceViewData.Member,
+		"Network":             deviceViewData.Network,
+		"NetworkDNSNamed":     deviceViewData.NetworkDNSNamed,
+		"NetworkCapabilities": deviceViewData.NetworkCapabilities,
+		"NetworkTags":         deviceViewData.NetworkTags,
+		"Auth":                a,
 	}
 	return []turbostreams.Message{
 		{
@@ -303,6 +332,12 @@ func replaceDeviceStream(
 			Template: deviceIPPartial,
 			Data:     data,
 		},
+		{
+			Action:   turbostreams.ActionReplace,
+			Target:   "/networks/" + networkID + "/devices/" + memberAddress + "/capabilities",
+			Template: deviceCapabilitiesPartial,
+			Data:     data,
+		},
 		{
 			Action:   turbostreams.ActionReplace,
 			Target:   "/networks/" + networkID + "/devices/" + memberAddress + "/advanced",
@@ -343,11 +378,13 @@ type deviceChangeState struct {
 	Device      zerotier.ControllerNetworkMember
 	DomainNames client.StringSet
 	DNSUpdates  client.StringSet
+	Connection  string
+	Metadata    ztdevices.Device
 }
 
 func (s *deviceChangeState) Update(
 	ctx context.Context, controller ztcontrollers.Controller, networkID, memberAddress string,
-	c *ztc.Client, dc *desecc.Client,
+	c *ztc.Client, dc *desecc.Client, mc *ztdevices.Client,
 ) (changed bool, err error) {
 	// Network
 	network, err := c.GetNetwork(ctx, controller, networkID)
@@ -374,6 +411,10 @@ func (s *deviceChangeState) Update(
 			err, "couldn't get network %s member %s records", networkID, memberAddress,
 		)
 	}
+	client.AddMemberPeers(ctx, controller, members, c)
+	if err = client.AddMemberMetadata(ctx, networkID, members, mc); err != nil {
+		return false, err
+	}
 	member := members[memberAddress]
 	deviceChanged := s.Device.Revision == nil || *s.Device.Revision != *member.ZerotierMember.Revision
 	s.Device = member.ZerotierMember
@@ -394,7 +435,19 @@ func (s *deviceChangeState) Update(
 	dnsUpdatesChanged := !updatedDNSUpdates.Equals(s.DNSUpdates)
 	s.DNSUpdates = updatedDNSUpdates
 
-	return deviceChanged || networkChanged || domainNamesChanged || dnsUpdatesChanged, nil
+	// Peer Connection
+	// We only track whether the connection is direct or relayed, since latencies change too often to
+	// be worth publishing
+	updatedConnection := ztc.DescribePeerConnection(member.Peer)
+	connectionChanged := updatedConnection != s.Connection
+	s.Connection = updatedConnection
+
+	// Metadata
+	metadataChanged := member.Metadata != s.Metadata
+	s.Metadata = member.Metadata
+
+	return deviceChanged || networkChanged || domainNamesChanged || dnsUpdatesChanged ||
+		connectionChanged || metadataChanged, nil
 }
 
 func (h *Handlers) HandleDevicePub() turbostreams.HandlerFunc {
@@ -422,7 +475,9 @@ func (h *Handlers) HandleDevicePub() turbostreams.HandlerFunc {
 		const pubInterval = 5 * time.Second
 		return handling.RepeatImmediate(ctx, pubInterval, func() (done bool, err error) {
 			// Check for changes
-			changed, err := state.Update(ctx, *controller, networkID, memberAddress, h.ztc, h.dc)
+			changed, err := state.Update(
+				ctx, *controller, networkID, memberAddress, h.ztc, h.dc, h.ztd,
+			)
 			if err != nil {
 				return false, errors.Wrapf(
 					err, "couldn't update state while tracking changes to network %s member %s",
@@ -442,7 +497,8 @@ func (h *Handlers) HandleDevicePub() turbostreams.HandlerFunc {
 
 			// Publish changes
 			messages, err := replaceDeviceStream(
-				ctx, controllerAddress, networkID, memberAddress, auth.Auth{}, h.ztc, h.ztcc, h.dc,
+				ctx, controllerAddress, networkID, memberAddress, auth.Auth{},
+				h.ztc, h.ztcc, h.dc, h.ztg, h.ztd,
 			)
 			if err != nil {
 				return false, errors.Wrapf(
@@ -458,10 +514,39 @@ func (h *Handlers) HandleDevicePub() turbostreams.HandlerFunc {
 
 // Device Authorization
 
+// parseAuthorizationDuration parses how long a member should stay authorized; a zero duration
+// means the authorization is permanent.
+func parseAuthorizationDuration(raw string) (time.Duration, error) {
+	if raw = strings.TrimSpace(raw); raw == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(raw)
+	if err != nil || duration <= 0 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"invalid authorization duration %s", raw,
+		))
+	}
+	return duration, nil
+}
+
+// setMemberAuthorization authorizes or deauthorizes the member. An authorization expires at the
+// expiration time, unless the expiration time is zero, in which case the authorization is
+// permanent.
 func setMemberAuthorization(
 	ctx context.Context, controller ztcontrollers.Controller, networkID, memberAddress string,
-	authorized bool, c *ztc.Client,
+	authorized bool, expiration time.Time, c *ztc.Client, mc *ztdevices.Client,
 ) error {
+	if authorized && !expiration.IsZero() {
+		// The expiration must be stored first, so that the member can't become authorized permanently
+		// by accident
+		if err := mc.SetExpiration(ctx, ztdevices.Expiration{
+			NetworkID:     networkID,
+			MemberAddress: memberAddress,
+			Time:          expiration,
+		}); err != nil {
+			return err
+		}
+	}
 	auth := authorized
 	if err := c.UpdateMember(
 		ctx, controller, networkID, memberAddress,
@@ -473,6 +558,9 @@ func setMemberAuthorization(
 		// We might've added a new network member, so we should invalidate the cache
 		c.Cache.UnsetNetworkMembersByID(networkID)
 	}
+	if !authorized || expiration.IsZero() {
+		return mc.DeleteExpiration(ctx, networkID, memberAddress)
+	}
 	return nil
 }
 
@@ -485,6 +573,14 @@ func (h *Handlers) HandleDeviceAuthorizationPost() auth.HTTPHandlerFunc {
 		controllerAddress := ztc.GetControllerAddress(networkID)
 		memberAddress := c.Param("address")
 		authorization := strings.ToLower(c.FormValue("authorization")) == checkboxTrueValue
+		duration, err := parseAuthorizationDuration(c.FormValue("duration"))
+		if err != nil {
+			return err
+		}
+		var expiration time.Time
+		if authorization && duration > 0 {
+			expiration = time.Now().Add(duration)
+		}
 
 		// Run queries
 		ctx := c.Request().Context()
@@ -493,7 +589,7 @@ func (h *Handlers) HandleDeviceAuthorizationPost() auth.HTTPHandlerFunc {
 			return errors.Wrapf(err, "couldn't find controller %s", controllerAddress)
 		}
 		if err = setMemberAuthorization(
-			ctx, *controller, networkID, memberAddress, authorization, h.ztc,
+			ctx, *controller, networkID, memberAddress, authorization, expiration, h.ztc, h.ztd,
 		); err != nil {
 			return errors.Wrapf(
 				err, "couldn't update authorization on network %s for member %s", networkID, memberAddress,
@@ -507,7 +603,8 @@ func (h *Handlers) HandleDeviceAuthorizationPost() auth.HTTPHandlerFunc {
 			// complexity to try to only look up the data for this device in order to send a smaller
 			// HTTP response payload.
 			messages, err := replaceDeviceStream(
-				c.Request().Context(), controllerAddress, networkID, memberAddress, a, h.ztc, h.ztcc, h.dc,
+				c.Request().Context(), controllerAddress, networkID, memberAddress, a,
+				h.ztc, h.ztcc, h.dc, h.ztg, h.ztd,
 			)
 			if err != nil {
 				return errors.Wrapf(
@@ -687,7 +784,8 @@ func (h *Handlers) HandleDeviceNamePost() auth.HTTPHandlerFunc {
 			// complexity to try to only look up the data for this device in order to send a smaller
 			// HTTP response payload.
 			messages, err := replaceDeviceStream(
-				c.Request().Context(), controllerAddress, networkID, memberAddress, a, h.ztc, h.ztcc, h.dc,
+				c.Request().Context(), controllerAddress, networkID, memberAddress, a,
+				h.ztc, h.ztcc, h.dc, h.ztg, h.ztd,
 			)
 			if err != nil {
 				return errors.Wrapf(
@@ -705,6 +803,69 @@ func (h *Handlers) HandleDeviceNamePost() auth.HTTPHandlerFunc {
 	}
 }
 
+// Device Metadata
+
+func parseDeviceMetadata(c echo.Context, networkID, memberAddress string) ztdevices.Device {
+	return ztdevices.Device{
+		NetworkID:     networkID,
+		MemberAddress: memberAddress,
+		DisplayName:   strings.TrimSpace(c.FormValue("display-name")),
+		Description:   strings.TrimSpace(c.FormValue("description")),
+		Owner:         strings.TrimSpace(c.FormValue("owner")),
+		AssetTag:      strings.TrimSpace(c.FormValue("asset-tag")),
+		Notes:         strings.TrimSpace(c.FormValue("notes")),
+	}
+}
+
+func (h *Handlers) HandleDeviceMetadataPost() auth.HTTPHandlerFunc {
+	for _, partial := range devicePartials {
+		h.r.MustHave(partial)
+	}
+	return func(c echo.Context, a auth.Auth) error {
+		// Parse params
+		networkID := c.Param("id")
+		controllerAddress := ztc.GetControllerAddress(networkID)
+		memberAddress := c.Param("address")
+		device := parseDeviceMetadata(c, networkID, memberAddress)
+
+		// Run queries
+		ctx := c.Request().Context()
+		controller, err := h.ztcc.FindControllerByAddress(ctx, controllerAddress)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't find controller %s", controllerAddress)
+		}
+		member, err := h.ztc.GetNetworkMember(ctx, *controller, networkID, memberAddress)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't get network %s member %s", networkID, memberAddress)
+		}
+		if member == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "zerotier network member not found")
+		}
+		if err = h.ztd.SetDevice(ctx, device); err != nil {
+			return err
+		}
+
+		// Render Turbo Stream if accepted
+		if turbostreams.Accepted(c.Request().Header) {
+			messages, err := replaceDeviceStream(
+				ctx, controllerAddress, networkID, memberAddress, a, h.ztc, h.ztcc, h.dc, h.ztg, h.ztd,
+			)
+			if err != nil {
+				return errors.Wrapf(
+					err, "couldn't generate turbo streams update for network %s member %s",
+					networkID, memberAddress,
+				)
+			}
+			return h.r.TurboStream(c.Response(), messages...)
+		}
+
+		// Redirect user
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf(
+			"/networks/%s#/networks/%s/devices/%s", networkID, networkID, memberAddress,
+		))
+	}
+}
+
 // Device IP Addresses
 
 func setDeviceIPAddresses(
@@ -760,7 +921,7 @@ func (h *Handlers) HandleDeviceIPPost() auth.HTTPHandlerFunc {
 		if turbostreams.Accepted(c.Request().Header) {
 			// TODO: also broadcast this message over Turbo Streams, and have web browsers subscribe to it
 			messages, err := replaceDeviceStream(
-				ctx, controllerAddress, networkID, memberAddress, a, h.ztc, h.ztcc, h.dc,
+				ctx, controllerAddress, networkID, memberAddress, a, h.ztc, h.ztcc, h.dc, h.ztg, h.ztd,
 			)
 			if err != nil {
 				return errors.Wrapf(
@@ -777,3 +938,138 @@ func (h *Handlers) HandleDeviceIPPost() auth.HTTPHandlerFunc {
 		))
 	}
 }
+
+// Device Capabilities and Tags
+
+func parseDeviceCapabilities(
+	rawIDs []string, networkCapabilities []zerotier.Capability,
+) ([]int, error) {
+	ids, err := parseTagNumbers(rawIDs, "capability id")
+	if err != nil {
+		return nil, err
+	}
+	defined := make(map[uint64]bool, len(networkCapabilities))
+	for _, capability := range networkCapabilities {
+		defined[capability.ID] = true
+	}
+	capabilities := make([]int, len(ids))
+	for i, id := range ids {
+		if !defined[id] {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+				"network has no capability with id %d", id,
+			))
+		}
+		capabilities[i] = int(id)
+	}
+	return capabilities, nil
+}
+
+// parseDeviceTags parses the value of each of the network's tags from the form field named after
+// the tag's id. The value can be a number or the name of one of the tag's enums, and the tag is
+// left unset if the value is empty.
+func parseDeviceTags(c echo.Context, networkTags []NetworkTag) ([][]int, error) {
+	tags := make([][]int, 0, len(networkTags))
+	for _, tag := range networkTags {
+		rawValue := strings.TrimSpace(c.FormValue(fmt.Sprintf("tag-%d", tag.Tag.ID)))
+		if rawValue == "" {
+			continue
+		}
+		if enum, ok := tag.findEnum(rawValue); ok {
+			tags = append(tags, []int{int(tag.Tag.ID), int(enum.Value)})
+			continue
+		}
+		value, err := parseTagNumber(rawValue, fmt.Sprintf("value of tag %d", tag.Tag.ID))
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, []int{int(tag.Tag.ID), int(value)})
+	}
+	return tags, nil
+}
+
+func setDeviceCapabilities(
+	ctx context.Context, controller ztcontrollers.Controller, networkID, memberAddress string,
+	capabilities []int, tags [][]int, c *ztc.Client,
+) error {
+	if err := c.UpdateMember(
+		ctx, controller, networkID, memberAddress,
+		zerotier.SetControllerNetworkMemberJSONRequestBody{Capabilities: &capabilities, Tags: &tags},
+	); err != nil {
+		return errors.Wrapf(err, "couldn't update network %s member %s", networkID, memberAddress)
+	}
+	return nil
+}
+
+func (h *Handlers) HandleDeviceCapabilitiesPost() auth.HTTPHandlerFunc {
+	for _, partial := range devicePartials {
+		h.r.MustHave(partial)
+	}
+	return func(c echo.Context, a auth.Auth) error {
+		// Parse params
+		networkID := c.Param("id")
+		controllerAddress := ztc.GetControllerAddress(networkID)
+		memberAddress := c.Param("address")
+		formParams, err := c.FormParams()
+		if err != nil {
+			return errors.Wrap(err, "couldn't parse form params")
+		}
+
+		// Run queries
+		ctx := c.Request().Context()
+		controller, err := h.ztcc.FindControllerByAddress(ctx, controllerAddress)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't find controller %s", controllerAddress)
+		}
+		network, err := h.ztc.GetNetwork(ctx, *controller, networkID)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't get network %s", networkID)
+		}
+		if network == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "zerotier network not found")
+		}
+		networkCapabilities, err := getNetworkCapabilities(*network)
+		if err != nil {
+			return err
+		}
+		capabilities, err := parseDeviceCapabilities(formParams["capabilities"], networkCapabilities)
+		if err != nil {
+			return err
+		}
+		networkTags, err := getNetworkTags(ctx, *network, h.ztg)
+		if err != nil {
+			return err
+		}
+		tags, err := parseDeviceTags(c, networkTags)
+		if err != nil {
+			return err
+		}
+		if err = setDeviceCapabilities(
+			ctx, *controller, networkID, memberAddress, capabilities, tags, h.ztc,
+		); err != nil {
+			return errors.Wrapf(
+				err, "couldn't set capabilities and tags of network %s member %s",
+				networkID, memberAddress,
+			)
+		}
+
+		// Render Turbo Stream if accepted
+		if turbostreams.Accepted(c.Request().Header) {
+			// TODO: also broadcast this message over Turbo Streams, and have web browsers subscribe to it
+			messages, err := replaceDeviceStream(
+				ctx, controllerAddress, networkID, memberAddress, a, h.ztc, h.ztcc, h.dc, h.ztg, h.ztd,
+			)
+			if err != nil {
+				return errors.Wrapf(
+					err, "couldn't generate turbo streams update for network %s member %s",
+					networkID, memberAddress,
+				)
+			}
+			return h.r.TurboStream(c.Response(), messages...)
+		}
+
+		// Redirect user
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf(
+			"/networks/%s#/networks/%s/devices/%s/capabilities", networkID, networkID, memberAddress,
+		))
+	}
+}
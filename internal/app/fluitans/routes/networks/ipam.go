@@ -0,0 +1,181 @@
+package networks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go4.org/netipx"
+
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/auth"
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/client"
+	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
+	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+// IP Address Allocation
+
+const (
+	defaultIPv4AllocationBits = 24
+	defaultIPv6AllocationBits = 64
+)
+
+// parseAllocationRequest parses a request to allocate the next free prefix with the specified
+// length from the supernet. If no supernet was specified, no allocation was requested.
+func parseAllocationRequest(
+	rawSupernet, rawBits string,
+) (supernet netip.Prefix, bits int, requested bool, err error) {
+	rawSupernet = strings.TrimSpace(rawSupernet)
+	if rawSupernet == "" {
+		return netip.Prefix{}, 0, false, nil
+	}
+	if supernet, err = netip.ParsePrefix(rawSupernet); err != nil {
+		return netip.Prefix{}, 0, false, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"invalid supernet %s", rawSupernet,
+		))
+	}
+	supernet = supernet.Masked()
+
+	if rawBits = strings.TrimPrefix(strings.TrimSpace(rawBits), "/"); rawBits == "" {
+		bits = defaultIPv6AllocationBits
+		if supernet.Addr().Is4() {
+			bits = defaultIPv4AllocationBits
+		}
+		return supernet, bits, true, nil
+	}
+	if bits, err = strconv.Atoi(rawBits); err != nil {
+		return netip.Prefix{}, 0, false, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"invalid prefix length %s", rawBits,
+		))
+	}
+	return supernet, bits, true, nil
+}
+
+// allocatePrefix finds the first prefix in the supernet which isn't used by any network of any
+// controller.
+func allocatePrefix(
+	ctx context.Context, supernet netip.Prefix, bits int, c *ztc.Client, cc *ztcontrollers.Client,
+) (netip.Prefix, error) {
+	controllers, err := cc.GetControllers(ctx)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	allocations, err := client.GetIPAllocations(ctx, controllers, c, cc)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	prefix, err := client.NextFreePrefix(supernet, bits, allocations)
+	if err != nil {
+		return netip.Prefix{}, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return prefix, nil
+}
+
+// addNetworkSubnet adds a managed route for the prefix to the network, and automatically assigns
+// IP addresses to devices from the prefix.
+func addNetworkSubnet(
+	ctx context.Context, controller ztcontrollers.Controller,
+	network zerotier.ControllerNetwork, prefix netip.Prefix, c *ztc.Client,
+) (*zerotier.ControllerNetwork, error) {
+	routes := make([]zerotier.Route, 0)
+	if network.Routes != nil {
+		routes = append(routes, *network.Routes...)
+	}
+	target := prefix.String()
+	routes = append(routes, zerotier.Route{Target: &target})
+	pools := make([]zerotier.IpAssignmentPool, 0)
+	if network.IpAssignmentPools != nil {
+		pools = append(pools, *network.IpAssignmentPools...)
+	}
+	poolRange := sanitizeAssignmentPoolRange(netipx.RangeOfPrefix(prefix))
+	rangeStart := poolRange.From().String()
+	rangeEnd := poolRange.To().String()
+	pools = append(pools, zerotier.IpAssignmentPool{IpRangeStart: &rangeStart, IpRangeEnd: &rangeEnd})
+
+	autoAssign := true
+	body := zerotier.SetControllerNetworkJSONRequestBody{Routes: &routes, IpAssignmentPools: &pools}
+	if prefix.Addr().Is4() {
+		body.V4AssignMode = &zerotier.V4AssignMode{Zt: &autoAssign}
+	} else {
+		body.V6AssignMode = &zerotier.V6AssignMode{Zt: &autoAssign}
+	}
+	return c.UpdateNetwork(ctx, controller, *network.Id, body)
+}
+
+// IP Address Management
+
+type IPAMViewData struct {
+	Controllers          []ztcontrollers.Controller
+	Allocations          []client.IPAllocation
+	Overlaps             []client.IPOverlap
+	DuplicateAssignments []client.DuplicateIPAssignment
+	Supernet             string
+	PrefixLength         string
+	NextFreePrefix       netip.Prefix
+}
+
+func getIPAMViewData(
+	ctx context.Context, rawSupernet, rawBits string, c *ztc.Client, cc *ztcontrollers.Client,
+) (vd IPAMViewData, err error) {
+	vd.Supernet = rawSupernet
+	vd.PrefixLength = rawBits
+	supernet, bits, allocationRequested, err := parseAllocationRequest(rawSupernet, rawBits)
+	if err != nil {
+		return IPAMViewData{}, err
+	}
+
+	if vd.Controllers, err = cc.GetControllers(ctx); err != nil {
+		return IPAMViewData{}, err
+	}
+	networkIDs, err := c.GetAllNetworkIDs(ctx, vd.Controllers, cc)
+	if err != nil {
+		return IPAMViewData{}, err
+	}
+	networks, err := c.GetAllNetworks(ctx, vd.Controllers, networkIDs)
+	if err != nil {
+		return IPAMViewData{}, err
+	}
+	if vd.Allocations, err = client.ListIPAllocations(
+		vd.Controllers, networkIDs, networks,
+	); err != nil {
+		return IPAMViewData{}, err
+	}
+	vd.Overlaps = client.FindIPOverlaps(vd.Allocations)
+	if vd.DuplicateAssignments, err = client.FindDuplicateIPAssignments(
+		ctx, vd.Controllers, networkIDs, c,
+	); err != nil {
+		return IPAMViewData{}, err
+	}
+
+	if allocationRequested {
+		if vd.NextFreePrefix, err = client.NextFreePrefix(
+			supernet, bits, vd.Allocations,
+		); err != nil {
+			return IPAMViewData{}, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+	return vd, nil
+}
+
+func (h *Handlers) HandleIPAMGet() auth.HTTPHandlerFunc {
+	t := "networks/ipam.page.tmpl"
+	h.r.MustHave(t)
+	return func(c echo.Context, a auth.Auth) error {
+		// Run queries
+		ipamViewData, err := getIPAMViewData(
+			c.Request().Context(), c.QueryParam("supernet"), c.QueryParam("prefix-length"),
+			h.ztc, h.ztcc,
+		)
+		if err != nil {
+			return err
+		}
+
+		// Produce output
+		return h.r.CacheablePage(c.Response(), c.Request(), t, ipamViewData, a)
+	}
+}
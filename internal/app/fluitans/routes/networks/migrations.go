@@ -0,0 +1,267 @@
+package networks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/auth"
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/client"
+	desecc "github.com/sargassum-world/fluitans/internal/clients/desec"
+	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
+	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/pkg/desec"
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+// Migration Execution
+
+// newMovedNetworkIDRRsets makes TXT rrsets which replace every zerotier-net-id record naming the
+// source network (including records for the network's aliases) with records naming the target
+// network.
+func newMovedNetworkIDRRsets(
+	sourceID, targetID string, subnameRRsets map[string][]desec.RRset,
+) []desec.RRset {
+	subnames := make([]string, 0)
+	for subname, rrsets := range subnameRRsets {
+		for _, rrset := range rrsets {
+			if rrset.Type != "TXT" {
+				continue
+			}
+			if id, hasID := client.GetNetworkID(rrset.Records); hasID && id == sourceID {
+				subnames = append(subnames, subname)
+			}
+		}
+	}
+	sort.Strings(subnames)
+
+	moved := make([]desec.RRset, 0, len(subnames))
+	for _, subname := range subnames {
+		for _, rrset := range subnameRRsets[subname] {
+			if rrset.Type != "TXT" {
+				continue
+			}
+			records := make([]string, len(rrset.Records))
+			for i, record := range rrset.Records {
+				records[i] = record
+				if id, isIDRecord := client.ParseNetworkIDRecord(record); isIDRecord && id == sourceID {
+					records[i] = client.MakeNetworkIDRecord(targetID)
+				}
+			}
+			rrset.Records = records
+			moved = append(moved, rrset)
+		}
+	}
+	return moved
+}
+
+func migrateNetwork(
+	ctx context.Context, source ztcontrollers.Controller, id string,
+	target ztcontrollers.Controller, c *ztc.Client, cc *ztcontrollers.Client, dc *desecc.Client,
+) (*zerotier.ControllerNetwork, error) {
+	eg, egctx := errgroup.WithContext(ctx)
+	var network *zerotier.ControllerNetwork
+	var subnameRRsets map[string][]desec.RRset
+	eg.Go(func() (err error) {
+		network, err = c.GetNetwork(egctx, source, id)
+		return err
+	})
+	eg.Go(func() (err error) {
+		subnameRRsets, err = dc.GetRRsets(egctx)
+		return err
+	})
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+	if network == nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "zerotier network not found")
+	}
+	backup, err := client.NewNetworkBackup(
+		ctx, dc.Config.DomainName, source, *network, subnameRRsets, c,
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't describe network %s", id)
+	}
+
+	// Recreate the network and its members on the target controller
+	migrated, err := c.CreateNetworkWithConfig(ctx, target, backup.NewNetworkRequest(backup.Name), cc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't create network on controller %s", target.Name)
+	}
+	if migrated == nil || migrated.Id == nil {
+		return nil, errors.Errorf("status of network created on controller %s is unknown", target.Name)
+	}
+	members := make([]MemberImportPlan, len(backup.Members))
+	for i, member := range backup.Members {
+		members[i], _ = planMemberImport(member, backup.DNSName, dc.Config.DomainName, subnameRRsets)
+	}
+	if _, err = importNetworkMembers(ctx, target, *migrated.Id, members, c); err != nil {
+		return nil, err
+	}
+
+	// Point the network's DNS records at the new network
+	rrsets := newMovedNetworkIDRRsets(id, *migrated.Id, subnameRRsets)
+	memberRRsets, err := newImportedMemberRRsets(*migrated, members, int(c.Config.DNS.DeviceTTL))
+	if err != nil {
+		return nil, err
+	}
+	rrsets = append(rrsets, memberRRsets...)
+	if len(rrsets) == 0 {
+		return migrated, nil
+	}
+	if _, err = dc.UpsertRRsets(ctx, rrsets...); err != nil {
+		return nil, errors.Wrapf(
+			err, "couldn't move dns records from network %s to network %s", id, *migrated.Id,
+		)
+	}
+	return migrated, nil
+}
+
+func (h *Handlers) HandleNetworkMigrationPost() auth.HTTPHandlerFunc {
+	return func(c echo.Context, a auth.Auth) error {
+		// Parse params
+		id := c.Param("id")
+		address := ztc.GetControllerAddress(id)
+		name := c.FormValue("controller")
+		if name == "" {
+			return echo.NewHTTPError(
+				http.StatusBadRequest, "zerotier controller name not specified",
+			)
+		}
+
+		// Run queries
+		ctx := c.Request().Context()
+		source, err := h.ztcc.FindControllerByAddress(ctx, address)
+		if err != nil {
+			return err
+		}
+		if source == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "controller not found")
+		}
+		target, err := h.ztcc.FindController(ctx, name)
+		if err != nil {
+			return err
+		}
+		if target == nil {
+			return echo.NewHTTPError(
+				http.StatusNotFound, fmt.Sprintf("zerotier controller %s not found", name),
+			)
+		}
+		if target.ID == source.ID {
+			return echo.NewHTTPError(
+				http.StatusBadRequest, fmt.Sprintf("network is already hosted by controller %s", name),
+			)
+		}
+		migrated, err := migrateNetwork(ctx, *source, id, *target, h.ztc, h.ztcc, h.dc)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't migrate network %s to controller %s", id, name)
+		}
+
+		// Redirect user
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf(
+			"/networks/%s/migration/%s", id, *migrated.Id,
+		))
+	}
+}
+
+// Migration Status
+
+type MigratedMember struct {
+	Member zerotier.ControllerNetworkMember
+	Joined bool
+}
+
+type NetworkMigrationViewData struct {
+	SourceController *ztcontrollers.Controller
+	SourceNetwork    *zerotier.ControllerNetwork // nil if the source network was already deleted
+	TargetController ztcontrollers.Controller
+	TargetNetwork    zerotier.ControllerNetwork
+	Members          []MigratedMember
+	Joined           int
+}
+
+func getNetworkMigrationViewData(
+	ctx context.Context, sourceID, targetID string, c *ztc.Client, cc *ztcontrollers.Client,
+) (vd NetworkMigrationViewData, err error) {
+	target, err := cc.FindControllerByAddress(ctx, ztc.GetControllerAddress(targetID))
+	if err != nil {
+		return NetworkMigrationViewData{}, err
+	}
+	if target == nil {
+		return NetworkMigrationViewData{}, echo.NewHTTPError(http.StatusNotFound, "controller not found")
+	}
+	vd.TargetController = *target
+	if source, err := cc.FindControllerByAddress(
+		ctx, ztc.GetControllerAddress(sourceID),
+	); err == nil {
+		// Tolerate a removed source controller by acting as if the source network was deleted
+		vd.SourceController = source
+	}
+
+	eg, egctx := errgroup.WithContext(ctx)
+	var network *zerotier.ControllerNetwork
+	var memberAddresses []string
+	eg.Go(func() (err error) {
+		network, memberAddresses, err = c.GetNetworkInfo(egctx, *target, targetID)
+		return err
+	})
+	if vd.SourceController != nil {
+		eg.Go(func() (err error) {
+			vd.SourceNetwork, err = c.GetNetwork(egctx, *vd.SourceController, sourceID)
+			return err
+		})
+	}
+	if err = eg.Wait(); err != nil {
+		return NetworkMigrationViewData{}, err
+	}
+	if network == nil {
+		return NetworkMigrationViewData{}, echo.NewHTTPError(
+			http.StatusNotFound, "zerotier network not found",
+		)
+	}
+	vd.TargetNetwork = *network
+
+	members, err := c.GetNetworkMembers(ctx, *target, targetID, memberAddresses)
+	if err != nil {
+		return NetworkMigrationViewData{}, err
+	}
+	sort.Strings(memberAddresses)
+	vd.Members = make([]MigratedMember, 0, len(memberAddresses))
+	for _, address := range memberAddresses {
+		member := MigratedMember{
+			Member: members[address],
+			Joined: ztc.MemberJoined(members[address]),
+		}
+		if member.Joined {
+			vd.Joined++
+		}
+		vd.Members = append(vd.Members, member)
+	}
+	return vd, nil
+}
+
+func (h *Handlers) HandleNetworkMigrationGet() auth.HTTPHandlerFunc {
+	t := "networks/migration.page.tmpl"
+	h.r.MustHave(t)
+	return func(c echo.Context, a auth.Auth) error {
+		// Parse params
+		id := c.Param("id")
+		targetID := c.Param("target")
+
+		// Run queries
+		migrationViewData, err := getNetworkMigrationViewData(
+			c.Request().Context(), id, targetID, h.ztc, h.ztcc,
+		)
+		if err != nil {
+			return err
+		}
+
+		// Produce output
+		return h.r.CacheablePage(c.Response(), c.Request(), t, migrationViewData, a)
+	}
+}
@@ -0,0 +1,179 @@
+package networks
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sargassum-world/godest/turbostreams"
+
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/auth"
+	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
+	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+const advancedMiscPartial = "networks/network-advancedmisc.partial.tmpl"
+
+func replaceAdvancedMiscStream(
+	id string, network *zerotier.ControllerNetwork, a auth.Auth,
+) turbostreams.Message {
+	return turbostreams.Message{
+		Action:   turbostreams.ActionReplace,
+		Target:   "/networks/" + id + "/advancedmisc",
+		Template: advancedMiscPartial,
+		Data: map[string]interface{}{
+			"Network": network,
+			"Auth":    a,
+		},
+	}
+}
+
+func setNetworkSettings(
+	ctx context.Context, controller ztcontrollers.Controller,
+	id string, settings zerotier.SetControllerNetworkJSONRequestBody, c *ztc.Client,
+) (*zerotier.ControllerNetwork, error) {
+	network, err := c.UpdateNetwork(ctx, controller, id, settings)
+	if err != nil {
+		return nil, err
+	}
+	return network, nil
+}
+
+// handleNetworkSettingsPost updates a network with the settings parsed from the request and
+// replaces the network's miscellaneous settings.
+func (h *Handlers) handleNetworkSettingsPost(
+	parse func(c echo.Context) (zerotier.SetControllerNetworkJSONRequestBody, error),
+) auth.HTTPHandlerFunc {
+	h.r.MustHave(advancedMiscPartial)
+	return func(c echo.Context, a auth.Auth) error {
+		// Parse params
+		id := c.Param("id")
+		address := ztc.GetControllerAddress(id)
+		settings, err := parse(c)
+		if err != nil {
+			return err
+		}
+
+		// Run queries
+		ctx := c.Request().Context()
+		controller, err := h.ztcc.FindControllerByAddress(ctx, address)
+		if err != nil {
+			return err
+		}
+		network, err := setNetworkSettings(ctx, *controller, id, settings, h.ztc)
+		if err != nil {
+			return err
+		}
+
+		// Render Turbo Stream if accepted
+		if turbostreams.Accepted(c.Request().Header) {
+			// TODO: also broadcast this message over Turbo Streams, and have web browsers subscribe to it
+			return h.r.TurboStream(c.Response(), replaceAdvancedMiscStream(id, network, a))
+		}
+
+		// Redirect user
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf(
+			"/networks/%s#/networks/%s/advancedmisc", id, id,
+		))
+	}
+}
+
+func parseSettingsInt(raw, description string, minValue, maxValue int) (int, error) {
+	value, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"invalid %s %s", description, raw,
+		))
+	}
+	if value < minValue {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"%s must be at least %d", description, minValue,
+		))
+	}
+	if value > maxValue {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"%s must be at most %d", description, maxValue,
+		))
+	}
+	return value, nil
+}
+
+// Network Access Control
+
+func (h *Handlers) HandleNetworkAccessPost() auth.HTTPHandlerFunc {
+	return h.handleNetworkSettingsPost(
+		func(c echo.Context) (zerotier.SetControllerNetworkJSONRequestBody, error) {
+			private := strings.ToLower(c.FormValue("private")) == checkboxTrueValue
+			return zerotier.SetControllerNetworkJSONRequestBody{Private: &private}, nil
+		},
+	)
+}
+
+// Network Ethernet Settings
+
+const (
+	// minMTU and maxMTU are the bounds which ZeroTier's controller enforces on network MTUs
+	minMTU = 1280
+	maxMTU = 10000
+)
+
+func (h *Handlers) HandleNetworkEthernetPost() auth.HTTPHandlerFunc {
+	return h.handleNetworkSettingsPost(
+		func(c echo.Context) (zerotier.SetControllerNetworkJSONRequestBody, error) {
+			mtu, err := parseSettingsInt(
+				c.FormValue("mtu"), "maximum transmission unit", minMTU, maxMTU,
+			)
+			if err != nil {
+				return zerotier.SetControllerNetworkJSONRequestBody{}, err
+			}
+			multicastLimit, err := parseSettingsInt(
+				c.FormValue("multicast-limit"), "multicast recipient limit", 0, math.MaxInt32,
+			)
+			if err != nil {
+				return zerotier.SetControllerNetworkJSONRequestBody{}, err
+			}
+			broadcast := strings.ToLower(c.FormValue("broadcast")) == checkboxTrueValue
+			return zerotier.SetControllerNetworkJSONRequestBody{
+				Mtu:             &mtu,
+				MulticastLimit:  &multicastLimit,
+				EnableBroadcast: &broadcast,
+			}, nil
+		},
+	)
+}
+
+// Network Remote Tracing
+
+var remoteTraceTargetParser = regexp.MustCompile(`^[0-9a-f]{10}$`)
+
+func (h *Handlers) HandleNetworkRemoteTracePost() auth.HTTPHandlerFunc {
+	return h.handleNetworkSettingsPost(
+		func(c echo.Context) (zerotier.SetControllerNetworkJSONRequestBody, error) {
+			level, err := parseSettingsInt(
+				c.FormValue("level"), "remote trace level", 0, math.MaxInt32,
+			)
+			if err != nil {
+				return zerotier.SetControllerNetworkJSONRequestBody{}, err
+			}
+			// An empty target disables remote tracing
+			target := strings.ToLower(strings.TrimSpace(c.FormValue("target")))
+			if target != "" && !remoteTraceTargetParser.MatchString(target) {
+				return zerotier.SetControllerNetworkJSONRequestBody{}, echo.NewHTTPError(
+					http.StatusBadRequest, fmt.Sprintf(
+						"remote trace target %s must be a 10-digit hexadecimal ZeroTier address", target,
+					),
+				)
+			}
+			return zerotier.SetControllerNetworkJSONRequestBody{
+				RemoteTraceLevel:  &level,
+				RemoteTraceTarget: &target,
+			}, nil
+		},
+	)
+}
@@ -0,0 +1,450 @@
+package networks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/sargassum-world/godest/turbostreams"
+
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/auth"
+	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
+	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/internal/clients/zttags"
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+// Capabilities and tags are identified by ids of 32-bit unsigned integers, and tag values are
+// 32-bit unsigned integers
+const maxTagNumber = 1<<32 - 1
+
+func parseTagNumber(raw, description string) (uint64, error) {
+	const base = 10
+	const bitSize = 64
+	value, err := strconv.ParseUint(strings.TrimSpace(raw), base, bitSize)
+	if err != nil || value > maxTagNumber {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"%s must be a number from 0 to %d", description, uint64(maxTagNumber),
+		))
+	}
+	return value, nil
+}
+
+func parseTagNumbers(raw []string, description string) ([]uint64, error) {
+	values := make([]uint64, len(raw))
+	for i, rawValue := range raw {
+		value, err := parseTagNumber(rawValue, description)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func getNetworkCapabilities(network zerotier.ControllerNetwork) ([]zerotier.Capability, error) {
+	if network.Capabilities == nil {
+		return []zerotier.Capability{}, nil
+	}
+	capabilities, err := zerotier.NewCapabilities(*network.Capabilities)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't parse capabilities of network %s", *network.Id)
+	}
+	sort.Slice(capabilities, func(i, j int) bool {
+		return capabilities[i].ID < capabilities[j].ID
+	})
+	return capabilities, nil
+}
+
+// NetworkTag is a tag of a network, with the names given to its values.
+type NetworkTag struct {
+	Tag   zerotier.Tag
+	Enums []zttags.Enum
+}
+
+func getNetworkTags(
+	ctx context.Context, network zerotier.ControllerNetwork, tc *zttags.Client,
+) ([]NetworkTag, error) {
+	if network.Tags == nil {
+		return []NetworkTag{}, nil
+	}
+	tags, err := zerotier.NewTags(*network.Tags)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't parse tags of network %s", *network.Id)
+	}
+	enums, err := tc.GetEnums(ctx, *network.Id)
+	if err != nil {
+		return nil, err
+	}
+	networkTags := make([]NetworkTag, len(tags))
+	for i, tag := range tags {
+		networkTags[i] = NetworkTag{Tag: tag, Enums: enums[tag.ID]}
+	}
+	sort.Slice(networkTags, func(i, j int) bool {
+		return networkTags[i].Tag.ID < networkTags[j].Tag.ID
+	})
+	return networkTags, nil
+}
+
+func (t NetworkTag) findEnum(name string) (zttags.Enum, bool) {
+	for _, enum := range t.Enums {
+		if enum.Name == name {
+			return enum, true
+		}
+	}
+	return zttags.Enum{}, false
+}
+
+// Network Capabilities
+
+func setNetworkCapabilities(
+	ctx context.Context, controller ztcontrollers.Controller,
+	id string, capabilities []zerotier.Capability, c *ztc.Client,
+) (*zerotier.ControllerNetwork, error) {
+	rawCapabilities, err := zerotier.RawCapabilities(capabilities)
+	if err != nil {
+		return nil, err
+	}
+	network, err := c.UpdateNetwork(
+		ctx, controller, id,
+		zerotier.SetControllerNetworkJSONRequestBody{Capabilities: &rawCapabilities},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return network, nil
+}
+
+func parseNewCapability(c echo.Context) (*zerotier.Capability, error) {
+	rawID := strings.TrimSpace(c.FormValue("new-capability-id"))
+	if rawID == "" {
+		return nil, nil
+	}
+	id, err := parseTagNumber(rawID, "capability id")
+	if err != nil {
+		return nil, err
+	}
+	capability := zerotier.Capability{
+		ID:      id,
+		Default: strings.ToLower(c.FormValue("new-capability-default")) == checkboxTrueValue,
+		Rules:   []zerotier.Rule{},
+	}
+	if rawRules := strings.TrimSpace(c.FormValue("new-capability-rules")); rawRules != "" {
+		if capability.Rules, err = zerotier.ParseRules([]byte(rawRules)); err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+				"invalid rules for capability %d: %s", id, err,
+			))
+		}
+	}
+	return &capability, nil
+}
+
+func (h *Handlers) HandleNetworkCapabilitiesPost() auth.HTTPHandlerFunc {
+	t := "networks/network-capabilities.partial.tmpl"
+	h.r.MustHave(t)
+	return func(c echo.Context, a auth.Auth) error {
+		// Parse params
+		id := c.Param("id")
+		address := ztc.GetControllerAddress(id)
+		formParams, err := c.FormParams()
+		if err != nil {
+			return errors.Wrap(err, "couldn't parse form params")
+		}
+		keptIDs, err := parseTagNumbers(formParams["existing-capabilities"], "capability id")
+		if err != nil {
+			return err
+		}
+		newCapability, err := parseNewCapability(c)
+		if err != nil {
+			return err
+		}
+
+		// Run queries
+		ctx := c.Request().Context()
+		controller, err := h.ztcc.FindControllerByAddress(ctx, address)
+		if err != nil {
+			return err
+		}
+		network, err := h.ztc.GetNetwork(ctx, *controller, id)
+		if err != nil {
+			return err
+		}
+		if network == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "zerotier network not found")
+		}
+		prevCapabilities, err := getNetworkCapabilities(*network)
+		if err != nil {
+			return err
+		}
+		kept := make(map[uint64]bool, len(keptIDs))
+		for _, keptID := range keptIDs {
+			kept[keptID] = true
+		}
+		capabilities := make([]zerotier.Capability, 0, len(prevCapabilities)+1)
+		for _, capability := range prevCapabilities {
+			// A new capability with the same id as an existing capability replaces it
+			if kept[capability.ID] && (newCapability == nil || capability.ID != newCapability.ID) {
+				capabilities = append(capabilities, capability)
+			}
+		}
+		if newCapability != nil {
+			capabilities = append(capabilities, *newCapability)
+		}
+		if network, err = setNetworkCapabilities(ctx, *controller, id, capabilities, h.ztc); err != nil {
+			return err
+		}
+
+		// Render Turbo Stream if accepted
+		if turbostreams.Accepted(c.Request().Header) {
+			// TODO: also broadcast this message over Turbo Streams, and have web browsers subscribe to it
+			if capabilities, err = getNetworkCapabilities(*network); err != nil {
+				return err
+			}
+			return h.r.TurboStream(c.Response(), turbostreams.Message{
+				Action:   turbostreams.ActionReplace,
+				Target:   "/networks/" + id + "/capabilities",
+				Template: t,
+				Data: map[string]interface{}{
+					"Network":      network,
+					"Capabilities": capabilities,
+					"Auth":         a,
+				},
+			})
+		}
+
+		// Redirect user
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf(
+			"/networks/%s#/networks/%s/capabilities", id, id,
+		))
+	}
+}
+
+// Network Tags
+
+func setNetworkTags(
+	ctx context.Context, controller ztcontrollers.Controller,
+	id string, tags []zerotier.Tag, c *ztc.Client,
+) (*zerotier.ControllerNetwork, error) {
+	rawTags, err := zerotier.RawTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	network, err := c.UpdateNetwork(
+		ctx, controller, id, zerotier.SetControllerNetworkJSONRequestBody{Tags: &rawTags},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return network, nil
+}
+
+func parseNewTag(c echo.Context) (*zerotier.Tag, error) {
+	rawID := strings.TrimSpace(c.FormValue("new-tag-id"))
+	if rawID == "" {
+		return nil, nil
+	}
+	id, err := parseTagNumber(rawID, "tag id")
+	if err != nil {
+		return nil, err
+	}
+	tag := zerotier.Tag{ID: id}
+	if rawDefault := strings.TrimSpace(c.FormValue("new-tag-default")); rawDefault != "" {
+		defaultValue, err := parseTagNumber(rawDefault, "default tag value")
+		if err != nil {
+			return nil, err
+		}
+		tag.Default = &defaultValue
+	}
+	return &tag, nil
+}
+
+func replaceNetworkTagsStream(
+	ctx context.Context, network zerotier.ControllerNetwork, a auth.Auth, tc *zttags.Client,
+) (turbostreams.Message, error) {
+	tags, err := getNetworkTags(ctx, network, tc)
+	if err != nil {
+		return turbostreams.Message{}, err
+	}
+	return turbostreams.Message{
+		Action:   turbostreams.ActionReplace,
+		Target:   "/networks/" + *network.Id + "/tags",
+		Template: "networks/network-tags.partial.tmpl",
+		Data: map[string]interface{}{
+			"Network": network,
+			"Tags":    tags,
+			"Auth":    a,
+		},
+	}, nil
+}
+
+func (h *Handlers) HandleNetworkTagsPost() auth.HTTPHandlerFunc {
+	h.r.MustHave("networks/network-tags.partial.tmpl")
+	return func(c echo.Context, a auth.Auth) error {
+		// Parse params
+		id := c.Param("id")
+		address := ztc.GetControllerAddress(id)
+		formParams, err := c.FormParams()
+		if err != nil {
+			return errors.Wrap(err, "couldn't parse form params")
+		}
+		keptIDs, err := parseTagNumbers(formParams["existing-tags"], "tag id")
+		if err != nil {
+			return err
+		}
+		newTag, err := parseNewTag(c)
+		if err != nil {
+			return err
+		}
+
+		// Run queries
+		ctx := c.Request().Context()
+		controller, err := h.ztcc.FindControllerByAddress(ctx, address)
+		if err != nil {
+			return err
+		}
+		network, err := h.ztc.GetNetwork(ctx, *controller, id)
+		if err != nil {
+			return err
+		}
+		if network == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "zerotier network not found")
+		}
+		prevTags, err := getNetworkTags(ctx, *network, h.ztg)
+		if err != nil {
+			return err
+		}
+		kept := make(map[uint64]bool, len(keptIDs))
+		for _, keptID := range keptIDs {
+			kept[keptID] = true
+		}
+		tags := make([]zerotier.Tag, 0, len(prevTags)+1)
+		removedIDs := make([]uint64, 0, len(prevTags))
+		for _, tag := range prevTags {
+			switch {
+			case newTag != nil && tag.Tag.ID == newTag.ID:
+				// A new tag with the same id as an existing tag replaces it, but keeps its enums
+			case kept[tag.Tag.ID]:
+				tags = append(tags, tag.Tag)
+			default:
+				removedIDs = append(removedIDs, tag.Tag.ID)
+			}
+		}
+		if newTag != nil {
+			tags = append(tags, *newTag)
+		}
+		if network, err = setNetworkTags(ctx, *controller, id, tags, h.ztc); err != nil {
+			return err
+		}
+		for _, removedID := range removedIDs {
+			if err = h.ztg.DeleteTagEnums(ctx, id, removedID); err != nil {
+				return err
+			}
+		}
+
+		// Render Turbo Stream if accepted
+		if turbostreams.Accepted(c.Request().Header) {
+			// TODO: also broadcast this message over Turbo Streams, and have web browsers subscribe to it
+			message, err := replaceNetworkTagsStream(ctx, *network, a, h.ztg)
+			if err != nil {
+				return err
+			}
+			return h.r.TurboStream(c.Response(), message)
+		}
+
+		// Redirect user
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/networks/%s#/networks/%s/tags", id, id))
+	}
+}
+
+func (h *Handlers) HandleNetworkTagEnumsPost() auth.HTTPHandlerFunc {
+	h.r.MustHave("networks/network-tags.partial.tmpl")
+	return func(c echo.Context, a auth.Auth) error {
+		// Parse params
+		id := c.Param("id")
+		address := ztc.GetControllerAddress(id)
+		tagID, err := parseTagNumber(c.Param("tag"), "tag id")
+		if err != nil {
+			return err
+		}
+		formParams, err := c.FormParams()
+		if err != nil {
+			return errors.Wrap(err, "couldn't parse form params")
+		}
+		keptValues, err := parseTagNumbers(formParams["existing-enums"], "tag value")
+		if err != nil {
+			return err
+		}
+		var newEnum *zttags.Enum
+		if newName := strings.TrimSpace(c.FormValue("new-enum-name")); newName != "" {
+			value, err := parseTagNumber(c.FormValue("new-enum-value"), "tag value")
+			if err != nil {
+				return err
+			}
+			newEnum = &zttags.Enum{NetworkID: id, TagID: tagID, Value: value, Name: newName}
+		}
+
+		// Run queries
+		ctx := c.Request().Context()
+		controller, err := h.ztcc.FindControllerByAddress(ctx, address)
+		if err != nil {
+			return err
+		}
+		network, err := h.ztc.GetNetwork(ctx, *controller, id)
+		if err != nil {
+			return err
+		}
+		if network == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "zerotier network not found")
+		}
+		tags, err := getNetworkTags(ctx, *network, h.ztg)
+		if err != nil {
+			return err
+		}
+		var tag *NetworkTag
+		for i := range tags {
+			if tags[i].Tag.ID == tagID {
+				tag = &tags[i]
+			}
+		}
+		if tag == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "zerotier network tag not found")
+		}
+		kept := make(map[uint64]bool, len(keptValues))
+		for _, keptValue := range keptValues {
+			kept[keptValue] = true
+		}
+		for _, enum := range tag.Enums {
+			// A new enum with the same value or name as an existing enum replaces it
+			if kept[enum.Value] && (newEnum == nil || (enum.Value != newEnum.Value &&
+				enum.Name != newEnum.Name)) {
+				continue
+			}
+			if err = h.ztg.DeleteEnum(ctx, enum); err != nil {
+				return err
+			}
+		}
+		if newEnum != nil {
+			if _, err = h.ztg.AddEnum(ctx, *newEnum); err != nil {
+				return err
+			}
+		}
+
+		// Render Turbo Stream if accepted
+		if turbostreams.Accepted(c.Request().Header) {
+			// TODO: also broadcast this message over Turbo Streams, and have web browsers subscribe to it
+			message, err := replaceNetworkTagsStream(ctx, *network, a, h.ztg)
+			if err != nil {
+				return err
+			}
+			return h.r.TurboStream(c.Response(), message)
+		}
+
+		// Redirect user
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/networks/%s#/networks/%s/tags", id, id))
+	}
+}
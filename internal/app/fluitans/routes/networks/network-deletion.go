@@ -0,0 +1,171 @@
+package networks
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/auth"
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/client"
+	desecc "github.com/sargassum-world/fluitans/internal/clients/desec"
+	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
+	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/internal/clients/ztdevices"
+	"github.com/sargassum-world/fluitans/internal/clients/ztjoins"
+	"github.com/sargassum-world/fluitans/pkg/desec"
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+// Network DNS Records
+
+// NetworkRecords lists the DNS records which refer to a network.
+type NetworkRecords struct {
+	// IDRRsets are the TXT rrsets with a zerotier-net-id record for the network, for the network's
+	// name and all its aliases
+	IDRRsets []desec.RRset
+	// DeviceRRsets are the rrsets for the domain names of devices on the network
+	DeviceRRsets []desec.RRset
+}
+
+// getNetworkRecords finds the DNS records which refer to the network; device records are only
+// found if the network is named by DNS with the provided subname.
+func getNetworkRecords(
+	id, subname string, subnameRRsets map[string][]desec.RRset,
+) (r NetworkRecords) {
+	idSubnames := make([]string, 0)
+	for idSubname, networkID := range client.GetNetworkIDs(subnameRRsets) {
+		if networkID == id {
+			idSubnames = append(idSubnames, idSubname)
+		}
+	}
+	sort.Strings(idSubnames)
+	r.IDRRsets = make([]desec.RRset, 0, len(idSubnames))
+	for _, idSubname := range idSubnames {
+		r.IDRRsets = append(r.IDRRsets, *findRRset(subnameRRsets[idSubname], "TXT"))
+	}
+
+	r.DeviceRRsets = make([]desec.RRset, 0)
+	if subname == "" {
+		return r
+	}
+	for _, deviceSubname := range getDeviceSubnames(subname, subnameRRsets) {
+		rrsets := append([]desec.RRset{}, subnameRRsets[deviceSubname]...)
+		sort.Slice(rrsets, func(i, j int) bool { return rrsets[i].Type < rrsets[j].Type })
+		r.DeviceRRsets = append(r.DeviceRRsets, rrsets...)
+	}
+	return r
+}
+
+// newTombstonedNetworkIDRRset makes a TXT rrset which replaces the network's zerotier-net-id
+// record in the rrset with a tombstone record.
+func newTombstonedNetworkIDRRset(id string, rrset desec.RRset) desec.RRset {
+	records := make([]string, len(rrset.Records))
+	for i, record := range rrset.Records {
+		records[i] = record
+		if recordID, isIDRecord := client.ParseNetworkIDRecord(record); isIDRecord && recordID == id {
+			records[i] = client.MakeNetworkTombstoneRecord(id)
+		}
+	}
+	return desec.RRset{Subname: rrset.Subname, Type: rrset.Type, Ttl: rrset.Ttl, Records: records}
+}
+
+// newRemovalRRsets makes rrsets which delete the device records and either remove or tombstone
+// the zerotier-net-id records, for a single upsert batch.
+func (r NetworkRecords) newRemovalRRsets(id string, tombstone bool) []desec.RRset {
+	rrsets := make([]desec.RRset, 0, len(r.IDRRsets)+len(r.DeviceRRsets))
+	for _, rrset := range r.IDRRsets {
+		if tombstone {
+			rrsets = append(rrsets, newTombstonedNetworkIDRRset(id, rrset))
+			continue
+		}
+		rrsets = append(rrsets, newUnlinkedNetworkIDRRset(id, rrset))
+	}
+	for _, rrset := range r.DeviceRRsets {
+		rrsets = append(rrsets, desecc.NewRRsetKey(rrset).AsDeletionUpsertRRset())
+	}
+	return rrsets
+}
+
+// Network Deletion
+
+// deleteNetwork removes or tombstones the network's DNS records in a single batch and then deletes
+// the network, along with the information Fluitans stores about its devices and join requests. The
+// DNS records are changed first, so that the network is left intact if the changes are rejected
+// (e.g. by the write rate limit for the DNS server).
+func deleteNetwork(
+	ctx context.Context, controller ztcontrollers.Controller, id string, tombstone bool,
+	c *ztc.Client, cc *ztcontrollers.Client, dc *desecc.Client,
+	mc *ztdevices.Client, jc *ztjoins.Client,
+) error {
+	_, subname, subnameRRsets, err := getNamedNetwork(ctx, controller, id, c, dc)
+	if err != nil {
+		return err
+	}
+	if rrsets := getNetworkRecords(id, subname, subnameRRsets).newRemovalRRsets(
+		id, tombstone,
+	); len(rrsets) > 0 {
+		if _, err = dc.UpsertRRsets(ctx, rrsets...); err != nil {
+			return errors.Wrapf(err, "couldn't remove DNS records of network %s", id)
+		}
+	}
+	if err = c.DeleteNetwork(ctx, controller, id, cc); err != nil {
+		return err
+	}
+	if err = mc.DeleteNetwork(ctx, id); err != nil {
+		return err
+	}
+	return jc.DeleteNetwork(ctx, id)
+}
+
+type NetworkDeletionViewData struct {
+	Controller ztcontrollers.Controller
+	Network    zerotier.ControllerNetwork
+	Records    NetworkRecords
+	DomainName string
+}
+
+func getNetworkDeletionViewData(
+	ctx context.Context, id string, c *ztc.Client, cc *ztcontrollers.Client, dc *desecc.Client,
+) (vd NetworkDeletionViewData, err error) {
+	controller, err := cc.FindControllerByAddress(ctx, ztc.GetControllerAddress(id))
+	if err != nil {
+		return NetworkDeletionViewData{}, err
+	}
+	if controller == nil {
+		return NetworkDeletionViewData{}, echo.NewHTTPError(
+			http.StatusNotFound, "controller not found",
+		)
+	}
+	vd.Controller = *controller
+	network, subname, subnameRRsets, err := getNamedNetwork(ctx, *controller, id, c, dc)
+	if err != nil {
+		return NetworkDeletionViewData{}, err
+	}
+	vd.Network = *network
+	vd.Records = getNetworkRecords(id, subname, subnameRRsets)
+	vd.DomainName = dc.Config.DomainName
+	return vd, nil
+}
+
+func (h *Handlers) HandleNetworkDeletionGet() auth.HTTPHandlerFunc {
+	t := "networks/deletion.page.tmpl"
+	h.r.MustHave(t)
+	return func(c echo.Context, a auth.Auth) error {
+		// Parse params
+		id := c.Param("id")
+
+		// Run queries
+		deletionViewData, err := getNetworkDeletionViewData(
+			c.Request().Context(), id, h.ztc, h.ztcc, h.dc,
+		)
+		if err != nil {
+			return err
+		}
+
+		// Produce output
+		return h.r.CacheablePage(c.Response(), c.Request(), t, deletionViewData, a)
+	}
+}
@@ -0,0 +1,174 @@
+package networks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/sargassum-world/godest/turbostreams"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/auth"
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/client"
+	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
+	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/pkg/desec"
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+// Network DNS Settings
+
+var searchDomainParser = regexp.MustCompile(
+	`^([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)*[a-z0-9]([a-z0-9-]*[a-z0-9])?$`,
+)
+
+const (
+	maxSearchDomainLength = 253
+	// maxDNSServers is the number of DNS servers which ZeroTier pushes to members
+	maxDNSServers = 4
+)
+
+func parseSearchDomain(raw string) (string, error) {
+	domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(raw)), ".")
+	if domain == "" {
+		return "", nil
+	}
+	if len(domain) > maxSearchDomainLength || !searchDomainParser.MatchString(domain) {
+		return "", echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"invalid search domain %s", raw,
+		))
+	}
+	return domain, nil
+}
+
+func parseDNSServers(rawServers []string) ([]string, error) {
+	servers := make([]string, 0, len(rawServers))
+	for _, rawServer := range rawServers {
+		if rawServer = strings.TrimSpace(rawServer); rawServer == "" {
+			continue
+		}
+		server, err := netip.ParseAddr(rawServer)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+				"invalid DNS server address %s", rawServer,
+			))
+		}
+		servers = append(servers, server.String())
+	}
+	if len(servers) > maxDNSServers {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"networks can have at most %d DNS servers", maxDNSServers,
+		))
+	}
+	return servers, nil
+}
+
+const networkDNSPartial = "networks/network-dns.partial.tmpl"
+
+func replaceNetworkDNSStream(
+	id string, network *zerotier.ControllerNetwork, namedByDNS bool, a auth.Auth,
+) turbostreams.Message {
+	return turbostreams.Message{
+		Action:   turbostreams.ActionReplace,
+		Target:   "/networks/" + id + "/dns",
+		Template: networkDNSPartial,
+		Data: map[string]interface{}{
+			"Network":    network,
+			"NamedByDNS": namedByDNS,
+			"Auth":       a,
+		},
+	}
+}
+
+func setNetworkDNS(
+	ctx context.Context, controller ztcontrollers.Controller,
+	id string, dns zerotier.DNS, c *ztc.Client,
+) (*zerotier.ControllerNetwork, error) {
+	network, err := c.UpdateNetwork(
+		ctx, controller, id, zerotier.SetControllerNetworkJSONRequestBody{Dns: &dns},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return network, nil
+}
+
+func (h *Handlers) HandleNetworkDNSPost() auth.HTTPHandlerFunc {
+	h.r.MustHave(networkDNSPartial)
+	return func(c echo.Context, a auth.Auth) error {
+		// Parse params
+		id := c.Param("id")
+		address := ztc.GetControllerAddress(id)
+		formParams, err := c.FormParams()
+		if err != nil {
+			return errors.Wrap(err, "couldn't parse form params")
+		}
+		useNetworkName := strings.ToLower(c.FormValue("network-name")) == checkboxTrueValue
+		domain, err := parseSearchDomain(c.FormValue("domain"))
+		if err != nil {
+			return err
+		}
+		servers, err := parseDNSServers(
+			append(formParams["existing-servers"], c.FormValue("new-server")),
+		)
+		if err != nil {
+			return err
+		}
+
+		// Run queries
+		ctx := c.Request().Context()
+		controller, err := h.ztcc.FindControllerByAddress(ctx, address)
+		if err != nil {
+			return err
+		}
+		eg, egctx := errgroup.WithContext(ctx)
+		var network *zerotier.ControllerNetwork
+		var subnameRRsets map[string][]desec.RRset
+		eg.Go(func() (err error) {
+			network, err = h.ztc.GetNetwork(egctx, *controller, id)
+			return err
+		})
+		eg.Go(func() (err error) {
+			subnameRRsets, err = h.dc.GetRRsets(egctx)
+			return err
+		})
+		if err = eg.Wait(); err != nil {
+			return err
+		}
+		if network == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "zerotier network not found")
+		}
+		namedByDNS := network.Name != nil && client.NetworkNamedByDNS(
+			id, *network.Name, h.dc.Config.DomainName, subnameRRsets,
+		)
+		if useNetworkName {
+			if !namedByDNS {
+				return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+					"network %s isn't named by DNS, so its name can't be its search domain", id,
+				))
+			}
+			domain = *network.Name
+		}
+		if network, err = setNetworkDNS(
+			ctx, *controller, id, zerotier.DNS{Domain: &domain, Servers: &servers}, h.ztc,
+		); err != nil {
+			return err
+		}
+
+		// Render Turbo Stream if accepted
+		if turbostreams.Accepted(c.Request().Header) {
+			// TODO: also broadcast this message over Turbo Streams, and have web browsers subscribe to it
+			return h.r.TurboStream(
+				c.Response(), replaceNetworkDNSStream(id, network, namedByDNS, a),
+			)
+		}
+
+		// Redirect user
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/networks/%s#/networks/%s/dns", id, id))
+	}
+}
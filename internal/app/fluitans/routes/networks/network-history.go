@@ -0,0 +1,188 @@
+package networks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/auth"
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/client"
+	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
+	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/internal/clients/zthistory"
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+type NetworkHistoryViewData struct {
+	Controller ztcontrollers.Controller
+	Network    zerotier.ControllerNetwork
+	Snapshots  []zthistory.Snapshot
+	From       *zthistory.Snapshot
+	To         *zthistory.Snapshot
+	Diff       []zthistory.DiffLine
+}
+
+func parseRevision(raw string) (int64, error) {
+	revision, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || revision < 0 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid revision %s", raw))
+	}
+	return revision, nil
+}
+
+// findSnapshot returns the snapshot with the revision, or nil if no snapshot has the revision.
+func findSnapshot(snapshots []zthistory.Snapshot, revision int64) *zthistory.Snapshot {
+	for i, snapshot := range snapshots {
+		if snapshot.Revision == revision {
+			return &snapshots[i]
+		}
+	}
+	return nil
+}
+
+func getNetworkHistoryViewData(
+	ctx context.Context, id, rawFrom, rawTo string,
+	c *ztc.Client, cc *ztcontrollers.Client, hc *zthistory.Client,
+) (vd NetworkHistoryViewData, err error) {
+	controller, err := cc.FindControllerByAddress(ctx, ztc.GetControllerAddress(id))
+	if err != nil {
+		return NetworkHistoryViewData{}, err
+	}
+	if controller == nil {
+		return NetworkHistoryViewData{}, echo.NewHTTPError(
+			http.StatusNotFound, "controller not found",
+		)
+	}
+	vd.Controller = *controller
+	network, err := c.GetNetwork(ctx, *controller, id)
+	if err != nil {
+		return NetworkHistoryViewData{}, err
+	}
+	if network == nil {
+		return NetworkHistoryViewData{}, echo.NewHTTPError(
+			http.StatusNotFound, "zerotier network not found",
+		)
+	}
+	vd.Network = *network
+	if vd.Snapshots, err = hc.GetSnapshots(ctx, id); err != nil {
+		return NetworkHistoryViewData{}, err
+	}
+	if len(vd.Snapshots) == 0 {
+		return vd, nil
+	}
+
+	// By default, we compare the latest snapshot with the snapshot before it
+	vd.To = &vd.Snapshots[0]
+	if len(vd.Snapshots) > 1 {
+		vd.From = &vd.Snapshots[1]
+	} else {
+		vd.From = vd.To
+	}
+	if rawFrom != "" {
+		from, err := parseRevision(rawFrom)
+		if err != nil {
+			return NetworkHistoryViewData{}, err
+		}
+		if vd.From = findSnapshot(vd.Snapshots, from); vd.From == nil {
+			return NetworkHistoryViewData{}, echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf(
+				"no snapshot of network %s at revision %d", id, from,
+			))
+		}
+	}
+	if rawTo != "" {
+		to, err := parseRevision(rawTo)
+		if err != nil {
+			return NetworkHistoryViewData{}, err
+		}
+		if vd.To = findSnapshot(vd.Snapshots, to); vd.To == nil {
+			return NetworkHistoryViewData{}, echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf(
+				"no snapshot of network %s at revision %d", id, to,
+			))
+		}
+	}
+	if vd.Diff, err = zthistory.DiffSnapshots(*vd.From, *vd.To); err != nil {
+		return NetworkHistoryViewData{}, errors.Wrapf(
+			err, "couldn't compare snapshots of network %s", id,
+		)
+	}
+	return vd, nil
+}
+
+func (h *Handlers) HandleNetworkHistoryGet() auth.HTTPHandlerFunc {
+	t := "networks/history.page.tmpl"
+	h.r.MustHave(t)
+	return func(c echo.Context, a auth.Auth) error {
+		// Parse params
+		id := c.Param("id")
+
+		// Run queries
+		historyViewData, err := getNetworkHistoryViewData(
+			c.Request().Context(), id, c.QueryParam("from"), c.QueryParam("to"),
+			h.ztc, h.ztcc, h.zth,
+		)
+		if err != nil {
+			return err
+		}
+
+		// Produce output
+		return h.r.CacheablePage(c.Response(), c.Request(), t, historyViewData, a)
+	}
+}
+
+// rollbackNetwork restores the network's configuration from its snapshot at the revision.
+func rollbackNetwork(
+	ctx context.Context, controller ztcontrollers.Controller, id string, revision int64,
+	c *ztc.Client, hc *zthistory.Client,
+) (*zerotier.ControllerNetwork, error) {
+	snapshot, err := hc.GetSnapshot(ctx, id, revision)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot == nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf(
+			"no snapshot of network %s at revision %d", id, revision,
+		))
+	}
+	network, err := c.UpdateNetwork(ctx, controller, id, client.NewRollbackRequest(*snapshot))
+	if err != nil {
+		return nil, errors.Wrapf(
+			err, "couldn't restore network %s to its configuration at revision %d", id, revision,
+		)
+	}
+	return network, nil
+}
+
+func (h *Handlers) HandleNetworkHistoryPost() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// Parse params
+		id := c.Param("id")
+		address := ztc.GetControllerAddress(id)
+		revision, err := parseRevision(c.FormValue("revision"))
+		if err != nil {
+			return err
+		}
+
+		// Run queries
+		ctx := c.Request().Context()
+		controller, err := h.ztcc.FindControllerByAddress(ctx, address)
+		if err != nil {
+			return err
+		}
+		network, err := rollbackNetwork(ctx, *controller, id, revision, h.ztc, h.zth)
+		if err != nil {
+			return err
+		}
+
+		// Redirect user
+		if network.Revision == nil {
+			return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/networks/%s/history", id))
+		}
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf(
+			"/networks/%s/history?from=%d&to=%d", id, revision, *network.Revision,
+		))
+	}
+}
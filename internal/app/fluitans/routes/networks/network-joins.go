@@ -0,0 +1,284 @@
+package networks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/auth"
+	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
+	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/internal/clients/ztdevices"
+	"github.com/sargassum-world/fluitans/internal/clients/ztjoins"
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+// PendingJoin is a device which requested to join a network but hasn't been authorized yet.
+type PendingJoin struct {
+	Member   zerotier.ControllerNetworkMember
+	Metadata ztdevices.Device
+	Decision *ztjoins.Decision // nil if no join policy has been applied to the device yet
+	// PreviouslyAuthorized is true if the device was authorized before, in which case join policies
+	// won't authorize it again
+	PreviouslyAuthorized bool
+}
+
+type NetworkJoinsViewData struct {
+	Controller ztcontrollers.Controller
+	Network    zerotier.ControllerNetwork
+	Policy     ztjoins.Policy
+	HasPolicy  bool
+	Pending    []PendingJoin
+	Decisions  []ztjoins.Decision
+	Now        time.Time
+}
+
+func getPendingJoins(
+	ctx context.Context, controller ztcontrollers.Controller, networkID string,
+	decisions []ztjoins.Decision, c *ztc.Client, mc *ztdevices.Client, jc *ztjoins.Client,
+) ([]PendingJoin, error) {
+	memberAddresses, err := c.GetNetworkMemberAddresses(ctx, controller, networkID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't get network %s member addresses", networkID)
+	}
+	sort.Strings(memberAddresses)
+	members, err := c.GetNetworkMembers(ctx, controller, networkID, memberAddresses)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't get network %s members", networkID)
+	}
+	devices, err := mc.GetDevices(ctx, networkID)
+	if err != nil {
+		return nil, err
+	}
+	authorizedMembers, err := jc.GetAuthorizedMembers(ctx, networkID)
+	if err != nil {
+		return nil, err
+	}
+	keyedDecisions := make(map[string]ztjoins.Decision)
+	for _, decision := range decisions {
+		keyedDecisions[decision.MemberAddress] = decision
+	}
+
+	pending := make([]PendingJoin, 0, len(memberAddresses))
+	for _, memberAddress := range memberAddresses {
+		member := members[memberAddress]
+		if (member.Authorized != nil && *member.Authorized) || !ztc.MemberJoined(member) {
+			continue
+		}
+		join := PendingJoin{
+			Member:               member,
+			Metadata:             devices[memberAddress],
+			PreviouslyAuthorized: authorizedMembers[memberAddress],
+		}
+		if decision, ok := keyedDecisions[memberAddress]; ok {
+			join.Decision = &decision
+		}
+		pending = append(pending, join)
+	}
+	return pending, nil
+}
+
+func getNetworkJoinsViewData(
+	ctx context.Context, id string,
+	c *ztc.Client, cc *ztcontrollers.Client, mc *ztdevices.Client, jc *ztjoins.Client,
+) (vd NetworkJoinsViewData, err error) {
+	controller, err := cc.FindControllerByAddress(ctx, ztc.GetControllerAddress(id))
+	if err != nil {
+		return NetworkJoinsViewData{}, err
+	}
+	if controller == nil {
+		return NetworkJoinsViewData{}, echo.NewHTTPError(
+			http.StatusNotFound, "controller not found",
+		)
+	}
+	vd.Controller = *controller
+	network, err := c.GetNetwork(ctx, *controller, id)
+	if err != nil {
+		return NetworkJoinsViewData{}, err
+	}
+	if network == nil {
+		return NetworkJoinsViewData{}, echo.NewHTTPError(
+			http.StatusNotFound, "zerotier network not found",
+		)
+	}
+	vd.Network = *network
+
+	policy, err := jc.GetPolicy(ctx, id)
+	if err != nil {
+		return NetworkJoinsViewData{}, err
+	}
+	if policy == nil {
+		// Without a policy, no devices are authorized automatically
+		vd.Policy = ztjoins.Policy{NetworkID: id, RequireApproval: true}
+	} else {
+		vd.Policy = *policy
+		vd.HasPolicy = true
+	}
+	if vd.Decisions, err = jc.GetDecisions(ctx, id); err != nil {
+		return NetworkJoinsViewData{}, err
+	}
+	if vd.Pending, err = getPendingJoins(
+		ctx, *controller, id, vd.Decisions, c, mc, jc,
+	); err != nil {
+		return NetworkJoinsViewData{}, err
+	}
+	vd.Now = time.Now()
+	return vd, nil
+}
+
+func (h *Handlers) HandleNetworkJoinsGet() auth.HTTPHandlerFunc {
+	t := "networks/joins.page.tmpl"
+	h.r.MustHave(t)
+	return func(c echo.Context, a auth.Auth) error {
+		// Parse params
+		id := c.Param("id")
+
+		// Run queries
+		joinsViewData, err := getNetworkJoinsViewData(
+			c.Request().Context(), id, h.ztc, h.ztcc, h.ztd, h.ztj,
+		)
+		if err != nil {
+			return err
+		}
+
+		// Produce output
+		return h.r.Page(c.Response(), c.Request(), http.StatusOK, t, joinsViewData, a)
+	}
+}
+
+// Join Policies
+
+// parseAllowlist parses a list of member addresses and identities, one per line.
+func parseAllowlist(raw string) []string {
+	allowlist := make([]string, 0)
+	for _, line := range strings.Split(raw, "\n") {
+		if entry := strings.TrimSpace(line); entry != "" {
+			allowlist = append(allowlist, entry)
+		}
+	}
+	return allowlist
+}
+
+func parseNonNegativeInt(raw, name string) (int64, error) {
+	if raw = strings.TrimSpace(raw); raw == "" {
+		return 0, nil
+	}
+	parsed, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || parsed < 0 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid %s %s", name, raw))
+	}
+	return parsed, nil
+}
+
+// parseJoinPolicy parses the join policy from the form, keeping the open enrollment window of the
+// previous policy unless the window is explicitly opened or closed.
+func parseJoinPolicy(
+	c echo.Context, networkID string, previous *ztjoins.Policy,
+) (ztjoins.Policy, error) {
+	policy := ztjoins.Policy{
+		NetworkID:       networkID,
+		RequireApproval: strings.ToLower(c.FormValue("require-approval")) == checkboxTrueValue,
+		Allowlist:       parseAllowlist(c.FormValue("allowlist")),
+	}
+	var err error
+	if policy.MaxAutoApprovals, err = parseNonNegativeInt(
+		c.FormValue("max-auto-approvals"), "maximum number of automatic authorizations",
+	); err != nil {
+		return ztjoins.Policy{}, err
+	}
+	enrollmentMinutes, err := parseNonNegativeInt(
+		c.FormValue("enrollment-minutes"), "open enrollment duration",
+	)
+	if err != nil {
+		return ztjoins.Policy{}, err
+	}
+
+	switch {
+	case strings.ToLower(c.FormValue("close-enrollment")) == checkboxTrueValue:
+		// The window is closed by leaving it empty
+	case enrollmentMinutes > 0:
+		policy.EnrollmentStart = time.Now()
+		duration := time.Duration(enrollmentMinutes) * time.Minute
+		policy.EnrollmentEnd = policy.EnrollmentStart.Add(duration)
+	case previous != nil:
+		policy.EnrollmentStart = previous.EnrollmentStart
+		policy.EnrollmentEnd = previous.EnrollmentEnd
+	}
+	return policy, nil
+}
+
+func (h *Handlers) HandleNetworkJoinsPost() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// Parse params
+		id := c.Param("id")
+		resetAutoApprovals := strings.ToLower(c.FormValue("reset-auto-approvals")) == checkboxTrueValue
+
+		// Run queries
+		ctx := c.Request().Context()
+		previous, err := h.ztj.GetPolicy(ctx, id)
+		if err != nil {
+			return err
+		}
+		policy, err := parseJoinPolicy(c, id, previous)
+		if err != nil {
+			return err
+		}
+		if err = h.ztj.SetPolicy(ctx, policy); err != nil {
+			return err
+		}
+		if resetAutoApprovals {
+			if err = h.ztj.ResetAutoApprovals(ctx, id); err != nil {
+				return err
+			}
+		}
+
+		// Redirect user
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/networks/%s/joins", id))
+	}
+}
+
+// Manual Join Approval
+
+func (h *Handlers) HandleNetworkJoinPost() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// Parse params
+		id := c.Param("id")
+		controllerAddress := ztc.GetControllerAddress(id)
+		memberAddress := c.Param("address")
+
+		// Run queries
+		ctx := c.Request().Context()
+		controller, err := h.ztcc.FindControllerByAddress(ctx, controllerAddress)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't find controller %s", controllerAddress)
+		}
+		if err = setMemberAuthorization(
+			ctx, *controller, id, memberAddress, true, time.Time{}, h.ztc, h.ztd,
+		); err != nil {
+			return err
+		}
+		now := time.Now()
+		if err = h.ztj.AddAuthorizedMember(ctx, id, memberAddress, now); err != nil {
+			return err
+		}
+		if err = h.ztj.RecordDecision(ctx, ztjoins.Decision{
+			NetworkID:     id,
+			MemberAddress: memberAddress,
+			Time:          now,
+			Accepted:      true,
+			Reason:        "the device was authorized manually",
+		}); err != nil {
+			return err
+		}
+
+		// Redirect user
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/networks/%s/joins", id))
+	}
+}
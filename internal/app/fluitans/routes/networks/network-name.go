@@ -0,0 +1,405 @@
+package networks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/client"
+	desecc "github.com/sargassum-world/fluitans/internal/clients/desec"
+	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
+	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/pkg/desec"
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+// Network Name
+
+// checkNetworkName checks whether the name is still available for naming a network by DNS.
+func checkNetworkName(ctx context.Context, name string, dc *desecc.Client) error {
+	txtRRset, err := dc.GetRRset(ctx, name, "TXT")
+	if err != nil {
+		return errors.Wrapf(
+			err, "couldn't check cache for DNS TXT RRset at %s.%s", name, dc.Config.DomainName,
+		)
+	}
+	if txtRRset != nil {
+		if _, hasID := client.GetNetworkID(txtRRset.Records); hasID {
+			return echo.NewHTTPError(
+				http.StatusBadRequest, "name is already used by another network",
+			)
+		}
+	}
+	return nil
+}
+
+func nameNetwork(
+	ctx context.Context, controller ztcontrollers.Controller, id string, name string,
+	c *ztc.Client, dc *desecc.Client,
+) (*zerotier.ControllerNetwork, error) {
+	if len(name) == 0 {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "cannot remove name from network")
+	}
+	if err := checkNetworkName(ctx, name, dc); err != nil {
+		return nil, errors.Wrapf(err, "couldn't check availability of name for network %s", id)
+	}
+
+	fqdn := name + "." + dc.Config.DomainName
+	ttl := c.Config.DNS.NetworkTTL
+	if _, err := dc.CreateRRset(
+		ctx, name, "TXT", ttl, []string{client.MakeNetworkIDRecord(id)},
+	); err != nil {
+		// TODO: if a TXT RRset already exists but doesn't have the ID, just append a
+		// zerotier-net-id=... record (but we should have a global lock on a get-and-patch to avoid
+		// data races)
+		// TODO: if the returned error code was an HTTP error, preserve the status code
+		return nil, errors.Wrapf(
+			err, "couldn't create a DNS TXT RRset at %s for network %s", fqdn, id,
+		)
+	}
+	body := zerotier.SetControllerNetworkJSONRequestBody{Name: &fqdn}
+	if c.Config.DNS.AutoSearchDomain {
+		network, err := c.GetNetwork(ctx, controller, id)
+		if err != nil {
+			return nil, errors.Wrapf(err, "couldn't get DNS settings of network %s", id)
+		}
+		body.Dns = newSearchDomain(network, fqdn)
+	}
+	return c.UpdateNetwork(ctx, controller, id, body)
+}
+
+// newSearchDomain makes DNS settings which change the network's search domain while keeping its
+// DNS servers, since the controller replaces both together.
+func newSearchDomain(network *zerotier.ControllerNetwork, domain string) *zerotier.DNS {
+	servers := []string{}
+	if network != nil && network.Dns != nil && network.Dns.Servers != nil {
+		servers = *network.Dns.Servers
+	}
+	return &zerotier.DNS{Domain: &domain, Servers: &servers}
+}
+
+// searchDomainFollowsName checks whether the network's search domain is the network's name, in
+// which case the search domain should change along with the name.
+func searchDomainFollowsName(network zerotier.ControllerNetwork) bool {
+	return network.Name != nil && *network.Name != "" &&
+		network.Dns != nil && network.Dns.Domain != nil && *network.Dns.Domain == *network.Name
+}
+
+func normalizeNetworkSubname(rawName string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(rawName)), ".")
+}
+
+func findRRset(rrsets []desec.RRset, recordType string) *desec.RRset {
+	for _, rrset := range rrsets {
+		if rrset.Type == recordType {
+			return &rrset
+		}
+	}
+	return nil
+}
+
+// newLinkedNetworkIDRRset makes a TXT rrset which adds a zerotier-net-id record for the network to
+// any existing TXT records at the subname.
+func newLinkedNetworkIDRRset(
+	id, subname string, subnameRRsets map[string][]desec.RRset, ttl int64,
+) desec.RRset {
+	records := []string{client.MakeNetworkIDRecord(id)}
+	if existing := findRRset(subnameRRsets[subname], "TXT"); existing != nil {
+		records = append(append([]string{}, existing.Records...), records...)
+	}
+	intTTL := int(ttl)
+	return desec.RRset{Subname: subname, Type: "TXT", Ttl: &intTTL, Records: records}
+}
+
+// newUnlinkedNetworkIDRRset makes a TXT rrset which removes the network's zerotier-net-id record
+// from the rrset, deleting the rrset if no other records remain in it.
+func newUnlinkedNetworkIDRRset(id string, rrset desec.RRset) desec.RRset {
+	records := make([]string, 0, len(rrset.Records))
+	for _, record := range rrset.Records {
+		if recordID, isIDRecord := client.ParseNetworkIDRecord(record); isIDRecord && recordID == id {
+			continue
+		}
+		records = append(records, record)
+	}
+	if len(records) == 0 {
+		return desecc.NewRRsetKey(rrset).AsDeletionUpsertRRset()
+	}
+	return desec.RRset{Subname: rrset.Subname, Type: rrset.Type, Ttl: rrset.Ttl, Records: records}
+}
+
+// getDeviceSubnames returns the subnames of the DNS records for devices on the network with the
+// provided subname.
+func getDeviceSubnames(
+	networkSubname string, subnameRRsets map[string][]desec.RRset,
+) []string {
+	subnames := make([]string, 0)
+	for subname := range subnameRRsets {
+		if strings.HasSuffix(subname, ".d."+networkSubname) {
+			subnames = append(subnames, subname)
+		}
+	}
+	sort.Strings(subnames)
+	return subnames
+}
+
+// getNamedNetwork looks up a network, its subname (or an empty string if it isn't named by DNS),
+// and all DNS records in the domain.
+func getNamedNetwork(
+	ctx context.Context, controller ztcontrollers.Controller, id string,
+	c *ztc.Client, dc *desecc.Client,
+) (
+	network *zerotier.ControllerNetwork, subname string, subnameRRsets map[string][]desec.RRset,
+	err error,
+) {
+	eg, egctx := errgroup.WithContext(ctx)
+	eg.Go(func() (err error) {
+		network, err = c.GetNetwork(egctx, controller, id)
+		return err
+	})
+	eg.Go(func() (err error) {
+		subnameRRsets, err = dc.GetRRsets(egctx)
+		return err
+	})
+	if err = eg.Wait(); err != nil {
+		return nil, "", nil, err
+	}
+	if network == nil {
+		return nil, "", nil, echo.NewHTTPError(http.StatusNotFound, "zerotier network not found")
+	}
+	if network.Name == nil || !client.NetworkNamedByDNS(
+		id, *network.Name, dc.Config.DomainName, subnameRRsets,
+	) {
+		return network, "", subnameRRsets, nil
+	}
+	return network, strings.TrimSuffix(*network.Name, "."+dc.Config.DomainName), subnameRRsets, nil
+}
+
+// renameNetwork moves the network's zerotier-net-id record and the DNS records of its devices
+// from the network's current subname to the new subname, in a single batch. If the network isn't
+// yet named by DNS, it's named instead.
+func renameNetwork(
+	ctx context.Context, controller ztcontrollers.Controller, id string, name string,
+	c *ztc.Client, dc *desecc.Client,
+) (*zerotier.ControllerNetwork, error) {
+	name = normalizeNetworkSubname(name)
+	if name == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "network name is required")
+	}
+	network, prevName, subnameRRsets, err := getNamedNetwork(ctx, controller, id, c, dc)
+	if err != nil {
+		return nil, err
+	}
+	if prevName == "" {
+		return nameNetwork(ctx, controller, id, name, c, dc)
+	}
+	if name == prevName {
+		return network, nil
+	}
+
+	// Move the zerotier-net-id record; if the new name is an alias, it already has the record
+	rrsets := make([]desec.RRset, 0)
+	rrsets = append(rrsets, newUnlinkedNetworkIDRRset(id, *findRRset(subnameRRsets[prevName], "TXT")))
+	nameID, named := "", false
+	if txtRRset := findRRset(subnameRRsets[name], "TXT"); txtRRset != nil {
+		nameID, named = client.GetNetworkID(txtRRset.Records)
+	}
+	switch {
+	case named && nameID != id:
+		return nil, echo.NewHTTPError(
+			http.StatusBadRequest, "name is already used by another network",
+		)
+	case !named:
+		rrsets = append(rrsets, newLinkedNetworkIDRRset(
+			id, name, subnameRRsets, c.Config.DNS.NetworkTTL,
+		))
+	}
+
+	// Move the DNS records of the network's devices
+	for _, subname := range getDeviceSubnames(prevName, subnameRRsets) {
+		movedSubname := strings.TrimSuffix(subname, prevName) + name
+		if len(subnameRRsets[movedSubname]) > 0 {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+				"DNS records already exist at %s.%s", movedSubname, dc.Config.DomainName,
+			))
+		}
+		for _, rrset := range subnameRRsets[subname] {
+			rrsets = append(
+				rrsets,
+				desec.RRset{
+					Subname: movedSubname, Type: rrset.Type, Ttl: rrset.Ttl, Records: rrset.Records,
+				},
+				desecc.NewRRsetKey(rrset).AsDeletionUpsertRRset(),
+			)
+		}
+	}
+	if _, err = dc.UpsertRRsets(ctx, rrsets...); err != nil {
+		return nil, errors.Wrapf(
+			err, "couldn't move DNS records of network %s from %s to %s", id, prevName, name,
+		)
+	}
+
+	fqdn := name + "." + dc.Config.DomainName
+	body := zerotier.SetControllerNetworkJSONRequestBody{Name: &fqdn}
+	if searchDomainFollowsName(*network) {
+		body.Dns = newSearchDomain(network, fqdn)
+	}
+	return c.UpdateNetwork(ctx, controller, id, body)
+}
+
+// unnameNetwork removes the network's zerotier-net-id records (including those of its aliases)
+// and the DNS records of its devices, in a single batch, and then removes the network's name.
+func unnameNetwork(
+	ctx context.Context, controller ztcontrollers.Controller, id string,
+	c *ztc.Client, dc *desecc.Client,
+) (*zerotier.ControllerNetwork, error) {
+	network, prevName, subnameRRsets, err := getNamedNetwork(ctx, controller, id, c, dc)
+	if err != nil {
+		return nil, err
+	}
+	if prevName == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "network isn't named by DNS")
+	}
+
+	records := getNetworkRecords(id, prevName, subnameRRsets)
+	if _, err = dc.UpsertRRsets(ctx, records.newRemovalRRsets(id, false)...); err != nil {
+		return nil, errors.Wrapf(err, "couldn't remove DNS records of network %s", id)
+	}
+
+	name := ""
+	body := zerotier.SetControllerNetworkJSONRequestBody{Name: &name}
+	if searchDomainFollowsName(*network) {
+		body.Dns = newSearchDomain(network, "")
+	}
+	return c.UpdateNetwork(ctx, controller, id, body)
+}
+
+func (h *Handlers) HandleNetworkNamePost() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// Parse params
+		id := c.Param("id")
+		address := ztc.GetControllerAddress(id)
+		state := c.FormValue("state")
+
+		// Run queries
+		ctx := c.Request().Context()
+		controller, err := h.ztcc.FindControllerByAddress(ctx, address)
+		if err != nil {
+			return err
+		}
+		switch state {
+		default:
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+				"invalid network name state %s", state,
+			))
+		case "named":
+			if _, err = renameNetwork(
+				ctx, *controller, id, c.FormValue("name"), h.ztc, h.dc,
+			); err != nil {
+				return err
+			}
+		case "unnamed":
+			if _, err = unnameNetwork(ctx, *controller, id, h.ztc, h.dc); err != nil {
+				return err
+			}
+		}
+
+		// Redirect user
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/networks/%s", id))
+	}
+}
+
+// Network Aliases
+
+// setNetworkAliases adds and removes zerotier-net-id records for the network's aliases so that the
+// network has exactly the provided aliases, in a single batch.
+func setNetworkAliases(
+	ctx context.Context, controller ztcontrollers.Controller, id string, aliases []string,
+	c *ztc.Client, dc *desecc.Client,
+) error {
+	_, name, subnameRRsets, err := getNamedNetwork(ctx, controller, id, c, dc)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return echo.NewHTTPError(
+			http.StatusBadRequest, "network must be named by DNS before it can have aliases",
+		)
+	}
+	txtRecords, err := client.GetRecordsOfType(subnameRRsets, "TXT")
+	if err != nil {
+		return err
+	}
+	prevAliases := make(map[string]bool)
+	for _, alias := range identifyNetworkAliases(id, name, txtRecords) {
+		prevAliases[alias] = true
+	}
+
+	rrsets := make([]desec.RRset, 0)
+	keptAliases := make(map[string]bool)
+	for _, alias := range aliases {
+		if alias = normalizeNetworkSubname(alias); alias == "" || keptAliases[alias] {
+			continue
+		}
+		keptAliases[alias] = true
+		if prevAliases[alias] {
+			continue
+		}
+		if alias == name {
+			return echo.NewHTTPError(http.StatusBadRequest, "alias is already the network's name")
+		}
+		if aliasID, hasID := client.GetNetworkID(txtRecords[alias]); hasID && aliasID != id {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+				"alias %s is already used by another network", alias,
+			))
+		}
+		rrsets = append(rrsets, newLinkedNetworkIDRRset(
+			id, alias, subnameRRsets, c.Config.DNS.NetworkTTL,
+		))
+	}
+	for alias := range prevAliases {
+		if !keptAliases[alias] {
+			rrsets = append(rrsets, newUnlinkedNetworkIDRRset(
+				id, *findRRset(subnameRRsets[alias], "TXT"),
+			))
+		}
+	}
+	if len(rrsets) == 0 {
+		return nil
+	}
+	if _, err = dc.UpsertRRsets(ctx, rrsets...); err != nil {
+		return errors.Wrapf(err, "couldn't update DNS records for aliases of network %s", id)
+	}
+	return nil
+}
+
+func (h *Handlers) HandleNetworkAliasesPost() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// Parse params
+		id := c.Param("id")
+		address := ztc.GetControllerAddress(id)
+		formParams, err := c.FormParams()
+		if err != nil {
+			return errors.Wrap(err, "couldn't parse form params")
+		}
+		aliases := append(formParams["existing-aliases"], c.FormValue("new-alias"))
+
+		// Run queries
+		ctx := c.Request().Context()
+		controller, err := h.ztcc.FindControllerByAddress(ctx, address)
+		if err != nil {
+			return err
+		}
+		if err = setNetworkAliases(ctx, *controller, id, aliases, h.ztc, h.dc); err != nil {
+			return err
+		}
+
+		// Redirect user
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/networks/%s", id))
+	}
+}
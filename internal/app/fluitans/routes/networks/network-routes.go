@@ -0,0 +1,192 @@
+package networks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/sargassum-world/godest/turbostreams"
+
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/auth"
+	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
+	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+// Network-Managed Routes
+
+// parseRoute parses a managed route. Without a gateway, the route's target is directly on the
+// network; otherwise, the target is reached via the gateway.
+func parseRoute(rawTarget, rawVia string) (zerotier.Route, error) {
+	rawTarget = strings.TrimSpace(rawTarget)
+	target, err := netip.ParsePrefix(rawTarget)
+	if err != nil {
+		return zerotier.Route{}, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"invalid route target %s", rawTarget,
+		))
+	}
+	sanitizedTarget := target.String()
+	route := zerotier.Route{Target: &sanitizedTarget}
+	if rawVia = strings.TrimSpace(rawVia); rawVia == "" {
+		return route, nil
+	}
+
+	via, err := netip.ParseAddr(rawVia)
+	if err != nil {
+		return zerotier.Route{}, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"invalid gateway %s for route %s", rawVia, sanitizedTarget,
+		))
+	}
+	if via.Is4() != target.Addr().Is4() {
+		return zerotier.Route{}, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"gateway %s must have the same IP version as route %s", via, sanitizedTarget,
+		))
+	}
+	sanitizedVia := via.String()
+	route.Via = &sanitizedVia
+	return route, nil
+}
+
+// checkRouteGateways checks that every gateway is inside one of the network's direct routes, since
+// devices must be able to reach the gateways over the network.
+func checkRouteGateways(routes []zerotier.Route) error {
+	direct := make([]netip.Prefix, 0, len(routes))
+	for _, route := range routes {
+		if route.Via != nil && *route.Via != "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(*route.Target)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't parse route target %s", *route.Target)
+		}
+		direct = append(direct, prefix)
+	}
+	for _, route := range routes {
+		if route.Via == nil || *route.Via == "" {
+			continue
+		}
+		via, err := netip.ParseAddr(*route.Via)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't parse gateway %s", *route.Via)
+		}
+		reachable := false
+		for _, prefix := range direct {
+			if prefix.Contains(via) {
+				reachable = true
+				break
+			}
+		}
+		if !reachable {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+				"gateway %s of route %s isn't inside any of the network's own routes",
+				*route.Via, *route.Target,
+			))
+		}
+	}
+	return nil
+}
+
+func setNetworkRoutes(
+	ctx context.Context, controller ztcontrollers.Controller,
+	id string, routes []zerotier.Route, c *ztc.Client,
+) (*zerotier.ControllerNetwork, error) {
+	network, err := c.UpdateNetwork(
+		ctx, controller, id, zerotier.SetControllerNetworkJSONRequestBody{Routes: &routes},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return network, nil
+}
+
+func (h *Handlers) HandleNetworkRoutesPost() auth.HTTPHandlerFunc {
+	t := "networks/network-routes.partial.tmpl"
+	h.r.MustHave(t)
+	tPools := "networks/network-autoip-pools.partial.tmpl"
+	h.r.MustHave(tPools)
+	return func(c echo.Context, a auth.Auth) error {
+		// Parse params
+		id := c.Param("id")
+		address := ztc.GetControllerAddress(id)
+		formParams, err := c.FormParams()
+		if err != nil {
+			return errors.Wrap(err, "couldn't parse form params")
+		}
+		routes := make([]zerotier.Route, 0, len(formParams["existing-routes"])+1)
+		// Existing routes are identified by their indices in the form, so that their targets and
+		// gateways can be edited together
+		for _, i := range formParams["existing-routes"] {
+			route, err := parseRoute(
+				c.FormValue("existing-target-"+i), c.FormValue("existing-via-"+i),
+			)
+			if err != nil {
+				return err
+			}
+			routes = append(routes, route)
+		}
+		if newTarget := c.FormValue("new-target"); strings.TrimSpace(newTarget) != "" {
+			route, err := parseRoute(newTarget, c.FormValue("new-via"))
+			if err != nil {
+				return err
+			}
+			routes = append(routes, route)
+		}
+		supernet, bits, allocationRequested, err := parseAllocationRequest(
+			c.FormValue("supernet"), c.FormValue("prefix-length"),
+		)
+		if err != nil {
+			return err
+		}
+
+		// Run queries
+		ctx := c.Request().Context()
+		controller, err := h.ztcc.FindControllerByAddress(ctx, address)
+		if err != nil {
+			return err
+		}
+		if allocationRequested {
+			subnet, err := allocatePrefix(ctx, supernet, bits, h.ztc, h.ztcc)
+			if err != nil {
+				return err
+			}
+			target := subnet.String()
+			routes = append(routes, zerotier.Route{Target: &target})
+		}
+		if err = checkRouteGateways(routes); err != nil {
+			return err
+		}
+		network, err := setNetworkRoutes(ctx, *controller, id, routes, h.ztc)
+		if err != nil {
+			return err
+		}
+
+		// Render Turbo Stream if accepted
+		if turbostreams.Accepted(c.Request().Header) {
+			// TODO: also broadcast this message over Turbo Streams, and have web browsers subscribe to it
+			assignmentPools, err := parseAssignmentPools(*network.Routes, *network.IpAssignmentPools)
+			if err != nil {
+				return err
+			}
+			return h.r.TurboStream(
+				c.Response(),
+				turbostreams.Message{
+					Action:   turbostreams.ActionReplace,
+					Target:   "/networks/" + id + "/routes",
+					Template: t,
+					Data: map[string]interface{}{
+						"Network": network,
+						"Auth":    a,
+					},
+				},
+				replaceAutoIPPoolsStream(id, network, assignmentPools, a),
+			)
+		}
+
+		// Redirect user
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/networks/%s#/networks/%s/routes", id, id))
+	}
+}
@@ -18,6 +18,8 @@ import (
 	desecc "github.com/sargassum-world/fluitans/internal/clients/desec"
 	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
 	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/internal/clients/ztdevices"
+	"github.com/sargassum-world/fluitans/internal/clients/zttags"
 	"github.com/sargassum-world/fluitans/pkg/desec"
 	"github.com/sargassum-world/fluitans/pkg/zerotier"
 )
@@ -46,11 +48,34 @@ func identifyNetworkAliases(
 	return aliases
 }
 
+func identifyReplacementNetwork(
+	networkID, networkName, domainName string, subnameRRsets map[string][]desec.RRset,
+) string {
+	domainSuffix := "." + domainName
+	if !strings.HasSuffix(networkName, domainSuffix) {
+		return ""
+	}
+
+	subname := strings.TrimSuffix(networkName, domainSuffix)
+	for _, rrset := range subnameRRsets[subname] {
+		if rrset.Type != "TXT" {
+			continue
+		}
+		if id, hasID := client.GetNetworkID(rrset.Records); hasID && id != networkID {
+			return id
+		}
+	}
+	return ""
+}
+
 type NetworkDNS struct {
 	Named            bool
 	Aliases          []string
 	DeviceSubdomains map[string]client.Subdomain
 	OtherSubdomains  []client.Subdomain
+	// ReplacedBy is the ID of the network now named by the network's domain name, if another network
+	// (e.g. a migrated copy of the network) took over the domain name
+	ReplacedBy string
 }
 
 func getNetworkDNSRecords(
@@ -58,7 +83,11 @@ func getNetworkDNSRecords(
 	c *ztc.Client, cc *ztcontrollers.Client, dc *desecc.Client,
 ) (networkDNS NetworkDNS, err error) {
 	if !client.NetworkNamedByDNS(networkID, networkName, dc.Config.DomainName, subnameRRsets) {
-		return NetworkDNS{}, nil
+		return NetworkDNS{
+			ReplacedBy: identifyReplacementNetwork(
+				networkID, networkName, dc.Config.DomainName, subnameRRsets,
+			),
+		}, nil
 	}
 	networkDNS.Named = true
 
@@ -100,12 +129,19 @@ func getNetworkDNSRecords(
 
 type NetworkViewData struct {
 	Controller       ztcontrollers.Controller
+	Controllers      []ztcontrollers.Controller
 	Network          zerotier.ControllerNetwork
 	Members          []client.Member
 	AssignmentPools  []AssignmentPool
+	Rules            []zerotier.Rule
 	JSONPrintedRules string
-	DomainName       string
-	NetworkDNS       NetworkDNS
+	RulesSource      string
+	// RulesSourceMessage explains why the rules couldn't be decompiled into RulesSource
+	RulesSourceMessage string
+	Capabilities       []zerotier.Capability
+	Tags               []NetworkTag
+	DomainName         string
+	NetworkDNS         NetworkDNS
 }
 
 func printJSONRules(rawRules []map[string]interface{}) (string, error) {
@@ -116,9 +152,44 @@ func printJSONRules(rawRules []map[string]interface{}) (string, error) {
 	return string(rules), err
 }
 
+// printSourceRules decompiles the network's rules, capabilities, and tags into the rules language.
+// If they can't be expressed in the rules language, a message explaining why is returned instead.
+func printSourceRules(
+	ctx context.Context, network zerotier.ControllerNetwork, tc *zttags.Client,
+) (source, message string, err error) {
+	var rules []zerotier.Rule
+	if network.Rules != nil {
+		if rules, err = zerotier.NewRules(*network.Rules); err != nil {
+			return "", "", err
+		}
+	}
+	var capabilities []zerotier.Capability
+	if network.Capabilities != nil {
+		if capabilities, err = zerotier.NewCapabilities(*network.Capabilities); err != nil {
+			return "", "", err
+		}
+	}
+	var tags []zerotier.Tag
+	if network.Tags != nil {
+		if tags, err = zerotier.NewTags(*network.Tags); err != nil {
+			return "", "", err
+		}
+	}
+	names, err := tc.GetRuleNames(ctx, *network.Id)
+	if err != nil {
+		return "", "", err
+	}
+	source, err = zerotier.DecompileRules(rules, capabilities, tags, names)
+	if err != nil {
+		return "", err.Error(), nil
+	}
+	return source, "", nil
+}
+
 func getNetworkViewData(
 	ctx context.Context, address, id string,
-	c *ztc.Client, cc *ztcontrollers.Client, dc *desecc.Client,
+	c *ztc.Client, cc *ztcontrollers.Client, dc *desecc.Client, tc *zttags.Client,
+	mc *ztdevices.Client,
 ) (vd NetworkViewData, err error) {
 	controller, err := cc.FindControllerByAddress(ctx, address)
 	if err != nil {
@@ -128,6 +199,9 @@ func getNetworkViewData(
 		return NetworkViewData{}, echo.NewHTTPError(http.StatusNotFound, "controller not found")
 	}
 	vd.Controller = *controller
+	if vd.Controllers, err = cc.GetControllers(ctx); err != nil {
+		return NetworkViewData{}, err
+	}
 
 	eg, egctx := errgroup.WithContext(ctx)
 	var network *zerotier.ControllerNetwork
@@ -153,17 +227,38 @@ func getNetworkViewData(
 	); err != nil {
 		return NetworkViewData{}, err
 	}
+	if vd.Rules, err = zerotier.NewRules(*network.Rules); err != nil {
+		return NetworkViewData{}, err
+	}
 	if vd.JSONPrintedRules, err = printJSONRules(*network.Rules); err != nil {
 		return NetworkViewData{}, err
 	}
+	if vd.RulesSource, vd.RulesSourceMessage, err = printSourceRules(
+		ctx, *network, tc,
+	); err != nil {
+		return NetworkViewData{}, err
+	}
+	if vd.Capabilities, err = getNetworkCapabilities(*network); err != nil {
+		return NetworkViewData{}, err
+	}
+	if vd.Tags, err = getNetworkTags(ctx, *network, tc); err != nil {
+		return NetworkViewData{}, err
+	}
 
 	eg, egctx = errgroup.WithContext(ctx)
 	eg.Go(func() (err error) {
 		members, err := client.GetMemberRecords(
 			egctx, dc.Config.DomainName, *controller, *network, memberAddresses, subnameRRsets, c,
 		)
+		if err != nil {
+			return err
+		}
+		client.AddMemberPeers(egctx, *controller, members, c)
+		if err = client.AddMemberMetadata(egctx, id, members, mc); err != nil {
+			return err
+		}
 		_, vd.Members = client.SortNetworkMembers(members)
-		return err
+		return nil
 	})
 	eg.Go(func() (err error) {
 		vd.NetworkDNS, err = getNetworkDNSRecords(
@@ -191,7 +286,7 @@ func (h *Handlers) HandleNetworkGet() auth.HTTPHandlerFunc {
 
 		// Run queries
 		networkViewData, err := getNetworkViewData(
-			c.Request().Context(), address, id, h.ztc, h.ztcc, h.dc,
+			c.Request().Context(), address, id, h.ztc, h.ztcc, h.dc, h.ztg, h.ztd,
 		)
 		if err != nil {
 			return err
@@ -208,6 +303,7 @@ func (h *Handlers) HandleNetworkPost() echo.HandlerFunc {
 		id := c.Param("id")
 		address := ztc.GetControllerAddress(id)
 		state := c.FormValue("state")
+		tombstone := strings.ToLower(c.FormValue("tombstone")) == checkboxTrueValue
 
 		// Run queries
 		ctx := c.Request().Context()
@@ -221,8 +317,9 @@ func (h *Handlers) HandleNetworkPost() echo.HandlerFunc {
 			if err != nil {
 				return err
 			}
-			if err = h.ztc.DeleteNetwork(ctx, *controller, id, h.ztcc); err != nil {
-				// TODO: add a tombstone to the TXT RRset?
+			if err = deleteNetwork(
+				ctx, *controller, id, tombstone, h.ztc, h.ztcc, h.dc, h.ztd, h.ztj,
+			); err != nil {
 				return err
 			}
 			h.tsh.Cancel("/networks/" + id + "/devices")
@@ -233,162 +330,62 @@ func (h *Handlers) HandleNetworkPost() echo.HandlerFunc {
 	}
 }
 
-// Network Name
+// Network Rules
 
-func nameNetwork(
-	ctx context.Context, controller ztcontrollers.Controller, id string, name string,
-	c *ztc.Client, dc *desecc.Client,
+func setNetworkRules(
+	ctx context.Context, controller ztcontrollers.Controller,
+	id string, jsonRules string, c *ztc.Client,
 ) (*zerotier.ControllerNetwork, error) {
-	if len(name) == 0 {
-		return nil, echo.NewHTTPError(http.StatusBadRequest, "cannot remove name from network")
-	}
-
-	// Check to see if the network was already named by DNS
-	fqdn := name + "." + dc.Config.DomainName
-	txtRRset, err := dc.GetRRset(ctx, name, "TXT")
+	parsedRules, err := zerotier.ParseRules([]byte(jsonRules))
 	if err != nil {
-		return nil, errors.Wrapf(
-			err, "couldn't check cache for DNS TXT RRset at %s for network %s", fqdn, id,
-		)
-	}
-	if txtRRset != nil {
-		if _, hasID := client.GetNetworkID(txtRRset.Records); hasID {
-			return nil, echo.NewHTTPError(
-				http.StatusBadRequest, "name is already used by another network",
-			)
-		}
+		return nil, err
 	}
-
-	ttl := c.Config.DNS.NetworkTTL
-	if _, err := dc.CreateRRset(
-		ctx, name, "TXT", ttl, []string{client.MakeNetworkIDRecord(id)},
-	); err != nil {
-		// TODO: if a TXT RRset already exists but doesn't have the ID, just append a
-		// zerotier-net-id=... record (but we should have a global lock on a get-and-patch to avoid
-		// data races)
-		// TODO: if the returned error code was an HTTP error, preserve the status code
-		return nil, errors.Wrapf(
-			err, "couldn't create a DNS TXT RRset at %s for network %s", fqdn, id,
-		)
+	rules, err := zerotier.RawRules(parsedRules)
+	if err != nil {
+		return nil, err
 	}
-	return c.UpdateNetwork(
-		ctx, controller, id, zerotier.SetControllerNetworkJSONRequestBody{Name: &fqdn},
+	network, err := c.UpdateNetwork(
+		ctx, controller, id, zerotier.SetControllerNetworkJSONRequestBody{Rules: &rules},
 	)
-}
-
-func (h *Handlers) HandleNetworkNamePost() echo.HandlerFunc {
-	return func(c echo.Context) error {
-		// Parse params
-		id := c.Param("id")
-		address := ztc.GetControllerAddress(id)
-
-		// Run queries
-		ctx := c.Request().Context()
-		controller, err := h.ztcc.FindControllerByAddress(ctx, address)
-		if err != nil {
-			return err
-		}
-		if _, err = nameNetwork(ctx, *controller, id, c.FormValue("name"), h.ztc, h.dc); err != nil {
-			return err
-		}
-
-		// Redirect user
-		return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/networks/%s", id))
+	if err != nil {
+		return nil, err
 	}
+	return network, nil
 }
 
-// Network-Managed Routes
-
-func setNetworkRoutes(
+func setNetworkRulesSource(
 	ctx context.Context, controller ztcontrollers.Controller,
-	id string, targets []string, c *ztc.Client,
+	id string, source string, c *ztc.Client, tc *zttags.Client,
 ) (*zerotier.ControllerNetwork, error) {
-	routes := make([]zerotier.Route, len(targets))
-	for i := range targets {
-		sanitized := strings.TrimSpace(targets[i])
-		routes[i].Target = &sanitized
+	compiled, err := zerotier.CompileRules(source)
+	if err != nil {
+		return nil, err
 	}
-	network, err := c.UpdateNetwork(
-		ctx, controller, id, zerotier.SetControllerNetworkJSONRequestBody{Routes: &routes},
-	)
+	rules, err := zerotier.RawRules(compiled.Rules)
 	if err != nil {
 		return nil, err
 	}
-	return network, nil
-}
-
-func (h *Handlers) HandleNetworkRoutesPost() auth.HTTPHandlerFunc {
-	t := "networks/network-routes.partial.tmpl"
-	h.r.MustHave(t)
-	tPools := "networks/network-autoip-pools.partial.tmpl"
-	h.r.MustHave(tPools)
-	return func(c echo.Context, a auth.Auth) error {
-		// Parse params
-		id := c.Param("id")
-		address := ztc.GetControllerAddress(id)
-		formParams, err := c.FormParams()
-		if err != nil {
-			return errors.Wrap(err, "couldn't parse form params")
-		}
-
-		// Run queries
-		ctx := c.Request().Context()
-		controller, err := h.ztcc.FindControllerByAddress(ctx, address)
-		if err != nil {
-			return err
-		}
-		targets := formParams["existing-targets"]
-		if newTarget := c.FormValue("new-target"); len(newTarget) > 0 {
-			targets = append(targets, newTarget)
-		}
-		network, err := setNetworkRoutes(ctx, *controller, id, targets, h.ztc)
-		if err != nil {
-			return err
-		}
-
-		// Render Turbo Stream if accepted
-		if turbostreams.Accepted(c.Request().Header) {
-			// TODO: also broadcast this message over Turbo Streams, and have web browsers subscribe to it
-			assignmentPools, err := parseAssignmentPools(*network.Routes, *network.IpAssignmentPools)
-			if err != nil {
-				return err
-			}
-			return h.r.TurboStream(
-				c.Response(),
-				turbostreams.Message{
-					Action:   turbostreams.ActionReplace,
-					Target:   "/networks/" + id + "/routes",
-					Template: t,
-					Data: map[string]interface{}{
-						"Network": network,
-						"Auth":    a,
-					},
-				},
-				replaceAutoIPPoolsStream(id, network, assignmentPools, a),
-			)
-		}
-
-		// Redirect user
-		return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/networks/%s#/networks/%s/routes", id, id))
+	capabilities, err := zerotier.RawCapabilities(compiled.Capabilities)
+	if err != nil {
+		return nil, err
 	}
-}
-
-// Network Rules
-
-func setNetworkRules(
-	ctx context.Context, controller ztcontrollers.Controller,
-	id string, jsonRules string, c *ztc.Client,
-) (*zerotier.ControllerNetwork, error) {
-	rules := make([]map[string]interface{}, 0)
-	if err := json.Unmarshal([]byte(jsonRules), &rules); err != nil {
+	tags, err := zerotier.RawTags(compiled.Tags)
+	if err != nil {
 		return nil, err
 	}
+	// The rules language defines all of the network's capabilities and tags, so any capabilities
+	// and tags which aren't in the source are removed
 	network, err := c.UpdateNetwork(
-		ctx, controller, id, zerotier.SetControllerNetworkJSONRequestBody{Rules: &rules},
+		ctx, controller, id, zerotier.SetControllerNetworkJSONRequestBody{
+			Rules: &rules, Capabilities: &capabilities, Tags: &tags,
+		},
 	)
 	if err != nil {
 		return nil, err
 	}
+	if err = tc.SetRuleNames(ctx, id, compiled.Names); err != nil {
+		return nil, err
+	}
 	return network, nil
 }
 
@@ -399,6 +396,9 @@ func (h *Handlers) HandleNetworkRulesPost() auth.HTTPHandlerFunc {
 		// Parse params
 		id := c.Param("id")
 		address := ztc.GetControllerAddress(id)
+		format := c.FormValue("format")
+		jsonRules := c.FormValue("rules")
+		source := c.FormValue("source")
 
 		// Run queries
 		ctx := c.Request().Context()
@@ -406,28 +406,69 @@ func (h *Handlers) HandleNetworkRulesPost() auth.HTTPHandlerFunc {
 		if err != nil {
 			return err
 		}
-		network, err := setNetworkRules(
-			ctx, *controller, id, c.FormValue("rules"), h.ztc,
-		)
+		var network *zerotier.ControllerNetwork
+		switch format {
+		default:
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+				"invalid rules format %s", format,
+			))
+		case "", "json":
+			network, err = setNetworkRules(ctx, *controller, id, jsonRules, h.ztc)
+		case "source":
+			network, err = setNetworkRulesSource(ctx, *controller, id, source, h.ztc, h.ztg)
+		}
+		var ruleErrors zerotier.RuleErrors
+		var compileError *zerotier.CompileError
 		if err != nil {
-			return err
+			var sourceError zerotier.CompileError
+			if errors.As(err, &sourceError) {
+				compileError = &sourceError
+			} else if !errors.As(err, &ruleErrors) {
+				return err
+			}
+			if !turbostreams.Accepted(c.Request().Header) {
+				return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid rules: %s", err))
+			}
+			// Show the problems next to the rules entered by the user, so that they can be fixed
+			if network, err = h.ztc.GetNetwork(ctx, *controller, id); err != nil {
+				return err
+			}
+			if network == nil {
+				return echo.NewHTTPError(http.StatusNotFound, "zerotier network not found")
+			}
 		}
 
 		// Render Turbo Stream if accepted
 		if turbostreams.Accepted(c.Request().Header) {
-			rules, err := printJSONRules(*network.Rules)
+			rules, err := zerotier.NewRules(*network.Rules)
 			if err != nil {
 				return err
 			}
+			if ruleErrors == nil {
+				if jsonRules, err = printJSONRules(*network.Rules); err != nil {
+					return err
+				}
+			}
+			sourceMessage := ""
+			if compileError == nil {
+				if source, sourceMessage, err = printSourceRules(ctx, *network, h.ztg); err != nil {
+					return err
+				}
+			}
 			// TODO: also broadcast this message over Turbo Streams, and have web browsers subscribe to it
 			return h.r.TurboStream(c.Response(), turbostreams.Message{
 				Action:   turbostreams.ActionReplace,
 				Target:   "/networks/" + id + "/rules",
 				Template: t,
 				Data: map[string]interface{}{
-					"Network":          network,
-					"Auth":             a,
-					"JSONPrintedRules": rules,
+					"Network":            network,
+					"Rules":              rules,
+					"RuleErrors":         ruleErrors,
+					"CompileError":       compileError,
+					"Auth":               a,
+					"JSONPrintedRules":   jsonRules,
+					"RulesSource":        source,
+					"RulesSourceMessage": sourceMessage,
 				},
 			})
 		}
@@ -4,24 +4,36 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
 
 	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
 
 	"github.com/sargassum-world/fluitans/internal/app/fluitans/auth"
+	desecc "github.com/sargassum-world/fluitans/internal/clients/desec"
 	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
 	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/internal/clients/zttemplates"
 	"github.com/sargassum-world/fluitans/pkg/zerotier"
 )
 
 type NetworksViewData struct {
 	Controller ztcontrollers.Controller
 	Networks   map[string]zerotier.ControllerNetwork
+	Templates  []zttemplates.Template
 }
 
 func getNetworksViewData(
-	ctx context.Context, c *ztc.Client, cc *ztcontrollers.Client,
+	ctx context.Context, c *ztc.Client, cc *ztcontrollers.Client, tc *zttemplates.Client,
 ) ([]NetworksViewData, error) {
-	controllers, err := cc.GetControllers()
+	controllers, err := cc.GetControllers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := tc.GetTemplates(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -40,6 +52,7 @@ func getNetworksViewData(
 	for i, controller := range controllers {
 		networksViewData[i].Controller = controller
 		networksViewData[i].Networks = networks[i]
+		networksViewData[i].Templates = templates
 	}
 	return networksViewData, nil
 }
@@ -49,7 +62,9 @@ func (h *Handlers) HandleNetworksGet() auth.HTTPHandlerFunc {
 	h.r.MustHave(t)
 	return func(c echo.Context, a auth.Auth) error {
 		// Run queries
-		networksViewData, err := getNetworksViewData(c.Request().Context(), h.ztc, h.ztcc)
+		networksViewData, err := getNetworksViewData(
+			c.Request().Context(), h.ztc, h.ztcc, h.ztt,
+		)
 		if err != nil {
 			return err
 		}
@@ -59,37 +74,136 @@ func (h *Handlers) HandleNetworksGet() auth.HTTPHandlerFunc {
 	}
 }
 
+func (h *Handlers) findTemplate(ctx context.Context, rawID string) (*zttemplates.Template, error) {
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		return nil, echo.NewHTTPError(
+			http.StatusBadRequest, fmt.Sprintf("invalid network template id %s", rawID),
+		)
+	}
+	template, err := h.ztt.GetTemplate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if template == nil {
+		return nil, echo.NewHTTPError(
+			http.StatusNotFound, fmt.Sprintf("network template with id %d not found", id),
+		)
+	}
+	return template, nil
+}
+
+// findNewNetworkTemplate finds the chosen template for a new network, or the default template if
+// no template was chosen.
+func (h *Handlers) findNewNetworkTemplate(
+	ctx context.Context, rawID string,
+) (*zttemplates.Template, error) {
+	if rawID != "" {
+		return h.findTemplate(ctx, rawID)
+	}
+	template, err := h.ztt.FindTemplate(ctx, zttemplates.DefaultTemplateName)
+	if err != nil {
+		return nil, err
+	}
+	if template == nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"no network template was chosen, and there's no network template named %s",
+			zttemplates.DefaultTemplateName,
+		))
+	}
+	return template, nil
+}
+
+func createNetwork(
+	ctx context.Context, controller ztcontrollers.Controller,
+	template zttemplates.Template, name string,
+	c *ztc.Client, cc *ztcontrollers.Client, dc *desecc.Client,
+) (*zerotier.ControllerNetwork, error) {
+	if !template.NameByDNS {
+		return c.CreateNetworkWithConfig(ctx, controller, template.NewNetworkRequest(name), cc)
+	}
+	if name == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"network template %s requires a name for the network", template.Name,
+		))
+	}
+	// We check the name before creating the network, to avoid leaving behind an unnamed network
+	if err := checkNetworkName(ctx, name, dc); err != nil {
+		return nil, err
+	}
+	network, err := c.CreateNetworkWithConfig(ctx, controller, template.NewNetworkRequest(""), cc)
+	if err != nil || network == nil || network.Id == nil {
+		return network, err
+	}
+	named, err := nameNetwork(ctx, controller, *network.Id, name, c, dc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't name new network %s as %s", *network.Id, name)
+	}
+	return named, nil
+}
+
 func (h *Handlers) HandleNetworksPost() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		// Parse params
-		name := c.FormValue("controller")
-		if name == "" {
+		controllerName := c.FormValue("controller")
+		if controllerName == "" {
 			return echo.NewHTTPError(
 				http.StatusBadRequest, "zerotier controller name not specified",
 			)
 		}
+		rawTemplateID := c.FormValue("template")
+		name := strings.TrimSpace(c.FormValue("name"))
+		supernet, bits, allocationRequested, err := parseAllocationRequest(
+			c.FormValue("supernet"), c.FormValue("prefix-length"),
+		)
+		if err != nil {
+			return err
+		}
 
 		// Run queries
-		controller, err := h.ztcc.FindController(name)
+		ctx := c.Request().Context()
+		controller, err := h.ztcc.FindController(ctx, controllerName)
 		if err != nil {
 			return err
 		}
 		if controller == nil {
 			return echo.NewHTTPError(
-				http.StatusNotFound, fmt.Sprintf("zerotier controller %s not found", name),
+				http.StatusNotFound, fmt.Sprintf("zerotier controller %s not found", controllerName),
 			)
 		}
+		template, err := h.findNewNetworkTemplate(ctx, rawTemplateID)
+		if err != nil {
+			return err
+		}
+		var subnet netip.Prefix
+		if allocationRequested {
+			// We allocate the subnet before creating the network, to avoid leaving behind a network
+			// without a subnet
+			if subnet, err = allocatePrefix(ctx, supernet, bits, h.ztc, h.ztcc); err != nil {
+				return err
+			}
+		}
 
-		createdNetwork, err := h.ztc.CreateNetwork(c.Request().Context(), *controller, h.ztcc)
+		createdNetwork, err := createNetwork(
+			ctx, *controller, *template, name, h.ztc, h.ztcc, h.dc,
+		)
 		if err != nil {
 			return err
 		}
-		created := createdNetwork.Id
-		if created == nil {
+		if createdNetwork == nil || createdNetwork.Id == nil {
 			return echo.NewHTTPError(http.StatusInternalServerError, "network status unknown")
 		}
+		if allocationRequested {
+			if _, err = addNetworkSubnet(
+				ctx, *controller, *createdNetwork, subnet, h.ztc,
+			); err != nil {
+				return errors.Wrapf(
+					err, "couldn't add subnet %s to new network %s", subnet, *createdNetwork.Id,
+				)
+			}
+		}
 
 		// Redirect user
-		return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/networks/%s", *created))
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/networks/%s", *createdNetwork.Id))
 	}
 }
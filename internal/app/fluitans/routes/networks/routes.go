@@ -11,6 +11,11 @@ import (
 	"github.com/sargassum-world/fluitans/internal/clients/desec"
 	"github.com/sargassum-world/fluitans/internal/clients/zerotier"
 	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/internal/clients/ztdevices"
+	"github.com/sargassum-world/fluitans/internal/clients/zthistory"
+	"github.com/sargassum-world/fluitans/internal/clients/ztjoins"
+	"github.com/sargassum-world/fluitans/internal/clients/zttags"
+	"github.com/sargassum-world/fluitans/internal/clients/zttemplates"
 )
 
 type Handlers struct {
@@ -21,11 +26,17 @@ type Handlers struct {
 	dc   *desec.Client
 	ztc  *zerotier.Client
 	ztcc *ztcontrollers.Client
+	ztt  *zttemplates.Client
+	ztg  *zttags.Client
+	zth  *zthistory.Client
+	ztd  *ztdevices.Client
+	ztj  *ztjoins.Client
 }
 
 func New(
 	r godest.TemplateRenderer, tsh *turbostreams.Hub,
-	dc *desec.Client, ztc *zerotier.Client, ztcc *ztcontrollers.Client,
+	dc *desec.Client, ztc *zerotier.Client, ztcc *ztcontrollers.Client, ztt *zttemplates.Client,
+	ztg *zttags.Client, zth *zthistory.Client, ztd *ztdevices.Client, ztj *ztjoins.Client,
 ) *Handlers {
 	return &Handlers{
 		r:    r,
@@ -33,6 +44,11 @@ func New(
 		dc:   dc,
 		ztc:  ztc,
 		ztcc: ztcc,
+		ztt:  ztt,
+		ztg:  ztg,
+		zth:  zth,
+		ztd:  ztd,
+		ztj:  ztj,
 	}
 }
 
@@ -42,22 +58,55 @@ func (h *Handlers) Register(er godest.EchoRouter, tsr turbostreams.Router, ss *s
 	tsaz := auth.RequireTSAuthz(ss)
 	hr.GET("/networks", h.HandleNetworksGet())
 	er.POST("/networks", h.HandleNetworksPost(), haz)
+	er.GET("/networks/export", h.HandleNetworksExportGet(), haz)
+	hr.GET("/networks/import", h.HandleNetworksImportGet(), haz)
+	hr.GET("/networks/ipam", h.HandleIPAMGet(), haz)
+	er.POST("/networks/import", h.HandleNetworksImportPost(), haz)
+	hr.POST("/networks/import/preview", h.HandleNetworksImportPreviewPost(), haz)
+	hr.GET("/networks/templates", h.HandleTemplatesGet(), haz)
+	er.POST("/networks/templates", h.HandleTemplatesPost(), haz)
+	hr.GET("/networks/templates/:template", h.HandleTemplateGet(), haz)
+	er.POST("/networks/templates/:template", h.HandleTemplatePost(), haz)
+	er.POST("/networks/templates/:template/settings", h.HandleTemplateSettingsPost(), haz)
 	hr.GET("/networks/:id", h.HandleNetworkGet())
 	er.POST("/networks/:id", h.HandleNetworkPost(), haz)
+	hr.GET("/networks/:id/deletion", h.HandleNetworkDeletionGet(), haz)
+	hr.GET("/networks/:id/history", h.HandleNetworkHistoryGet(), haz)
+	er.POST("/networks/:id/history", h.HandleNetworkHistoryPost(), haz)
+	hr.GET("/networks/:id/joins", h.HandleNetworkJoinsGet(), haz)
+	er.POST("/networks/:id/joins", h.HandleNetworkJoinsPost(), haz)
+	er.POST("/networks/:id/joins/:address", h.HandleNetworkJoinPost(), haz)
 	er.POST("/networks/:id/name", h.HandleNetworkNamePost(), haz)
+	er.POST("/networks/:id/aliases", h.HandleNetworkAliasesPost(), haz)
+	er.GET("/networks/:id/export", h.HandleNetworkExportGet(), haz)
+	er.POST("/networks/:id/template", h.HandleNetworkTemplatePost(), haz)
+	hr.POST("/networks/:id/migration", h.HandleNetworkMigrationPost(), haz)
+	hr.GET("/networks/:id/migration/:target", h.HandleNetworkMigrationGet(), haz)
 	hr.POST("/networks/:id/routes", h.HandleNetworkRoutesPost(), haz)
 	hr.POST("/networks/:id/autoip/v6-modes", h.HandleNetworkAutoIPv6ModesPost(), haz)
 	hr.POST("/networks/:id/autoip/v4-modes", h.HandleNetworkAutoIPv4ModesPost(), haz)
 	hr.POST("/networks/:id/autoip/pools", h.HandleNetworkAutoIPPoolsPost(), haz)
+	hr.POST("/networks/:id/dns", h.HandleNetworkDNSPost(), haz)
 	hr.POST("/networks/:id/rules", h.HandleNetworkRulesPost(), haz)
+	hr.POST("/networks/:id/capabilities", h.HandleNetworkCapabilitiesPost(), haz)
+	hr.POST("/networks/:id/tags", h.HandleNetworkTagsPost(), haz)
+	hr.POST("/networks/:id/tags/:tag/enums", h.HandleNetworkTagEnumsPost(), haz)
+	hr.POST("/networks/:id/access", h.HandleNetworkAccessPost(), haz)
+	hr.POST("/networks/:id/ethernet", h.HandleNetworkEthernetPost(), haz)
+	hr.POST("/networks/:id/remote-trace", h.HandleNetworkRemoteTracePost(), haz)
 	hr.POST("/networks/:id/devices", h.HandleDevicesPost(), haz)
+	hr.POST("/networks/:id/devices/bulk", h.HandleDevicesBulkPost(), haz)
 	tsr.SUB("/networks/:id/devices", h.HandleDevicesSub(), tsaz)
 	tsr.PUB("/networks/:id/devices", h.HandleDevicesPub())
 	tsr.MSG("/networks/:id/devices", handling.HandleTSMsg(h.r, ss), tsaz)
 	tsr.SUB("/networks/:id/devices/:address", h.HandleDeviceSub(), tsaz)
 	tsr.PUB("/networks/:id/devices/:address", h.HandleDevicePub())
 	tsr.MSG("/networks/:id/devices/:address", handling.HandleTSMsg(h.r, ss), tsaz)
+	hr.POST("/networks/:id/devices/:address", h.HandleDevicePost(), haz)
 	hr.POST("/networks/:id/devices/:address/authorization", h.HandleDeviceAuthorizationPost(), haz)
 	hr.POST("/networks/:id/devices/:address/name", h.HandleDeviceNamePost(), haz)
+	hr.POST("/networks/:id/devices/:address/metadata", h.HandleDeviceMetadataPost(), haz)
 	hr.POST("/networks/:id/devices/:address/ip", h.HandleDeviceIPPost(), haz)
+	hr.POST("/networks/:id/devices/:address/ip/next", h.HandleDeviceIPNextPost(), haz)
+	hr.POST("/networks/:id/devices/:address/capabilities", h.HandleDeviceCapabilitiesPost(), haz)
 }
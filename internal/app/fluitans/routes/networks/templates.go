@@ -0,0 +1,302 @@
+package networks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/auth"
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/client"
+	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
+	"github.com/sargassum-world/fluitans/internal/clients/zttemplates"
+	"github.com/sargassum-world/fluitans/pkg/desec"
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+// Template Settings
+
+type TemplateViewData struct {
+	Template      zttemplates.Template
+	NetworkConfig string
+}
+
+func newTemplateViewData(template zttemplates.Template) (TemplateViewData, error) {
+	networkConfig, err := json.MarshalIndent(
+		zttemplates.NewTemplateNetwork(template.Network), "", "  ",
+	)
+	if err != nil {
+		return TemplateViewData{}, err
+	}
+	return TemplateViewData{
+		Template:      template,
+		NetworkConfig: string(networkConfig),
+	}, nil
+}
+
+func parseTemplateNetwork(rawNetworkConfig string) (zerotier.ControllerNetwork, error) {
+	if rawNetworkConfig == "" {
+		return zerotier.ControllerNetwork{}, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(rawNetworkConfig)))
+	decoder.DisallowUnknownFields()
+	var network zerotier.ControllerNetwork
+	if err := decoder.Decode(&network); err != nil {
+		return zerotier.ControllerNetwork{}, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"invalid network configuration: %s", err,
+		))
+	}
+	if network.Rules != nil {
+		encodedRules, err := json.Marshal(*network.Rules)
+		if err != nil {
+			return zerotier.ControllerNetwork{}, err
+		}
+		parsedRules, err := zerotier.ParseRules(encodedRules)
+		if err != nil {
+			return zerotier.ControllerNetwork{}, echo.NewHTTPError(
+				http.StatusBadRequest, fmt.Sprintf("invalid rules in network configuration: %s", err),
+			)
+		}
+		rules, err := zerotier.RawRules(parsedRules)
+		if err != nil {
+			return zerotier.ControllerNetwork{}, err
+		}
+		network.Rules = &rules
+	}
+	return zttemplates.NewTemplateNetwork(network), nil
+}
+
+func parseTemplate(c echo.Context, prevTemplate zttemplates.Template) (
+	template zttemplates.Template, err error,
+) {
+	template = prevTemplate
+	template.Name = strings.TrimSpace(c.FormValue("name"))
+	if template.Name == "" {
+		return zttemplates.Template{}, echo.NewHTTPError(
+			http.StatusBadRequest, "network template name is required",
+		)
+	}
+	template.Description = strings.TrimSpace(c.FormValue("description"))
+	template.NameByDNS = strings.ToLower(c.FormValue("name-by-dns")) == checkboxTrueValue
+	if template.Network, err = parseTemplateNetwork(
+		strings.TrimSpace(c.FormValue("network-config")),
+	); err != nil {
+		return zttemplates.Template{}, err
+	}
+	return template, nil
+}
+
+func checkTemplateConflicts(
+	ctx context.Context, template zttemplates.Template, tc *zttemplates.Client,
+) error {
+	existing, err := tc.FindTemplate(ctx, template.Name)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != template.ID {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"network template with name %s already exists", template.Name,
+		))
+	}
+	return nil
+}
+
+// All Templates
+
+type TemplatesViewData struct {
+	Templates   []zttemplates.Template
+	NewTemplate TemplateViewData
+}
+
+func (h *Handlers) HandleTemplatesGet() auth.HTTPHandlerFunc {
+	t := "networks/templates.page.tmpl"
+	h.r.MustHave(t)
+	return func(c echo.Context, a auth.Auth) error {
+		// Run queries
+		templates, err := h.ztt.GetTemplates(c.Request().Context())
+		if err != nil {
+			return err
+		}
+
+		// Produce output
+		newTemplate, err := newTemplateViewData(zttemplates.Template{})
+		if err != nil {
+			return err
+		}
+		return h.r.CacheablePage(c.Response(), c.Request(), t, TemplatesViewData{
+			Templates:   templates,
+			NewTemplate: newTemplate,
+		}, a)
+	}
+}
+
+func (h *Handlers) HandleTemplatesPost() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// Parse params
+		template, err := parseTemplate(c, zttemplates.Template{})
+		if err != nil {
+			return err
+		}
+
+		// Run queries
+		ctx := c.Request().Context()
+		if err = checkTemplateConflicts(ctx, template, h.ztt); err != nil {
+			return err
+		}
+		id, err := h.ztt.AddTemplate(ctx, template)
+		if err != nil {
+			return err
+		}
+
+		// Redirect user
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/networks/templates/%d", id))
+	}
+}
+
+// Individual Template
+
+func (h *Handlers) HandleTemplateGet() auth.HTTPHandlerFunc {
+	t := "networks/template.page.tmpl"
+	h.r.MustHave(t)
+	return func(c echo.Context, a auth.Auth) error {
+		// Parse params
+		rawID := c.Param("template")
+
+		// Run queries
+		template, err := h.findTemplate(c.Request().Context(), rawID)
+		if err != nil {
+			return err
+		}
+
+		// Produce output
+		templateViewData, err := newTemplateViewData(*template)
+		if err != nil {
+			return err
+		}
+		return h.r.CacheablePage(c.Response(), c.Request(), t, templateViewData, a)
+	}
+}
+
+func (h *Handlers) HandleTemplatePost() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// Parse params
+		rawID := c.Param("template")
+		state := c.FormValue("state")
+
+		// Run queries
+		ctx := c.Request().Context()
+		template, err := h.findTemplate(ctx, rawID)
+		if err != nil {
+			return err
+		}
+		switch state {
+		default:
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+				"invalid network template state %s", state,
+			))
+		case "deleted":
+			// Networks created from the template are left untouched
+			if err = h.ztt.DeleteTemplate(ctx, template.ID); err != nil {
+				return err
+			}
+
+			// Redirect user
+			return c.Redirect(http.StatusSeeOther, "/networks/templates")
+		}
+	}
+}
+
+func (h *Handlers) HandleTemplateSettingsPost() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// Parse params
+		rawID := c.Param("template")
+		ctx := c.Request().Context()
+		prevTemplate, err := h.findTemplate(ctx, rawID)
+		if err != nil {
+			return err
+		}
+		template, err := parseTemplate(c, *prevTemplate)
+		if err != nil {
+			return err
+		}
+
+		// Run queries
+		if err = checkTemplateConflicts(ctx, template, h.ztt); err != nil {
+			return err
+		}
+		if err = h.ztt.UpdateTemplate(ctx, template); err != nil {
+			return err
+		}
+
+		// Redirect user
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/networks/templates/%d", template.ID))
+	}
+}
+
+// Templates from Networks
+
+func (h *Handlers) HandleNetworkTemplatePost() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// Parse params
+		id := c.Param("id")
+		address := ztc.GetControllerAddress(id)
+		name := strings.TrimSpace(c.FormValue("name"))
+		if name == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "network template name is required")
+		}
+		description := strings.TrimSpace(c.FormValue("description"))
+
+		// Run queries
+		ctx := c.Request().Context()
+		controller, err := h.ztcc.FindControllerByAddress(ctx, address)
+		if err != nil {
+			return err
+		}
+		if controller == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "controller not found")
+		}
+		eg, egctx := errgroup.WithContext(ctx)
+		var network *zerotier.ControllerNetwork
+		var subnameRRsets map[string][]desec.RRset
+		eg.Go(func() (err error) {
+			network, err = h.ztc.GetNetwork(egctx, *controller, id)
+			return err
+		})
+		eg.Go(func() (err error) {
+			subnameRRsets, err = h.dc.GetRRsets(egctx)
+			return err
+		})
+		if err = eg.Wait(); err != nil {
+			return err
+		}
+		if network == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "zerotier network not found")
+		}
+
+		template := zttemplates.Template{
+			Name:        name,
+			Description: description,
+			Network:     zttemplates.NewTemplateNetwork(*network),
+		}
+		if network.Name != nil {
+			template.NameByDNS = client.NetworkNamedByDNS(
+				id, *network.Name, h.dc.Config.DomainName, subnameRRsets,
+			)
+		}
+		if err = checkTemplateConflicts(ctx, template, h.ztt); err != nil {
+			return err
+		}
+		templateID, err := h.ztt.AddTemplate(ctx, template)
+		if err != nil {
+			return err
+		}
+
+		// Redirect user
+		return c.Redirect(http.StatusSeeOther, fmt.Sprintf("/networks/templates/%d", templateID))
+	}
+}
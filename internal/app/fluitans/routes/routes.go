@@ -32,6 +32,11 @@ func (h *Handlers) Register(er godest.EchoRouter, tsr turbostreams.Router, em go
 	ss := h.globals.Sessions
 	ztcc := h.globals.ZTControllers
 	ztc := h.globals.Zerotier
+	ztt := h.globals.ZTTemplates
+	ztg := h.globals.ZTTags
+	zth := h.globals.ZTHistory
+	ztd := h.globals.ZTDevices
+	ztj := h.globals.ZTJoins
 	dc := h.globals.Desec
 
 	assets.RegisterStatic(er, em)
@@ -41,8 +46,10 @@ func (h *Handlers) Register(er godest.EchoRouter, tsr turbostreams.Router, em go
 	).Register(er)
 	home.New(h.r).Register(er, ss)
 	auth.New(h.r, ss, acc, h.globals.Authn).Register(er)
-	controllers.New(h.r, ztcc, ztc).Register(er, ss)
-	networks.New(h.r, h.globals.TSBroker.Hub(), dc, ztc, ztcc).Register(er, tsr, ss)
+	controllers.New(h.r, ztcc, ztc, ztt).Register(er, tsr, ss)
+	networks.New(
+		h.r, h.globals.TSBroker.Hub(), dc, ztc, ztcc, ztt, ztg, zth, ztd, ztj,
+	).Register(er, tsr, ss)
 	dns.New(h.r, dc, ztc, ztcc).Register(er, tsr, ss)
 
 	tsr.UNSUB("/*", turbostreams.EmptyHandler)
@@ -205,6 +205,14 @@ func (s *Server) runWorkersInContext(ctx context.Context) error {
 		}
 		return nil
 	})
+	eg.Go(func() error {
+		if err := workers.MonitorZerotierControllers(
+			ctx, s.Globals.ZTControllers,
+		); err != nil && err != context.Canceled {
+			s.Globals.Logger.Error(errors.Wrap(err, "couldn't monitor zerotier controllers"))
+		}
+		return nil
+	})
 	eg.Go(func() error {
 		if err := workers.PrefetchZerotierNetworks(
 			ctx, s.Globals.Zerotier, s.Globals.ZTControllers,
@@ -213,6 +221,22 @@ func (s *Server) runWorkersInContext(ctx context.Context) error {
 		}
 		return nil
 	})
+	eg.Go(func() error {
+		if err := workers.AuthorizeZerotierJoins(
+			ctx, s.Globals.Zerotier, s.Globals.ZTControllers, s.Globals.ZTJoins,
+		); err != nil && err != context.Canceled {
+			s.Globals.Logger.Error(errors.Wrap(err, "couldn't authorize zerotier network joins"))
+		}
+		return nil
+	})
+	eg.Go(func() error {
+		if err := workers.ExpireZerotierAuthorizations(
+			ctx, s.Globals.Zerotier, s.Globals.ZTControllers, s.Globals.Desec, s.Globals.ZTDevices,
+		); err != nil && err != context.Canceled {
+			s.Globals.Logger.Error(errors.Wrap(err, "couldn't expire zerotier member authorizations"))
+		}
+		return nil
+	})
 	eg.Go(func() error {
 		if err := workers.PrefetchDNSRecords(
 			ctx, s.Globals.Desec,
@@ -248,6 +272,12 @@ func (s *Server) Run(e *echo.Echo) error {
 	if err := s.openDB(context.Background()); err != nil {
 		return errors.Wrap(err, "couldn't open database")
 	}
+	if err := s.Globals.ZTControllers.ResealSecrets(context.Background()); err != nil {
+		return errors.Wrap(err, "couldn't re-encrypt stored zerotier controller secrets")
+	}
+	if err := s.Globals.ZTControllers.SeedEnvController(context.Background()); err != nil {
+		return errors.Wrap(err, "couldn't seed zerotier controller from environment variables")
+	}
 
 	// The echo http server can't be canceled by context cancelation, so the API shouldn't promise to
 	// stop blocking execution on context cancelation - so we use the background context here. The
@@ -1,9 +1,35 @@
 package tmplfunc
 
 import (
+	"fmt"
 	"time"
 )
 
 func DurationToSec(i time.Duration) float64 {
 	return i.Seconds()
 }
+
+func pluralize(count int64, unit string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, unit)
+	}
+	return fmt.Sprintf("%d %ss", count, unit)
+}
+
+// DescribeTimeRemaining describes the time remaining until t in the largest whole units.
+func DescribeTimeRemaining(t time.Time) string {
+	remaining := time.Until(t)
+	const day = 24 * time.Hour
+	switch {
+	case remaining <= 0:
+		return "no time"
+	case remaining >= day:
+		return pluralize(int64(remaining/day), "day")
+	case remaining >= time.Hour:
+		return pluralize(int64(remaining/time.Hour), "hour")
+	case remaining >= time.Minute:
+		return pluralize(int64(remaining/time.Minute), "minute")
+	default:
+		return "less than a minute"
+	}
+}
@@ -14,9 +14,12 @@ func FuncMap(h HashedNamers, tss TurboStreamSigner) template.FuncMap {
 		"identifyNetwork":        IdentifyNetwork,
 		"getNetworkHostAddress":  GetNetworkHostAddress,
 		"getNetworkNumber":       GetNetworkNumber,
+		"describePeerConnection": DescribePeerConnection,
 		"durationToSec":          DurationToSec,
+		"describeTimeRemaining":  DescribeTimeRemaining,
 		"derefBool":              DerefBool,
 		"derefInt":               DerefInt,
+		"derefUint64":            DerefUint64,
 		"derefFloat32":           DerefFloat32,
 		"derefString":            DerefString,
 		"describeDNSRecordType":  DescribeDNSRecordType,
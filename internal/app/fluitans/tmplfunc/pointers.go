@@ -12,6 +12,14 @@ func DerefInt(i *int, nilValue int) int {
 	return *i
 }
 
+func DerefUint64(i *uint64, nilValue uint64) uint64 {
+	if i == nil {
+		return nilValue
+	}
+
+	return *i
+}
+
 func DerefFloat32(i *float32, nilValue float32) float32 {
 	if i == nil {
 		return nilValue
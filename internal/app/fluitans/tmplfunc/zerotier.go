@@ -3,6 +3,7 @@ package tmplfunc
 import (
 	"strings"
 
+	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
 	"github.com/sargassum-world/fluitans/pkg/zerotier"
 )
 
@@ -20,3 +21,7 @@ func GetNetworkHostAddress(id string) string {
 func GetNetworkNumber(id string) string {
 	return id[10:]
 }
+
+func DescribePeerConnection(peer *zerotier.Peer) string {
+	return ztc.DescribePeerConnection(peer)
+}
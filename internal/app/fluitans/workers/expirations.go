@@ -0,0 +1,143 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sargassum-world/godest/handling"
+
+	"github.com/sargassum-world/fluitans/internal/app/fluitans/client"
+	desecc "github.com/sargassum-world/fluitans/internal/clients/desec"
+	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
+	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/internal/clients/ztdevices"
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+func deleteExpirations(
+	ctx context.Context, networkID string, memberAddresses []string, mc *ztdevices.Client,
+) error {
+	for _, memberAddress := range memberAddresses {
+		if err := mc.DeleteExpiration(ctx, networkID, memberAddress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expireAuthorizations deauthorizes the members of the network and deletes their DNS records. The
+// members' expirations are only deleted once both steps succeed, so that failed steps are retried.
+// Expirations of members (or networks, or controllers) which no longer exist are deleted without
+// any other steps.
+func expireAuthorizations(
+	ctx context.Context, networkID string, memberAddresses []string,
+	c *ztc.Client, cc *ztcontrollers.Client, dc *desecc.Client, mc *ztdevices.Client,
+) error {
+	controllerAddress := ztc.GetControllerAddress(networkID)
+	controller, err := cc.FindControllerByAddress(ctx, controllerAddress)
+	if isMissingController(err) {
+		// Fluitans no longer manages the network, and otherwise we'd rescan all controllers for it on
+		// every poll
+		return deleteExpirations(ctx, networkID, memberAddresses, mc)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "couldn't find controller %s", controllerAddress)
+	}
+	network, err := c.GetNetwork(ctx, *controller, networkID)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't get network %s", networkID)
+	}
+	if network == nil {
+		return deleteExpirations(ctx, networkID, memberAddresses, mc)
+	}
+	existingAddresses, err := c.GetNetworkMemberAddresses(ctx, *controller, networkID)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't get network %s member addresses", networkID)
+	}
+	existing := make(map[string]bool, len(existingAddresses))
+	for _, memberAddress := range existingAddresses {
+		existing[memberAddress] = true
+	}
+	expiredAddresses := make([]string, 0, len(memberAddresses))
+	deletedAddresses := make([]string, 0)
+	for _, memberAddress := range memberAddresses {
+		if !existing[memberAddress] {
+			// Updating a deleted member would add it back to the network
+			deletedAddresses = append(deletedAddresses, memberAddress)
+			continue
+		}
+		expiredAddresses = append(expiredAddresses, memberAddress)
+	}
+	if err = deleteExpirations(ctx, networkID, deletedAddresses, mc); err != nil {
+		return err
+	}
+	if len(expiredAddresses) == 0 {
+		return nil
+	}
+
+	authorized := false
+	for _, memberAddress := range expiredAddresses {
+		if err = c.UpdateMember(
+			ctx, *controller, networkID, memberAddress,
+			zerotier.SetControllerNetworkMemberJSONRequestBody{Authorized: &authorized},
+		); err != nil {
+			return errors.Wrapf(
+				err, "couldn't deauthorize network %s member %s", networkID, memberAddress,
+			)
+		}
+	}
+	subnameRRsets, err := dc.GetRRsets(ctx)
+	if err != nil {
+		return errors.Wrap(err, "couldn't get DNS records")
+	}
+	if err = client.DeleteMemberRecords(
+		ctx, *controller, *network, expiredAddresses, subnameRRsets, c, dc,
+	); err != nil {
+		return err
+	}
+	return deleteExpirations(ctx, networkID, expiredAddresses, mc)
+}
+
+// ExpireZerotierAuthorizations periodically revokes the authorizations of network members whose
+// temporary authorizations have expired.
+func ExpireZerotierAuthorizations(
+	ctx context.Context,
+	c *ztc.Client, cc *ztcontrollers.Client, dc *desecc.Client, mc *ztdevices.Client,
+) error {
+	const pollInterval = 10 * time.Second
+	return handling.RepeatImmediate(ctx, pollInterval, func() (done bool, err error) {
+		expirations, err := mc.GetExpiredAuthorizations(ctx, time.Now())
+		if err != nil {
+			mc.Logger.Error(errors.Wrap(err, "couldn't get the list of expired authorizations"))
+			return false, nil
+		}
+
+		// DNS records are deleted in one batch per network, since the DNS server limits the rate of
+		// writes
+		networkIDs := make([]string, 0, len(expirations))
+		memberAddresses := make(map[string][]string)
+		for _, expiration := range expirations {
+			if _, ok := memberAddresses[expiration.NetworkID]; !ok {
+				networkIDs = append(networkIDs, expiration.NetworkID)
+			}
+			memberAddresses[expiration.NetworkID] = append(
+				memberAddresses[expiration.NetworkID], expiration.MemberAddress,
+			)
+		}
+		for _, networkID := range networkIDs {
+			if err := expireAuthorizations(
+				ctx, networkID, memberAddresses[networkID], c, cc, dc, mc,
+			); err != nil {
+				if ctx.Err() != nil {
+					// The error is meaningless if we're shutting down
+					return false, nil
+				}
+				mc.Logger.Error(errors.Wrapf(
+					err, "couldn't revoke expired authorizations in network %s", networkID,
+				))
+			}
+		}
+		return false, nil
+	})
+}
@@ -0,0 +1,132 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sargassum-world/godest/handling"
+
+	ztc "github.com/sargassum-world/fluitans/internal/clients/zerotier"
+	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/internal/clients/ztjoins"
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+// applyJoinPolicy decides whether to authorize each device which requested to join the policy's
+// network and was never authorized, recording each decision. Devices which were rejected are
+// evaluated again on every poll, since they may be allowed by a later change to the policy; devices
+// which were ever authorized aren't, so that devices which were deauthorized (or whose
+// authorizations expired) aren't authorized again. If the network's controller was removed, the
+// network's join records are deleted instead.
+func applyJoinPolicy(
+	ctx context.Context, policy ztjoins.Policy,
+	c *ztc.Client, cc *ztcontrollers.Client, jc *ztjoins.Client,
+) error {
+	networkID := policy.NetworkID
+	controllerAddress := ztc.GetControllerAddress(networkID)
+	controller, err := cc.FindControllerByAddress(ctx, controllerAddress)
+	if isMissingController(err) {
+		// Fluitans no longer manages the network, and otherwise we'd rescan all controllers for it on
+		// every poll
+		return jc.DeleteNetwork(ctx, networkID)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "couldn't find controller %s", controllerAddress)
+	}
+	memberAddresses, err := c.GetNetworkMemberAddresses(ctx, *controller, networkID)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't get network %s member addresses", networkID)
+	}
+	members, err := c.GetNetworkMembers(ctx, *controller, networkID, memberAddresses)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't get network %s members", networkID)
+	}
+	authorizedMembers, err := jc.GetAuthorizedMembers(ctx, networkID)
+	if err != nil {
+		return err
+	}
+	decisions, err := jc.GetDecisions(ctx, networkID)
+	if err != nil {
+		return err
+	}
+	acceptedMembers := make(map[string]bool, len(decisions))
+	for _, decision := range decisions {
+		if decision.Accepted {
+			acceptedMembers[decision.MemberAddress] = true
+		}
+	}
+
+	for _, memberAddress := range memberAddresses {
+		member := members[memberAddress]
+		if member.Authorized != nil && *member.Authorized {
+			// Devices can also be authorized without the join policy, e.g. manually, so we need to
+			// remember them in case they're deauthorized later
+			if !authorizedMembers[memberAddress] {
+				if err = jc.AddAuthorizedMember(ctx, networkID, memberAddress, time.Now()); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if authorizedMembers[memberAddress] || acceptedMembers[memberAddress] ||
+			!ztc.MemberJoined(member) {
+			continue
+		}
+
+		decision := policy.Evaluate(member, time.Now())
+		if decision.Accepted {
+			authorized := true
+			if err = c.UpdateMember(
+				ctx, *controller, networkID, memberAddress,
+				zerotier.SetControllerNetworkMemberJSONRequestBody{Authorized: &authorized},
+			); err != nil {
+				return errors.Wrapf(
+					err, "couldn't authorize network %s member %s", networkID, memberAddress,
+				)
+			}
+			// We might've added a new network member, so we should invalidate the cache
+			c.Cache.UnsetNetworkMembersByID(networkID)
+			if err = jc.AddAuthorizedMember(ctx, networkID, memberAddress, decision.Time); err != nil {
+				return err
+			}
+			if err = jc.IncrementAutoApprovals(ctx, networkID); err != nil {
+				return err
+			}
+			policy.AutoApprovals++
+		}
+		// The decision only replaces the previous decision if its outcome or reason changed
+		if err = jc.RecordDecision(ctx, decision); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AuthorizeZerotierJoins periodically applies the join policies of networks to devices which
+// requested to join those networks.
+func AuthorizeZerotierJoins(
+	ctx context.Context, c *ztc.Client, cc *ztcontrollers.Client, jc *ztjoins.Client,
+) error {
+	const pollInterval = 10 * time.Second
+	return handling.RepeatImmediate(ctx, pollInterval, func() (done bool, err error) {
+		policies, err := jc.GetPolicies(ctx)
+		if err != nil {
+			jc.Logger.Error(errors.Wrap(err, "couldn't get the list of join policies"))
+			return false, nil
+		}
+
+		for _, policy := range policies {
+			if err := applyJoinPolicy(ctx, policy, c, cc, jc); err != nil {
+				if ctx.Err() != nil {
+					// The error is meaningless if we're shutting down
+					return false, nil
+				}
+				jc.Logger.Error(errors.Wrapf(
+					err, "couldn't apply join policy of network %s", policy.NetworkID,
+				))
+			}
+		}
+		return false, nil
+	})
+}
@@ -3,8 +3,10 @@ package workers
 
 import (
 	"context"
+	"net/http"
 	"time"
 
+	"github.com/labstack/echo/v4"
 	"github.com/pkg/errors"
 	"github.com/sargassum-world/godest/handling"
 	"golang.org/x/sync/errgroup"
@@ -20,7 +22,7 @@ import (
 func PrescanZerotierControllers(ctx context.Context, c *ztcontrollers.Client) error {
 	const retryInterval = 5 * time.Second
 	return handling.RepeatImmediate(ctx, retryInterval, func() (done bool, err error) {
-		controllers, err := c.GetControllers()
+		controllers, err := c.GetControllers(ctx)
 		if err != nil {
 			c.Logger.Error(errors.Wrap(err, "couldn't get the list of known controllers"))
 			return false, nil
@@ -36,12 +38,58 @@ func PrescanZerotierControllers(ctx context.Context, c *ztcontrollers.Client) er
 	})
 }
 
+// isMissingController checks whether the error from looking up a controller by its address means
+// that none of the known controllers has the address, e.g. because the controller was removed.
+func isMissingController(err error) bool {
+	herr, ok := err.(*echo.HTTPError)
+	return ok && herr.Code == http.StatusNotFound
+}
+
+// MonitorZerotierControllers periodically observes the state of every known controller, recording
+// any state changes in each controller's health history.
+func MonitorZerotierControllers(ctx context.Context, c *ztcontrollers.Client) error {
+	const pollInterval = 30 * time.Second
+	const pollTimeout = 10 * time.Second
+	return handling.RepeatImmediate(ctx, pollInterval, func() (done bool, err error) {
+		controllers, err := c.GetControllers(ctx)
+		if err != nil {
+			c.Logger.Error(errors.Wrap(err, "couldn't get the list of known controllers"))
+			return false, nil
+		}
+
+		eg, egctx := errgroup.WithContext(ctx)
+		for _, controller := range controllers {
+			eg.Go(func(controller ztcontrollers.Controller) func() error {
+				return func() error {
+					pollCtx, cancel := context.WithTimeout(egctx, pollTimeout)
+					defer cancel()
+					state := c.ObserveController(pollCtx, controller)
+					if egctx.Err() != nil {
+						// The observed state is meaningless if we're shutting down
+						return nil
+					}
+					if _, err := c.RecordControllerState(egctx, controller, state); err != nil {
+						c.Logger.Error(errors.Wrapf(
+							err, "couldn't record state of zerotier controller %s", controller.Name,
+						))
+					}
+					return nil
+				}
+			}(controller))
+		}
+		if err := eg.Wait(); err != nil {
+			return false, err
+		}
+		return false, nil
+	})
+}
+
 func PrefetchZerotierNetworks(
 	ctx context.Context, c *ztc.Client, cc *ztcontrollers.Client,
 ) error {
 	const retryInterval = 5 * time.Second
 	return handling.RepeatImmediate(ctx, retryInterval, func() (done bool, err error) {
-		controllers, err := cc.GetControllers()
+		controllers, err := cc.GetControllers(ctx)
 		if err != nil {
 			cc.Logger.Error(errors.Wrap(err, "couldn't get the list of known controllers"))
 			return false, nil
@@ -137,7 +185,7 @@ func UpdateZeroTierDNSRecords(
 ) error {
 	const runInterval = 10 * time.Second
 	return handling.RepeatImmediate(ctx, runInterval, func() (done bool, err error) {
-		controllers, err := cc.GetControllers()
+		controllers, err := cc.GetControllers(ctx)
 		if err != nil {
 			return false, err
 		}
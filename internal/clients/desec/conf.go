@@ -9,6 +9,8 @@ import (
 	"github.com/sargassum-world/godest/env"
 
 	"github.com/sargassum-world/fluitans/internal/models"
+	"github.com/sargassum-world/fluitans/pkg/secrets"
+	"github.com/sargassum-world/fluitans/pkg/transport"
 )
 
 const envPrefix = "DNS_"
@@ -50,7 +52,7 @@ func getDNSServer() (s models.DNSServer, err error) {
 		return models.DNSServer{}, nil
 	}
 
-	s.Authtoken = os.Getenv(envPrefix + "AUTHTOKEN")
+	s.Authtoken = secrets.Redacted(os.Getenv(envPrefix + "AUTHTOKEN"))
 	if len(s.Authtoken) == 0 {
 		return models.DNSServer{}, nil
 	}
@@ -66,6 +68,11 @@ func getDNSServer() (s models.DNSServer, err error) {
 	if err != nil {
 		return models.DNSServer{}, errors.Wrap(err, "couldn't make network cost config")
 	}
+
+	s.Transport, err = transport.GetSettings(envPrefix)
+	if err != nil {
+		return models.DNSServer{}, errors.Wrap(err, "couldn't make transport config")
+	}
 	return s, nil
 }
 
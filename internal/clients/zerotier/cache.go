@@ -2,6 +2,7 @@ package zerotier
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/sargassum-world/godest/clientcache"
 
@@ -11,6 +12,7 @@ import (
 type Cache struct {
 	Cache      clientcache.Cache
 	CostWeight float32
+	PeersTTL   time.Duration
 }
 
 // /zerotier/networks/:id
@@ -71,3 +73,30 @@ func (c *Cache) GetNetworkMembersByID(networkID string) ([]string, error) {
 
 	return value, nil
 }
+
+// /zerotier/controllers/:server/peers
+
+func keyPeersByServer(server string) string {
+	return fmt.Sprintf("/zerotier/controllers/s:[%s]/peers", server)
+}
+
+func (c *Cache) SetPeersByServer(server string, peers []zerotier.Peer) error {
+	key := keyPeersByServer(server)
+	return c.Cache.SetEntry(key, peers, c.CostWeight, c.PeersTTL)
+}
+
+func (c *Cache) UnsetPeersByServer(server string) {
+	key := keyPeersByServer(server)
+	c.Cache.UnsetEntry(key)
+}
+
+func (c *Cache) GetPeersByServer(server string) ([]zerotier.Peer, bool, error) {
+	key := keyPeersByServer(server)
+	var value []zerotier.Peer
+	keyExists, valueExists, err := c.Cache.GetEntry(key, &value)
+	if !keyExists || !valueExists || err != nil {
+		return nil, keyExists, err
+	}
+
+	return value, true, nil
+}
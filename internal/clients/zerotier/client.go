@@ -2,14 +2,28 @@
 package zerotier
 
 import (
+	"context"
+
 	"github.com/sargassum-world/godest"
 	"github.com/sargassum-world/godest/clientcache"
+
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
 )
 
+// NetworkRecorder records snapshots of network configurations. The changed flag is true when the
+// network's revision was produced by the change being made with the context, and false when the
+// snapshot is of the network from before the change.
+type NetworkRecorder interface {
+	RecordNetwork(ctx context.Context, network zerotier.ControllerNetwork, changed bool) error
+}
+
 type Client struct {
 	Config Config
 	Logger godest.Logger
 	Cache  *Cache
+	// Recorder, if it's not nil, records the configurations of networks before and after they're
+	// changed by the client
+	Recorder NetworkRecorder
 }
 
 func NewClient(c Config, cache clientcache.Cache, l godest.Logger) *Client {
@@ -17,7 +31,8 @@ func NewClient(c Config, cache clientcache.Cache, l godest.Logger) *Client {
 		Config: c,
 		Logger: l,
 		Cache: &Cache{
-			Cache: cache,
+			Cache:    cache,
+			PeersTTL: c.PeersCacheTTL,
 		},
 	}
 }
@@ -1,6 +1,8 @@
 package zerotier
 
 import (
+	"time"
+
 	"github.com/pkg/errors"
 	"github.com/sargassum-world/godest/env"
 )
@@ -8,7 +10,8 @@ import (
 const envPrefix = "ZEROTIER_"
 
 type Config struct {
-	DNS ZTDNSSettings
+	DNS           ZTDNSSettings
+	PeersCacheTTL time.Duration
 }
 
 func GetConfig() (c Config, err error) {
@@ -16,9 +19,27 @@ func GetConfig() (c Config, err error) {
 	if err != nil {
 		return Config{}, errors.Wrap(err, "couldn't make Zerotier DNS settings")
 	}
+	c.PeersCacheTTL, err = getPeersCacheTTL()
+	if err != nil {
+		return Config{}, errors.Wrap(err, "couldn't make peers cache TTL config")
+	}
 	return c, nil
 }
 
+func getPeersCacheTTL() (time.Duration, error) {
+	// The TTL of cached peers lists, in units of seconds; negative numbers represent an infinite
+	// TTL. Peer latencies and paths change frequently, so the TTL should be short.
+	const defaultTTL = 10 // default: 10 sec
+	rawTTL, err := env.GetFloat32(envPrefix+"PEERS_CACHETTL", defaultTTL)
+	if err != nil {
+		return 0, err
+	}
+	if rawTTL < 0 {
+		return -1, nil
+	}
+	return time.Duration(rawTTL * float32(time.Second)), nil
+}
+
 func getNetworkTTL() (int64, error) {
 	const defaultTTL = 60 * 60 * 24 // default: 24 hours
 	return env.GetInt64(envPrefix+"DNS_NETWORKTTL", defaultTTL)
@@ -29,6 +50,10 @@ func getDeviceTTL() (int64, error) {
 	return env.GetInt64(envPrefix+"DNS_DEVICETTL", defaultTTL)
 }
 
+func getAutoSearchDomain() (bool, error) {
+	return env.GetBool(envPrefix + "DNS_AUTOSEARCHDOMAIN")
+}
+
 func GetDNSSettings() (s ZTDNSSettings, err error) {
 	s.NetworkTTL, err = getNetworkTTL()
 	if err != nil {
@@ -40,5 +65,10 @@ func GetDNSSettings() (s ZTDNSSettings, err error) {
 		return ZTDNSSettings{}, errors.Wrap(err, "couldn't make device record TTL config")
 	}
 
+	s.AutoSearchDomain, err = getAutoSearchDomain()
+	if err != nil {
+		return ZTDNSSettings{}, errors.Wrap(err, "couldn't make automatic search domain config")
+	}
+
 	return s, nil
 }
@@ -3,4 +3,7 @@ package zerotier
 type ZTDNSSettings struct {
 	NetworkTTL int64 `json:"networkTTL"`
 	DeviceTTL  int64 `json:"deviceTTL"`
+	// AutoSearchDomain makes networks push their domain name to their members as a DNS search domain
+	// when they're named by DNS
+	AutoSearchDomain bool `json:"autoSearchDomain"`
 }
@@ -2,7 +2,9 @@ package zerotier
 
 import (
 	"context"
+	"net/http"
 
+	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
@@ -32,6 +34,10 @@ func (c *Client) GetNetworkMembers(
 				if err != nil {
 					return err
 				}
+				if res.JSON200 == nil {
+					// The member might've been deleted after its address was listed
+					return errors.Errorf("network %s member %s not found", networkID, memberAddress)
+				}
 
 				members[i] = *res.JSON200
 				return nil
@@ -80,6 +86,41 @@ func (c *Client) UpdateMember(
 	return err
 }
 
+func (c *Client) DeleteMember(
+	ctx context.Context, controller ztcontrollers.Controller, networkID string,
+	memberAddress string,
+) error {
+	client, err := controller.NewClient()
+	if err != nil {
+		return err
+	}
+
+	res, err := client.DeleteControllerNetworkMemberWithResponse(ctx, networkID, memberAddress)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode() != http.StatusOK {
+		return errors.Errorf(
+			"couldn't delete network %s member %s (status %d)",
+			networkID, memberAddress, res.StatusCode(),
+		)
+	}
+
+	c.Cache.UnsetNetworkMembersByID(networkID)
+	return nil
+}
+
+// Membership
+
+// MemberJoined checks whether the device has requested the network's configuration from the
+// controller, as opposed to only having been configured ahead of time through the controller's API.
+func MemberJoined(member zerotier.ControllerNetworkMember) bool {
+	if member.Identity != nil && *member.Identity != "" {
+		return true
+	}
+	return member.VMajor != nil && *member.VMajor >= 0
+}
+
 // IP Addresses
 
 func CalculateNDPAddresses(
@@ -249,54 +249,10 @@ func (c *Client) GetNetworkInfo(
 	return network, addresses, nil
 }
 
-func makeDefaultRules() []map[string]interface{} {
-	ipv4Type := 2048
-	ipv4ARPType := 2054
-	ipv6Type := 34525
-	return []map[string]interface{}{
-		{
-			"type":      "MATCH_ETHERTYPE",
-			"etherType": ipv4Type,
-			"not":       true,
-		},
-		{
-			"type":      "MATCH_ETHERTYPE",
-			"etherType": ipv4ARPType,
-			"not":       true,
-		},
-		{
-			"type":      "MATCH_ETHERTYPE",
-			"etherType": ipv6Type,
-			"not":       true,
-		},
-		{
-			"type": "ACTION_DROP",
-		},
-		{
-			"type": "ACTION_ACCEPT",
-		},
-	}
-}
-
-func makeDefaultNetworkRequest() zerotier.GenerateControllerNetworkJSONRequestBody {
-	private := true
-	n6plane := true
-	v6AssignMode := zerotier.V6AssignMode{
-		N6plane: &n6plane,
-		Rfc4193: nil,
-		Zt:      nil,
-	}
-	rules := makeDefaultRules()
-
-	body := zerotier.GenerateControllerNetworkJSONRequestBody{}
-	body.Private = &private
-	body.V6AssignMode = &v6AssignMode
-	body.Rules = &rules
-	return body
-}
-
-func (c *Client) CreateNetwork(
-	ctx context.Context, controller ztcontrollers.Controller, cc *ztcontrollers.Client,
+// CreateNetworkWithConfig creates a new network on the controller, with a randomly-generated ID.
+func (c *Client) CreateNetworkWithConfig(
+	ctx context.Context, controller ztcontrollers.Controller,
+	body zerotier.GenerateControllerNetworkJSONRequestBody, cc *ztcontrollers.Client,
 ) (*zerotier.ControllerNetwork, error) {
 	client, cerr := controller.NewClient()
 	if cerr != nil {
@@ -308,7 +264,6 @@ func (c *Client) CreateNetwork(
 		return nil, err
 	}
 
-	body := makeDefaultNetworkRequest()
 	nRes, err := client.GenerateControllerNetworkWithResponse(
 		ctx, address, body,
 	)
@@ -321,9 +276,89 @@ func (c *Client) CreateNetwork(
 	if err = c.Cache.SetNetworkByID(*nRes.JSON200.Id, *nRes.JSON200); err != nil {
 		return nil, err
 	}
+	c.recordChangedNetwork(ctx, nil, nRes.JSON200)
 	return nRes.JSON200, nil
 }
 
+// recordUnchangedNetwork records the network's configuration from before a change, so that the
+// change can be undone, and returns that configuration.
+func (c *Client) recordUnchangedNetwork(
+	ctx context.Context, controller ztcontrollers.Controller, id string,
+) (*zerotier.ControllerNetwork, error) {
+	if c.Recorder == nil {
+		return nil, nil
+	}
+
+	// We bypass the cache because the network may have been changed outside of Fluitans since it was
+	// cached, and the recorded configuration must be the one which the change will be applied to
+	network, err := c.getNetworkFromZerotier(ctx, controller, id)
+	if err != nil || network == nil {
+		return nil, err
+	}
+	return network, errors.Wrapf(
+		c.Recorder.RecordNetwork(ctx, *network, false),
+		"couldn't record configuration of network %s before changing it", id,
+	)
+}
+
+// changeAttribution describes how a network's revision resulting from a change should be recorded.
+type changeAttribution int
+
+const (
+	// changeUnchanged means that the change didn't produce a new revision, so the revision shouldn't
+	// be recorded as a result of the change
+	changeUnchanged changeAttribution = iota
+	// changeUnattributed means that other changes may have produced revisions between the previous
+	// revision and the resulting revision, so the resulting revision can't be attributed to the
+	// change
+	changeUnattributed
+	// changeAttributed means that the resulting revision was produced by the change
+	changeAttributed
+)
+
+// attributeChange determines how the network's revision resulting from a change should be
+// recorded, given the network's configuration from before the change. The controller only
+// increments the revision of a network when its configuration actually changes.
+func attributeChange(previous, network zerotier.ControllerNetwork) changeAttribution {
+	if previous.Revision == nil || network.Revision == nil {
+		return changeUnattributed
+	}
+	switch *network.Revision {
+	case *previous.Revision:
+		return changeUnchanged
+	case *previous.Revision + 1:
+		return changeAttributed
+	default:
+		return changeUnattributed
+	}
+}
+
+// recordChangedNetwork records the network's configuration resulting from a change, given the
+// network's configuration from before the change (or nil if the change created the network).
+// Because the change was already made, errors are logged rather than returned.
+func (c *Client) recordChangedNetwork(
+	ctx context.Context, previous, network *zerotier.ControllerNetwork,
+) {
+	if c.Recorder == nil || network == nil {
+		return
+	}
+
+	attribution := changeAttributed
+	if previous != nil {
+		attribution = attributeChange(*previous, *network)
+	}
+	if attribution == changeUnchanged {
+		return
+	}
+	if err := c.Recorder.RecordNetwork(
+		ctx, *network, attribution == changeAttributed,
+	); err != nil {
+		c.Logger.Error(errors.Wrapf(
+			err, "couldn't record configuration of network %s after changing it", *network.Id,
+		))
+	}
+}
+
 func (c *Client) UpdateNetwork(
 	ctx context.Context, controller ztcontrollers.Controller, id string,
 	network zerotier.SetControllerNetworkJSONRequestBody,
@@ -333,13 +368,21 @@ func (c *Client) UpdateNetwork(
 		return nil, err
 	}
 
+	previous, err := c.recordUnchangedNetwork(ctx, controller, id)
+	if err != nil {
+		return nil, err
+	}
 	res, err := client.SetControllerNetworkWithResponse(ctx, id, network)
 	if err != nil {
 		return nil, err
 	}
 
 	// TODO: this should only happen on a success HTTP status code
-	return res.JSON200, c.Cache.SetNetworkByID(id, *res.JSON200)
+	if err = c.Cache.SetNetworkByID(id, *res.JSON200); err != nil {
+		return nil, err
+	}
+	c.recordChangedNetwork(ctx, previous, res.JSON200)
+	return res.JSON200, nil
 }
 
 func (c *Client) DeleteNetwork(
@@ -350,6 +393,9 @@ func (c *Client) DeleteNetwork(
 		return err
 	}
 
+	if _, err = c.recordUnchangedNetwork(ctx, controller, id); err != nil {
+		return err
+	}
 	_, err = client.DeleteControllerNetworkWithResponse(ctx, id)
 	if err != nil {
 		return err
@@ -0,0 +1,29 @@
+package zerotier
+
+import (
+	"testing"
+
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+func TestAttributeChange(t *testing.T) {
+	revision := func(revision int) zerotier.ControllerNetwork {
+		return zerotier.ControllerNetwork{Revision: &revision}
+	}
+	testCases := []struct {
+		name     string
+		previous zerotier.ControllerNetwork
+		network  zerotier.ControllerNetwork
+		want     changeAttribution
+	}{
+		{"no-op update", revision(3), revision(3), changeUnchanged},
+		{"next revision", revision(3), revision(4), changeAttributed},
+		{"skipped revisions", revision(3), revision(6), changeUnattributed},
+		{"unknown revision", zerotier.ControllerNetwork{}, revision(4), changeUnattributed},
+	}
+	for _, testCase := range testCases {
+		if got := attributeChange(testCase.previous, testCase.network); got != testCase.want {
+			t.Errorf("%s: got %d, want %d", testCase.name, got, testCase.want)
+		}
+	}
+}
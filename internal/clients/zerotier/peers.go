@@ -0,0 +1,118 @@
+package zerotier
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/sargassum-world/fluitans/internal/clients/ztcontrollers"
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+// All Peers
+
+func (c *Client) getPeersFromCache(controller ztcontrollers.Controller) ([]zerotier.Peer, bool) {
+	peers, cacheHit, err := c.Cache.GetPeersByServer(controller.Server)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		// Log the error but return as a cache miss so we can manually query the peers
+		c.Logger.Error(errors.Wrapf(
+			err, "couldn't get the cache entry for the peers of %s", controller.Server,
+		))
+		return nil, false // treat an unparseable cache entry like a cache miss
+	}
+	return peers, cacheHit
+}
+
+func (c *Client) getPeersFromZerotier(
+	ctx context.Context, controller ztcontrollers.Controller,
+) ([]zerotier.Peer, error) {
+	client, cerr := controller.NewClient()
+	if cerr != nil {
+		return nil, cerr
+	}
+
+	res, err := client.GetPeersWithResponse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if res.JSON200 == nil {
+		return nil, errors.Errorf(
+			"zerotier controller %s responded to peers query with status %s",
+			controller.Server, res.Status(),
+		)
+	}
+
+	peers := *res.JSON200
+	sort.Slice(peers, func(i, j int) bool {
+		return derefString(peers[i].Address) < derefString(peers[j].Address)
+	})
+	if err := c.Cache.SetPeersByServer(controller.Server, peers); err != nil {
+		return nil, err
+	}
+	return peers, nil
+}
+
+// GetPeers returns all peers of the controller's ZeroTier node, sorted by address.
+func (c *Client) GetPeers(
+	ctx context.Context, controller ztcontrollers.Controller,
+) ([]zerotier.Peer, error) {
+	if peers, cacheHit := c.getPeersFromCache(controller); cacheHit {
+		return peers, nil
+	}
+	return c.getPeersFromZerotier(ctx, controller)
+}
+
+// Individual Peer
+
+// GetPeer returns the peer with the specified address, or nil if the controller's ZeroTier node
+// isn't currently peered with a node with that address. The peer is looked up from the list of all
+// peers, so that looking up many peers (e.g. for all members of a network) only needs one query.
+func (c *Client) GetPeer(
+	ctx context.Context, controller ztcontrollers.Controller, address string,
+) (*zerotier.Peer, error) {
+	peers, err := c.GetPeers(ctx, controller)
+	if err != nil {
+		return nil, err
+	}
+	for _, peer := range peers {
+		if derefString(peer.Address) == address {
+			return &peer, nil
+		}
+	}
+	return nil, nil
+}
+
+// Paths
+
+// HasDirectPath checks whether the peer can be reached over at least one active physical path,
+// rather than only being reachable through relays.
+func HasDirectPath(peer zerotier.Peer) bool {
+	if peer.Paths == nil {
+		return false
+	}
+	for _, path := range *peer.Paths {
+		if path.Active != nil && *path.Active && (path.Expired == nil || !*path.Expired) {
+			return true
+		}
+	}
+	return false
+}
+
+// DescribePeerConnection summarizes how the peer is connected, for display purposes.
+func DescribePeerConnection(peer *zerotier.Peer) string {
+	if peer == nil {
+		return "not connected"
+	}
+	if HasDirectPath(*peer) {
+		return "direct"
+	}
+	return "relayed"
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -23,7 +23,7 @@ func (c *Client) GetControllerStatuses(
 	eg.Go(func() error {
 		res, cerr := client.GetStatusWithResponse(ctx)
 		if cerr != nil {
-			return err
+			return cerr
 		}
 		status = res.JSON200
 		return cc.Cache.SetControllerByAddress(*status.Address, controller)
@@ -31,7 +31,7 @@ func (c *Client) GetControllerStatuses(
 	eg.Go(func() error {
 		res, cerr := client.GetControllerStatusWithResponse(ctx)
 		if cerr != nil {
-			return err
+			return cerr
 		}
 		controllerStatus = res.JSON200
 		return nil
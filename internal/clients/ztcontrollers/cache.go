@@ -3,11 +3,16 @@ package ztcontrollers
 import (
 	"fmt"
 
+	"github.com/pkg/errors"
 	"github.com/sargassum-world/godest/clientcache"
+
+	"github.com/sargassum-world/fluitans/pkg/secrets"
 )
 
 type Cache struct {
 	Cache clientcache.Cache
+
+	keyring *secrets.Keyring
 }
 
 // /ztcontrollers/controllers/:server/networkIDs
@@ -47,6 +52,17 @@ func keyControllerByAddress(address string) string {
 
 func (c *Cache) SetControllerByAddress(address string, ztController Controller) error {
 	key := keyControllerByAddress(address)
+	// Secrets are kept encrypted in the cache, just like in the database
+	sealedAuthtoken, err := c.keyring.Seal(ztController.Authtoken)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't encrypt authtoken for cache entry %s", key)
+	}
+	ztController.Authtoken = secrets.Redacted(sealedAuthtoken)
+	sealedClientKey, err := c.keyring.Seal(ztController.Transport.ClientKey)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't encrypt client key for cache entry %s", key)
+	}
+	ztController.Transport.ClientKey = secrets.Redacted(sealedClientKey)
 	return c.Cache.SetEntry(key, ztController, ztController.NetworkCostWeight, -1)
 }
 
@@ -63,6 +79,12 @@ func (c *Cache) GetControllerByAddress(address string) (*Controller, bool, error
 		return nil, keyExists, err
 	}
 
+	if value.Authtoken, err = c.keyring.Open(value.Authtoken.Reveal()); err != nil {
+		return nil, true, errors.Wrapf(err, "couldn't decrypt authtoken for cache entry %s", key)
+	}
+	if value.Transport.ClientKey, err = c.keyring.Open(value.Transport.ClientKey.Reveal()); err != nil {
+		return nil, true, errors.Wrapf(err, "couldn't decrypt client key for cache entry %s", key)
+	}
 	return &value, true, nil
 }
 
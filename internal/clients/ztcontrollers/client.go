@@ -5,20 +5,31 @@ package ztcontrollers
 import (
 	"github.com/sargassum-world/godest"
 	"github.com/sargassum-world/godest/clientcache"
+	"github.com/sargassum-world/godest/database"
+
+	"github.com/sargassum-world/fluitans/pkg/secrets"
 )
 
 type Client struct {
 	Config Config
 	Logger godest.Logger
 	Cache  *Cache
+	db     *database.DB
+
+	keyring *secrets.Keyring
 }
 
-func NewClient(c Config, cache clientcache.Cache, l godest.Logger) *Client {
+func NewClient(
+	c Config, cache clientcache.Cache, db *database.DB, keyring *secrets.Keyring, l godest.Logger,
+) *Client {
 	return &Client{
 		Config: c,
 		Logger: l,
 		Cache: &Cache{
-			Cache: cache,
+			Cache:   cache,
+			keyring: keyring,
 		},
+		db:      db,
+		keyring: keyring,
 	}
 }
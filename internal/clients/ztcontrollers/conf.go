@@ -5,6 +5,9 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/sargassum-world/godest/env"
+
+	"github.com/sargassum-world/fluitans/pkg/secrets"
+	"github.com/sargassum-world/fluitans/pkg/transport"
 )
 
 const envPrefix = "ZTCONTROLLER_"
@@ -31,7 +34,7 @@ func GetController() (c Controller, err error) {
 		return Controller{}, nil
 	}
 
-	c.Authtoken = os.Getenv(envPrefix + "AUTHTOKEN")
+	c.Authtoken = secrets.Redacted(os.Getenv(envPrefix + "AUTHTOKEN"))
 	if len(c.Authtoken) == 0 {
 		return Controller{}, nil
 	}
@@ -47,5 +50,10 @@ func GetController() (c Controller, err error) {
 	if err != nil {
 		return Controller{}, errors.Wrap(err, "couldn't make network cost config")
 	}
+
+	c.Transport, err = transport.GetSettings(envPrefix)
+	if err != nil {
+		return Controller{}, errors.Wrap(err, "couldn't make transport config")
+	}
 	return c, nil
 }
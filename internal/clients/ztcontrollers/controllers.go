@@ -2,25 +2,32 @@ package ztcontrollers
 
 import (
 	"context"
+	_ "embed"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 	"github.com/pkg/errors"
+	"github.com/sargassum-world/godest/database"
 	"golang.org/x/sync/errgroup"
+	"zombiezen.com/go/sqlite/sqlitex"
+
+	"github.com/sargassum-world/fluitans/pkg/transport"
 )
 
 // All Controllers
 
-func (c *Client) GetControllers() ([]Controller, error) {
-	// TODO: look up the controllers from a database, if one is specified!
-	controllers := make([]Controller, 0)
-	envController := c.Config.Controller
+//go:embed queries/select-controllers.sql
+var rawSelectControllersQuery string
+var selectControllersQuery string = strings.TrimSpace(rawSelectControllersQuery)
 
-	if envController != (Controller{}) {
-		controllers = append(controllers, envController)
+func (c *Client) GetControllers(ctx context.Context) ([]Controller, error) {
+	sel := newControllersSelector(c.keyring)
+	if err := c.db.ExecuteSelection(ctx, selectControllersQuery, nil, sel.Step); err != nil {
+		return nil, errors.Wrap(err, "couldn't get controllers")
 	}
-	return controllers, nil
+	return sel.Controllers(), nil
 }
 
 func (c *Client) ScanControllers(ctx context.Context, controllers []Controller) ([]string, error) {
@@ -61,18 +68,252 @@ func (c *Client) ScanControllers(ctx context.Context, controllers []Controller)
 
 // Individual Controller
 
-func (c *Client) FindController(name string) (*Controller, error) {
-	controllers, err := c.GetControllers()
+//go:embed queries/select-controller.sql
+var rawSelectControllerQuery string
+var selectControllerQuery string = strings.TrimSpace(rawSelectControllerQuery)
+
+func (c *Client) GetController(ctx context.Context, id int64) (*Controller, error) {
+	sel := newControllersSelector(c.keyring)
+	if err := c.db.ExecuteSelection(
+		ctx, selectControllerQuery, newControllerSelection(id), sel.Step,
+	); err != nil {
+		return nil, errors.Wrapf(err, "couldn't get controller with id %d", id)
+	}
+	controllers := sel.Controllers()
+	if len(controllers) == 0 {
+		return nil, nil
+	}
+	return &controllers[0], nil
+}
+
+//go:embed queries/select-controller-by-name.sql
+var rawSelectControllerByNameQuery string
+var selectControllerByNameQuery string = strings.TrimSpace(rawSelectControllerByNameQuery)
+
+func (c *Client) FindController(ctx context.Context, name string) (*Controller, error) {
+	sel := newControllersSelector(c.keyring)
+	if err := c.db.ExecuteSelection(
+		ctx, selectControllerByNameQuery, newControllerNameSelection(name), sel.Step,
+	); err != nil {
+		return nil, errors.Wrapf(err, "couldn't get controller with name %s", name)
+	}
+	controllers := sel.Controllers()
+	if len(controllers) == 0 {
+		return nil, nil
+	}
+	return &controllers[0], nil
+}
+
+//go:embed queries/insert-controller.sql
+var rawInsertControllerQuery string
+var insertControllerQuery string = strings.TrimSpace(rawInsertControllerQuery)
+
+func (c *Client) sealSecrets(controller Controller) (sealed sealedSecrets, err error) {
+	if sealed.Authtoken, err = c.keyring.Seal(controller.Authtoken); err != nil {
+		return sealedSecrets{}, errors.Wrapf(
+			err, "couldn't encrypt authtoken for controller %s", controller.Name,
+		)
+	}
+	if sealed.ClientKey, err = c.keyring.Seal(controller.Transport.ClientKey); err != nil {
+		return sealedSecrets{}, errors.Wrapf(
+			err, "couldn't encrypt client key for controller %s", controller.Name,
+		)
+	}
+	return sealed, nil
+}
+
+func (c *Client) AddController(ctx context.Context, controller Controller) (id int64, err error) {
+	sealed, err := c.sealSecrets(controller)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
+	id, err = c.db.ExecuteInsertionForID(
+		ctx, insertControllerQuery, controller.newInsertion(sealed),
+	)
+	if err != nil {
+		return 0, errors.Wrapf(err, "couldn't add controller %s", controller.Name)
+	}
+	return id, nil
+}
 
-	for _, v := range controllers {
-		if v.Name == name {
-			return &v, nil
+//go:embed queries/update-controller.sql
+var rawUpdateControllerQuery string
+var updateControllerQuery string = strings.TrimSpace(rawUpdateControllerQuery)
+
+func (c *Client) UpdateController(ctx context.Context, controller Controller) error {
+	prevController, err := c.GetController(ctx, controller.ID)
+	if err != nil {
+		return err
+	}
+	sealed, err := c.sealSecrets(controller)
+	if err != nil {
+		return err
+	}
+	if err = c.db.ExecuteUpdate(
+		ctx, updateControllerQuery, controller.newUpdate(sealed),
+	); err != nil {
+		return errors.Wrapf(err, "couldn't update controller with id %d", controller.ID)
+	}
+
+	if prevController != nil {
+		c.uncacheController(ctx, *prevController)
+	}
+	c.uncacheController(ctx, controller)
+	return nil
+}
+
+//go:embed queries/delete-controller.sql
+var rawDeleteControllerQuery string
+var deleteControllerQuery string = strings.TrimSpace(rawDeleteControllerQuery)
+
+func (c *Client) DeleteController(ctx context.Context, id int64) error {
+	prevController, err := c.GetController(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err = c.db.ExecuteDelete(
+		ctx, deleteControllerQuery, Controller{ID: id}.newDelete(),
+	); err != nil {
+		return errors.Wrapf(err, "couldn't delete controller with id %d", id)
+	}
+
+	if prevController != nil {
+		c.uncacheController(ctx, *prevController)
+	}
+	return nil
+}
+
+// uncacheController removes all cache entries which were derived from the controller, so that
+// stale servers, names, or authtokens won't be used after the controller is edited or removed.
+func (c *Client) uncacheController(ctx context.Context, controller Controller) {
+	address, cacheHit := c.getAddressFromCache(controller)
+	if !cacheHit {
+		// The controller-by-address entry may still exist even if the address-by-server entry was
+		// evicted, so we try to look up the address from the controller itself
+		var err error
+		if address, err = c.getAddressFromZerotier(ctx, controller); err != nil {
+			address = ""
+		}
+	}
+	if address != "" {
+		c.Cache.UnsetControllerByAddress(address)
+	}
+	c.Cache.UnsetAddressByServer(controller.Server)
+	c.Cache.UnsetNetworkIDsByServer(controller.Server)
+	transport.RemoveHTTPClient(controller.Server)
+}
+
+// CheckController attempts to connect to the controller with its authtoken, returning the
+// controller's ZeroTier address if the connection was successful.
+func (c *Client) CheckController(ctx context.Context, controller Controller) (string, error) {
+	client, err := controller.NewClient()
+	if err != nil {
+		return "", echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"couldn't make client for zerotier controller at %s: %s", controller.Server, err,
+		))
+	}
+
+	res, err := client.GetStatusWithResponse(ctx)
+	if err != nil {
+		return "", echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"couldn't connect to zerotier controller at %s: %s", controller.Server, err,
+		))
+	}
+	if res.JSON200 == nil || res.JSON200.Address == nil {
+		return "", echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"zerotier controller at %s responded with status %s (is the authtoken correct?)",
+			controller.Server, res.Status(),
+		))
+	}
+	return *res.JSON200.Address, nil
+}
+
+//go:embed queries/insert-controller-if-absent.sql
+var rawInsertControllerIfAbsentQuery string
+var insertControllerIfAbsentQuery string = strings.TrimSpace(rawInsertControllerIfAbsentQuery)
+
+// SeedEnvController adds the controller specified by environment variables to the database, if
+// one was specified. It's only a bootstrap entry: if a controller with the same server or name is
+// already in the database, the existing database entry is left untouched, so that edits made
+// through Fluitans aren't overwritten on every restart.
+func (c *Client) SeedEnvController(ctx context.Context) error {
+	envController := c.Config.Controller
+	if envController == (Controller{}) {
+		return nil
+	}
+
+	sealed, err := c.sealSecrets(envController)
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(
+		c.db.ExecuteInsertion(
+			ctx, insertControllerIfAbsentQuery, envController.newInsertion(sealed),
+		),
+		"couldn't seed controller %s from environment variables", envController.Name,
+	)
+}
+
+//go:embed queries/select-controller-secrets.sql
+var rawSelectControllerSecretsQuery string
+var selectControllerSecretsQuery string = strings.TrimSpace(rawSelectControllerSecretsQuery)
+
+//go:embed queries/update-controller-secrets.sql
+var rawUpdateControllerSecretsQuery string
+var updateControllerSecretsQuery string = strings.TrimSpace(rawUpdateControllerSecretsQuery)
+
+func (c *Client) resealSecret(stored string) (resealed string, changed bool, err error) {
+	if !c.keyring.NeedsResealing(stored) {
+		return stored, false, nil
+	}
+	if resealed, err = c.keyring.Reseal(stored); err != nil {
+		return "", false, err
+	}
+	return resealed, true, nil
+}
+
+// ResealSecrets re-encrypts every stored authtoken and client key which wasn't encrypted with the
+// current key-encryption key, so that previous keys can be retired after a key rotation. Secrets
+// which were stored unencrypted are encrypted. All secrets are updated in a single transaction.
+func (c *Client) ResealSecrets(ctx context.Context) (err error) {
+	conn, err := c.db.AcquireWriter(ctx)
+	if err != nil {
+		return errors.Wrap(err, "couldn't acquire writer to reseal secrets")
+	}
+	defer c.db.ReleaseWriter(conn)
+	defer sqlitex.Save(conn)(&err)
+
+	sel := newSealedSecretsSelector()
+	if err = database.ExecuteSelection(
+		conn, selectControllerSecretsQuery, nil, sel.Step,
+	); err != nil {
+		return errors.Wrap(err, "couldn't get stored secrets")
+	}
+	resealed := 0
+	for _, id := range sel.ids {
+		stored := sel.secrets[id]
+		var updated sealedSecrets
+		var authtokenChanged, clientKeyChanged bool
+		if updated.Authtoken, authtokenChanged, err = c.resealSecret(stored.Authtoken); err != nil {
+			return errors.Wrapf(err, "couldn't reseal authtoken for controller with id %d", id)
 		}
+		if updated.ClientKey, clientKeyChanged, err = c.resealSecret(stored.ClientKey); err != nil {
+			return errors.Wrapf(err, "couldn't reseal client key for controller with id %d", id)
+		}
+		if !authtokenChanged && !clientKeyChanged {
+			continue
+		}
+		if err = database.ExecuteUpdate(
+			conn, updateControllerSecretsQuery, newSecretsUpdate(id, updated),
+		); err != nil {
+			return errors.Wrapf(err, "couldn't update secrets for controller with id %d", id)
+		}
+		resealed++
 	}
-	return nil, nil
+	if resealed > 0 {
+		c.Logger.Infof("re-encrypted the stored secrets of %d zerotier controllers", resealed)
+	}
+	return nil
 }
 
 func (c *Client) checkCachedController(ctx context.Context, address string) (*Controller, error) {
@@ -102,6 +343,13 @@ func (c *Client) checkCachedController(ctx context.Context, address string) (*Co
 			"zerotier controller %s's address has changed from %s to %s",
 			controller.Server, address, *res.JSON200.Address,
 		)
+		if _, err = c.RecordControllerState(
+			ctx, *controller, newControllerState(*res.JSON200),
+		); err != nil {
+			c.Logger.Error(errors.Wrapf(
+				err, "couldn't record address change of zerotier controller %s", controller.Server,
+			))
+		}
 		err = c.Cache.SetControllerByAddress(*res.JSON200.Address, *controller)
 		if err != nil {
 			return nil, err
@@ -127,7 +375,7 @@ func (c *Client) FindControllerByAddress(ctx context.Context, address string) (*
 	}
 
 	// Query the list of all known controllers
-	controllers, err := c.GetControllers()
+	controllers, err := c.GetControllers(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,173 @@
+package ztcontrollers
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ObserveController queries the controller for its current state. A controller which can't be
+// queried is observed as unreachable, rather than producing an error.
+func (c *Client) ObserveController(ctx context.Context, controller Controller) ControllerState {
+	client, err := controller.NewClient()
+	if err != nil {
+		return ControllerState{}
+	}
+	res, err := client.GetStatusWithResponse(ctx)
+	if err != nil || res.JSON200 == nil {
+		return ControllerState{}
+	}
+	return newControllerState(*res.JSON200)
+}
+
+func describeStateChange(prev *ControllerState, state ControllerState) string {
+	if prev == nil {
+		prev = &ControllerState{}
+	}
+	changes := make([]string, 0)
+	if prev.Reachable != state.Reachable {
+		if state.Reachable {
+			changes = append(changes, "became reachable")
+		} else {
+			changes = append(changes, "became unreachable")
+		}
+	}
+	if prev.Online != state.Online {
+		if state.Online {
+			changes = append(changes, "came online")
+		} else {
+			changes = append(changes, "went offline")
+		}
+	}
+	if state.Reachable && prev.Version != "" && prev.Version != state.Version {
+		changes = append(changes, fmt.Sprintf(
+			"changed version from %s to %s", prev.Version, state.Version,
+		))
+	}
+	if state.Reachable && prev.Address != "" && prev.Address != state.Address {
+		changes = append(changes, fmt.Sprintf(
+			"changed address from %s to %s", prev.Address, state.Address,
+		))
+	}
+	return strings.Join(changes, ", ")
+}
+
+//go:embed queries/insert-controller-event.sql
+var rawInsertControllerEventQuery string
+var insertControllerEventQuery string = strings.TrimSpace(rawInsertControllerEventQuery)
+
+// RecordControllerState adds an event to the controller's health history if the state differs
+// from the most recently recorded state of the controller. It returns the added event, or nil if
+// the state hasn't changed.
+func (c *Client) RecordControllerState(
+	ctx context.Context, controller Controller, state ControllerState,
+) (*ControllerEvent, error) {
+	latest, err := c.GetLatestControllerEvent(ctx, controller.ID)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil && !state.Reachable {
+		// We keep the last known version and address, so that changes to them across periods of
+		// unreachability are still detected
+		state.Version = latest.State.Version
+		state.Address = latest.State.Address
+	}
+	if latest != nil && latest.State == state {
+		return nil, nil
+	}
+
+	event := ControllerEvent{
+		ControllerID: controller.ID,
+		Time:         time.Now(),
+		State:        state,
+	}
+	if latest == nil {
+		event.Description = "started being monitored"
+	} else {
+		event.Description = describeStateChange(&latest.State, state)
+	}
+	if event.ID, err = c.db.ExecuteInsertionForID(
+		ctx, insertControllerEventQuery, event.newInsertion(),
+	); err != nil {
+		return nil, errors.Wrapf(err, "couldn't record state of controller %s", controller.Name)
+	}
+	return &event, nil
+}
+
+//go:embed queries/select-latest-controller-event.sql
+var rawSelectLatestEventQuery string
+var selectLatestEventQuery string = strings.TrimSpace(rawSelectLatestEventQuery)
+
+func (c *Client) GetLatestControllerEvent(
+	ctx context.Context, controllerID int64,
+) (*ControllerEvent, error) {
+	sel := newControllerEventsSelector()
+	if err := c.db.ExecuteSelection(
+		ctx, selectLatestEventQuery, newLatestControllerEventSelection(controllerID),
+		sel.Step,
+	); err != nil {
+		return nil, errors.Wrapf(
+			err, "couldn't get latest event for controller with id %d", controllerID,
+		)
+	}
+	events := sel.Events()
+	if len(events) == 0 {
+		return nil, nil
+	}
+	return &events[0], nil
+}
+
+//go:embed queries/select-controller-events.sql
+var rawSelectControllerEventsQuery string
+var selectControllerEventsQuery string = strings.TrimSpace(rawSelectControllerEventsQuery)
+
+// GetControllerEvents returns the controller's events since the start time in chronological order,
+// including the last event before the start time (which describes the state at the start time).
+func (c *Client) GetControllerEvents(
+	ctx context.Context, controllerID int64, start time.Time,
+) ([]ControllerEvent, error) {
+	sel := newControllerEventsSelector()
+	if err := c.db.ExecuteSelection(
+		ctx, selectControllerEventsQuery, newControllerEventsSelection(controllerID, start),
+		sel.Step,
+	); err != nil {
+		return nil, errors.Wrapf(err, "couldn't get events for controller with id %d", controllerID)
+	}
+	return sel.Events(), nil
+}
+
+// ComputeUptime calculates the fraction of time between the start and end times during which the
+// controller was online, according to its events in chronological order. Time before the first
+// event is not counted, and the returned duration is the length of time which was counted.
+func ComputeUptime(
+	events []ControllerEvent, start, end time.Time,
+) (uptime float64, monitored time.Duration) {
+	var online time.Duration
+	for i, event := range events {
+		intervalStart := event.Time
+		if intervalStart.Before(start) {
+			intervalStart = start
+		}
+		intervalEnd := end
+		if i+1 < len(events) {
+			intervalEnd = events[i+1].Time
+		}
+		if !intervalEnd.After(intervalStart) {
+			continue
+		}
+
+		duration := intervalEnd.Sub(intervalStart)
+		monitored += duration
+		if event.State.Reachable && event.State.Online {
+			online += duration
+		}
+	}
+	if monitored == 0 {
+		return 0, 0
+	}
+	return float64(online) / float64(monitored), monitored
+}
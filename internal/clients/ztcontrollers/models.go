@@ -1,17 +1,274 @@
 package ztcontrollers
 
 import (
+	"time"
+
+	"github.com/pkg/errors"
+	"zombiezen.com/go/sqlite"
+
+	"github.com/sargassum-world/fluitans/pkg/secrets"
+	"github.com/sargassum-world/fluitans/pkg/transport"
 	"github.com/sargassum-world/fluitans/pkg/zerotier"
 )
 
 type Controller struct {
-	Server            string  `json:"server"`
-	Name              string  `json:"name"` // Must be unique for display purposes!
-	Description       string  `json:"description"`
-	Authtoken         string  `json:"authtoken"`
-	NetworkCostWeight float32 `json:"local"`
+	ID                int64              `json:"id"`
+	Server            string             `json:"server"`
+	Name              string             `json:"name"` // Must be unique for display purposes!
+	Description       string             `json:"description"`
+	Authtoken         secrets.Redacted   `json:"authtoken"`
+	NetworkCostWeight float32            `json:"local"`
+	Transport         transport.Settings `json:"transport"`
 }
 
 func (c Controller) NewClient() (*zerotier.ClientWithResponses, error) {
-	return zerotier.NewAuthClientWithResponses(c.Server, c.Authtoken)
+	httpClient, err := transport.GetHTTPClient(c.Server, c.Transport)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't make http client for %s", c.Server)
+	}
+	return zerotier.NewAuthClientWithResponses(
+		c.Server, c.Authtoken.Reveal(), zerotier.WithHTTPClient(httpClient),
+	)
+}
+
+// sealedSecrets holds the encrypted forms of a controller's secrets, for storage.
+type sealedSecrets struct {
+	Authtoken string
+	ClientKey string
+}
+
+func (c Controller) newInsertion(sealed sealedSecrets) map[string]interface{} {
+	return map[string]interface{}{
+		"$server":                c.Server,
+		"$name":                  c.Name,
+		"$description":           c.Description,
+		"$authtoken":             sealed.Authtoken,
+		"$network_cost_weight":   float64(c.NetworkCostWeight),
+		"$transport_timeout":     c.Transport.Timeout.Milliseconds(),
+		"$transport_ca_bundle":   c.Transport.CABundle,
+		"$transport_client_cert": c.Transport.ClientCert,
+		"$transport_client_key":  sealed.ClientKey,
+		"$transport_proxy_url":   c.Transport.ProxyURL,
+	}
+}
+
+func (c Controller) newUpdate(sealed sealedSecrets) map[string]interface{} {
+	return map[string]interface{}{
+		"$id":                    c.ID,
+		"$server":                c.Server,
+		"$name":                  c.Name,
+		"$description":           c.Description,
+		"$authtoken":             sealed.Authtoken,
+		"$network_cost_weight":   float64(c.NetworkCostWeight),
+		"$transport_timeout":     c.Transport.Timeout.Milliseconds(),
+		"$transport_ca_bundle":   c.Transport.CABundle,
+		"$transport_client_cert": c.Transport.ClientCert,
+		"$transport_client_key":  sealed.ClientKey,
+		"$transport_proxy_url":   c.Transport.ProxyURL,
+	}
+}
+
+func (c Controller) newDelete() map[string]interface{} {
+	return map[string]interface{}{
+		"$id": c.ID,
+	}
+}
+
+func newControllerSelection(id int64) map[string]interface{} {
+	return map[string]interface{}{
+		"$id": id,
+	}
+}
+
+func newControllerNameSelection(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"$name": name,
+	}
+}
+
+// Controllers
+
+type controllersSelector struct {
+	keyring     *secrets.Keyring
+	ids         []int64
+	controllers map[int64]Controller
+}
+
+func newControllersSelector(keyring *secrets.Keyring) *controllersSelector {
+	return &controllersSelector{
+		keyring:     keyring,
+		ids:         make([]int64, 0),
+		controllers: make(map[int64]Controller),
+	}
+}
+
+func (sel *controllersSelector) Step(s *sqlite.Stmt) error {
+	id := s.GetInt64("id")
+	if _, ok := sel.controllers[id]; !ok {
+		authtoken, err := sel.keyring.Open(s.GetText("authtoken"))
+		if err != nil {
+			return errors.Wrapf(err, "couldn't decrypt authtoken for controller with id %d", id)
+		}
+		clientKey, err := sel.keyring.Open(s.GetText("transport_client_key"))
+		if err != nil {
+			return errors.Wrapf(err, "couldn't decrypt client key for controller with id %d", id)
+		}
+		sel.controllers[id] = Controller{
+			ID:                id,
+			Server:            s.GetText("server"),
+			Name:              s.GetText("name"),
+			Description:       s.GetText("description"),
+			Authtoken:         authtoken,
+			NetworkCostWeight: float32(s.GetFloat("network_cost_weight")),
+			Transport: transport.Settings{
+				Timeout:    time.Duration(s.GetInt64("transport_timeout")) * time.Millisecond,
+				CABundle:   s.GetText("transport_ca_bundle"),
+				ClientCert: s.GetText("transport_client_cert"),
+				ClientKey:  clientKey,
+				ProxyURL:   s.GetText("transport_proxy_url"),
+			},
+		}
+		sel.ids = append(sel.ids, id)
+	}
+	return nil
+}
+
+func (sel *controllersSelector) Controllers() []Controller {
+	controllers := make([]Controller, len(sel.ids))
+	for i, id := range sel.ids {
+		controllers[i] = sel.controllers[id]
+	}
+	return controllers
+}
+
+// Sealed Secrets
+
+type sealedSecretsSelector struct {
+	ids     []int64
+	secrets map[int64]sealedSecrets
+}
+
+func newSealedSecretsSelector() *sealedSecretsSelector {
+	return &sealedSecretsSelector{
+		ids:     make([]int64, 0),
+		secrets: make(map[int64]sealedSecrets),
+	}
+}
+
+func (sel *sealedSecretsSelector) Step(s *sqlite.Stmt) error {
+	id := s.GetInt64("id")
+	if _, ok := sel.secrets[id]; !ok {
+		sel.secrets[id] = sealedSecrets{
+			Authtoken: s.GetText("authtoken"),
+			ClientKey: s.GetText("transport_client_key"),
+		}
+		sel.ids = append(sel.ids, id)
+	}
+	return nil
+}
+
+func newSecretsUpdate(id int64, sealed sealedSecrets) map[string]interface{} {
+	return map[string]interface{}{
+		"$id":                   id,
+		"$authtoken":            sealed.Authtoken,
+		"$transport_client_key": sealed.ClientKey,
+	}
+}
+
+// Controller Health
+
+// ControllerState is the health of a controller, as observed by Fluitans.
+type ControllerState struct {
+	Reachable bool   `json:"reachable"`
+	Online    bool   `json:"online"`
+	Version   string `json:"version"`
+	Address   string `json:"address"`
+}
+
+func newControllerState(status zerotier.Status) ControllerState {
+	state := ControllerState{
+		Reachable: true,
+		Online:    status.Online != nil && *status.Online,
+	}
+	if status.Version != nil {
+		state.Version = *status.Version
+	}
+	if status.Address != nil {
+		state.Address = *status.Address
+	}
+	return state
+}
+
+// ControllerEvent is a recorded change in the state of a controller.
+type ControllerEvent struct {
+	ID           int64           `json:"id"`
+	ControllerID int64           `json:"controllerId"`
+	Time         time.Time       `json:"time"`
+	State        ControllerState `json:"state"`
+	Description  string          `json:"description"`
+}
+
+func (e ControllerEvent) newInsertion() map[string]interface{} {
+	return map[string]interface{}{
+		"$controller_id":    e.ControllerID,
+		"$observation_time": e.Time.UnixMilli(),
+		"$reachable":        e.State.Reachable,
+		"$online":           e.State.Online,
+		"$version":          e.State.Version,
+		"$address":          e.State.Address,
+		"$description":      e.Description,
+	}
+}
+
+func newControllerEventsSelection(controllerID int64, start time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"$controller_id": controllerID,
+		"$start_time":    start.UnixMilli(),
+	}
+}
+
+func newLatestControllerEventSelection(controllerID int64) map[string]interface{} {
+	return map[string]interface{}{
+		"$controller_id": controllerID,
+	}
+}
+
+type controllerEventsSelector struct {
+	ids    []int64
+	events map[int64]ControllerEvent
+}
+
+func newControllerEventsSelector() *controllerEventsSelector {
+	return &controllerEventsSelector{
+		ids:    make([]int64, 0),
+		events: make(map[int64]ControllerEvent),
+	}
+}
+
+func (sel *controllerEventsSelector) Step(s *sqlite.Stmt) error {
+	id := s.GetInt64("id")
+	if _, ok := sel.events[id]; !ok {
+		sel.events[id] = ControllerEvent{
+			ID:           id,
+			ControllerID: s.GetInt64("controller_id"),
+			Time:         time.UnixMilli(s.GetInt64("observation_time")),
+			State: ControllerState{
+				Reachable: s.GetBool("reachable"),
+				Online:    s.GetBool("online"),
+				Version:   s.GetText("version"),
+				Address:   s.GetText("address"),
+			},
+			Description: s.GetText("description"),
+		}
+		sel.ids = append(sel.ids, id)
+	}
+	return nil
+}
+
+func (sel *controllerEventsSelector) Events() []ControllerEvent {
+	events := make([]ControllerEvent, len(sel.ids))
+	for i, id := range sel.ids {
+		events[i] = sel.events[id]
+	}
+	return events
 }
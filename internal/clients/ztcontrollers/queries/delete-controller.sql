@@ -0,0 +1,2 @@
+delete from ztcontrollers_controller
+where ztcontrollers_controller.id = $id
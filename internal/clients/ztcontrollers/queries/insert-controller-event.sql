@@ -0,0 +1,6 @@
+insert into ztcontrollers_controller_event (
+  controller_id, observation_time, reachable, online, version, address, description
+)
+values (
+  $controller_id, $observation_time, $reachable, $online, $version, $address, $description
+);
@@ -0,0 +1,10 @@
+insert into ztcontrollers_controller (
+  server, name, description, authtoken, network_cost_weight,
+  transport_timeout, transport_ca_bundle, transport_client_cert, transport_client_key,
+  transport_proxy_url
+)
+values (
+  $server, $name, $description, $authtoken, $network_cost_weight,
+  $transport_timeout, $transport_ca_bundle, $transport_client_cert, $transport_client_key,
+  $transport_proxy_url
+);
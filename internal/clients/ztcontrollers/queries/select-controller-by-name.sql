@@ -0,0 +1,14 @@
+select
+  c.id                    as id,
+  c.server                as server,
+  c.name                  as name,
+  c.description           as description,
+  c.authtoken             as authtoken,
+  c.network_cost_weight   as network_cost_weight,
+  c.transport_timeout     as transport_timeout,
+  c.transport_ca_bundle   as transport_ca_bundle,
+  c.transport_client_cert as transport_client_cert,
+  c.transport_client_key  as transport_client_key,
+  c.transport_proxy_url   as transport_proxy_url
+from ztcontrollers_controller as c
+where c.name = $name
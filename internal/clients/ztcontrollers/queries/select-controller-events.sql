@@ -0,0 +1,19 @@
+select
+  e.id               as id,
+  e.controller_id    as controller_id,
+  e.observation_time as observation_time,
+  e.reachable        as reachable,
+  e.online           as online,
+  e.version          as version,
+  e.address          as address,
+  e.description      as description
+from ztcontrollers_controller_event as e
+where
+  e.controller_id = $controller_id
+  and e.observation_time >= (
+    -- Include the last event before the start time, since it describes the state at the start time
+    select coalesce(max(p.observation_time), $start_time)
+    from ztcontrollers_controller_event as p
+    where p.controller_id = $controller_id and p.observation_time <= $start_time
+  )
+order by e.observation_time asc, e.id asc
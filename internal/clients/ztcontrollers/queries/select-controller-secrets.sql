@@ -0,0 +1,6 @@
+select
+  c.id                   as id,
+  c.authtoken            as authtoken,
+  c.transport_client_key as transport_client_key
+from ztcontrollers_controller as c
+order by c.id asc
@@ -0,0 +1,13 @@
+select
+  e.id               as id,
+  e.controller_id    as controller_id,
+  e.observation_time as observation_time,
+  e.reachable        as reachable,
+  e.online           as online,
+  e.version          as version,
+  e.address          as address,
+  e.description      as description
+from ztcontrollers_controller_event as e
+where e.controller_id = $controller_id
+order by e.observation_time desc, e.id desc
+limit 1
@@ -0,0 +1,5 @@
+update ztcontrollers_controller
+set
+  authtoken = $authtoken,
+  transport_client_key = $transport_client_key
+where ztcontrollers_controller.id = $id
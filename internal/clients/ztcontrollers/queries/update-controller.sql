@@ -0,0 +1,13 @@
+update ztcontrollers_controller
+set
+  server = $server,
+  name = $name,
+  description = $description,
+  authtoken = $authtoken,
+  network_cost_weight = $network_cost_weight,
+  transport_timeout = $transport_timeout,
+  transport_ca_bundle = $transport_ca_bundle,
+  transport_client_cert = $transport_client_cert,
+  transport_client_key = $transport_client_key,
+  transport_proxy_url = $transport_proxy_url
+where ztcontrollers_controller.id = $id
@@ -0,0 +1,20 @@
+// Package ztdevices provides a high-level client for management of human-friendly information
+// about the members of Zerotier networks
+package ztdevices
+
+import (
+	"github.com/sargassum-world/godest"
+	"github.com/sargassum-world/godest/database"
+)
+
+type Client struct {
+	Logger godest.Logger
+	db     *database.DB
+}
+
+func NewClient(db *database.DB, l godest.Logger) *Client {
+	return &Client{
+		Logger: l,
+		db:     db,
+	}
+}
@@ -0,0 +1,85 @@
+package ztdevices
+
+import (
+	"context"
+	_ "embed"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// All Devices
+
+//go:embed queries/select-devices.sql
+var rawSelectDevicesQuery string
+var selectDevicesQuery string = strings.TrimSpace(rawSelectDevicesQuery)
+
+// GetDevices returns the information about the members of the network, keyed by member address.
+// Members without any information are omitted.
+func (c *Client) GetDevices(ctx context.Context, networkID string) (map[string]Device, error) {
+	sel := newDevicesSelector()
+	if err := c.db.ExecuteSelection(
+		ctx, selectDevicesQuery, newDevicesSelection(networkID), sel.Step,
+	); err != nil {
+		return nil, errors.Wrapf(err, "couldn't get devices of network %s", networkID)
+	}
+	return sel.Devices(), nil
+}
+
+// Individual Device
+
+//go:embed queries/select-device.sql
+var rawSelectDeviceQuery string
+var selectDeviceQuery string = strings.TrimSpace(rawSelectDeviceQuery)
+
+// GetDevice returns the information about the member of the network, which is empty if no
+// information was stored.
+func (c *Client) GetDevice(ctx context.Context, networkID, memberAddress string) (Device, error) {
+	sel := newDevicesSelector()
+	if err := c.db.ExecuteSelection(
+		ctx, selectDeviceQuery, newDeviceSelection(networkID, memberAddress), sel.Step,
+	); err != nil {
+		return Device{}, errors.Wrapf(
+			err, "couldn't get device for network %s member %s", networkID, memberAddress,
+		)
+	}
+	device, ok := sel.Devices()[memberAddress]
+	if !ok {
+		return Device{NetworkID: networkID, MemberAddress: memberAddress}, nil
+	}
+	return device, nil
+}
+
+//go:embed queries/upsert-device.sql
+var rawUpsertDeviceQuery string
+var upsertDeviceQuery string = strings.TrimSpace(rawUpsertDeviceQuery)
+
+//go:embed queries/delete-device.sql
+var rawDeleteDeviceQuery string
+var deleteDeviceQuery string = strings.TrimSpace(rawDeleteDeviceQuery)
+
+// SetDevice stores the information about the member of the network, replacing any previous
+// information. Empty information is deleted instead of being stored.
+func (c *Client) SetDevice(ctx context.Context, device Device) error {
+	if device.Empty() {
+		return c.DeleteDevice(ctx, device.NetworkID, device.MemberAddress)
+	}
+	if err := c.db.ExecuteInsertion(ctx, upsertDeviceQuery, device.newUpsert()); err != nil {
+		return errors.Wrapf(
+			err, "couldn't set device for network %s member %s",
+			device.NetworkID, device.MemberAddress,
+		)
+	}
+	return nil
+}
+
+func (c *Client) DeleteDevice(ctx context.Context, networkID, memberAddress string) error {
+	if err := c.db.ExecuteDelete(
+		ctx, deleteDeviceQuery, newDeviceSelection(networkID, memberAddress),
+	); err != nil {
+		return errors.Wrapf(
+			err, "couldn't delete device for network %s member %s", networkID, memberAddress,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package ztdevices
+
+import (
+	"context"
+	_ "embed"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// All Expirations
+
+//go:embed queries/select-expirations.sql
+var rawSelectExpirationsQuery string
+var selectExpirationsQuery string = strings.TrimSpace(rawSelectExpirationsQuery)
+
+// GetExpirations returns the authorization expirations of the members of the network, keyed by
+// member address. Members which are authorized permanently are omitted.
+func (c *Client) GetExpirations(
+	ctx context.Context, networkID string,
+) (map[string]Expiration, error) {
+	sel := newExpirationsSelector()
+	if err := c.db.ExecuteSelection(
+		ctx, selectExpirationsQuery, newDevicesSelection(networkID), sel.Step,
+	); err != nil {
+		return nil, errors.Wrapf(err, "couldn't get authorization expirations of network %s", networkID)
+	}
+	expirations := make(map[string]Expiration)
+	for _, expiration := range sel.Expirations() {
+		expirations[expiration.MemberAddress] = expiration
+	}
+	return expirations, nil
+}
+
+//go:embed queries/select-expirations-by-time.sql
+var rawSelectExpirationsByTimeQuery string
+var selectExpirationsByTimeQuery string = strings.TrimSpace(rawSelectExpirationsByTimeQuery)
+
+// GetExpiredAuthorizations returns the authorization expirations of members of all networks which
+// expired by the time, from the earliest to the latest.
+func (c *Client) GetExpiredAuthorizations(ctx context.Context, t time.Time) ([]Expiration, error) {
+	sel := newExpirationsSelector()
+	if err := c.db.ExecuteSelection(
+		ctx, selectExpirationsByTimeQuery, newExpirationsTimeSelection(t), sel.Step,
+	); err != nil {
+		return nil, errors.Wrap(err, "couldn't get expired authorizations")
+	}
+	return sel.Expirations(), nil
+}
+
+// Individual Expiration
+
+//go:embed queries/upsert-expiration.sql
+var rawUpsertExpirationQuery string
+var upsertExpirationQuery string = strings.TrimSpace(rawUpsertExpirationQuery)
+
+// SetExpiration stores the time when the member's authorization should be revoked, replacing any
+// previous expiration.
+func (c *Client) SetExpiration(ctx context.Context, expiration Expiration) error {
+	if err := c.db.ExecuteInsertion(ctx, upsertExpirationQuery, expiration.newUpsert()); err != nil {
+		return errors.Wrapf(
+			err, "couldn't set authorization expiration for network %s member %s",
+			expiration.NetworkID, expiration.MemberAddress,
+		)
+	}
+	return nil
+}
+
+//go:embed queries/delete-expiration.sql
+var rawDeleteExpirationQuery string
+var deleteExpirationQuery string = strings.TrimSpace(rawDeleteExpirationQuery)
+
+// DeleteExpiration makes the member's authorization permanent, if it's authorized.
+func (c *Client) DeleteExpiration(ctx context.Context, networkID, memberAddress string) error {
+	if err := c.db.ExecuteDelete(
+		ctx, deleteExpirationQuery, newDeviceSelection(networkID, memberAddress),
+	); err != nil {
+		return errors.Wrapf(
+			err, "couldn't delete authorization expiration for network %s member %s",
+			networkID, memberAddress,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package ztdevices
+
+import (
+	"time"
+
+	"zombiezen.com/go/sqlite"
+)
+
+// Device is human-friendly information about a member of a network.
+type Device struct {
+	ID            int64
+	NetworkID     string
+	MemberAddress string
+	DisplayName   string
+	Description   string
+	Owner         string
+	AssetTag      string // Hardware or asset tag
+	Notes         string
+}
+
+// Empty checks whether the device has no information besides its identity.
+func (d Device) Empty() bool {
+	return d.DisplayName == "" && d.Description == "" && d.Owner == "" && d.AssetTag == "" &&
+		d.Notes == ""
+}
+
+func (d Device) newUpsert() map[string]interface{} {
+	return map[string]interface{}{
+		"$network_id":     d.NetworkID,
+		"$member_address": d.MemberAddress,
+		"$display_name":   d.DisplayName,
+		"$description":    d.Description,
+		"$owner":          d.Owner,
+		"$asset_tag":      d.AssetTag,
+		"$notes":          d.Notes,
+	}
+}
+
+func newDeviceSelection(networkID, memberAddress string) map[string]interface{} {
+	return map[string]interface{}{
+		"$network_id":     networkID,
+		"$member_address": memberAddress,
+	}
+}
+
+func newDevicesSelection(networkID string) map[string]interface{} {
+	return map[string]interface{}{
+		"$network_id": networkID,
+	}
+}
+
+// Expiration is the time when a network member's authorization should be revoked.
+type Expiration struct {
+	ID            int64
+	NetworkID     string
+	MemberAddress string
+	Time          time.Time
+}
+
+func (e Expiration) newUpsert() map[string]interface{} {
+	return map[string]interface{}{
+		"$network_id":      e.NetworkID,
+		"$member_address":  e.MemberAddress,
+		"$expiration_time": e.Time.UnixMilli(),
+	}
+}
+
+func newExpirationsTimeSelection(t time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"$time": t.UnixMilli(),
+	}
+}
+
+// Reservation is an IP address which is being assigned to a network member, and which shouldn't be
+// chosen for other members until the reservation expires.
+type Reservation struct {
+	ID            int64
+	NetworkID     string
+	IPAddress     string
+	MemberAddress string
+	Expiration    time.Time
+}
+
+func (r Reservation) newInsertion() map[string]interface{} {
+	return map[string]interface{}{
+		"$network_id":      r.NetworkID,
+		"$ip_address":      r.IPAddress,
+		"$member_address":  r.MemberAddress,
+		"$expiration_time": r.Expiration.UnixMilli(),
+	}
+}
+
+func newReservationSelection(networkID, ipAddress string) map[string]interface{} {
+	return map[string]interface{}{
+		"$network_id": networkID,
+		"$ip_address": ipAddress,
+	}
+}
+
+// Devices
+
+type devicesSelector struct {
+	devices []Device
+}
+
+func newDevicesSelector() *devicesSelector {
+	return &devicesSelector{
+		devices: make([]Device, 0),
+	}
+}
+
+func (sel *devicesSelector) Step(s *sqlite.Stmt) error {
+	sel.devices = append(sel.devices, Device{
+		ID:            s.GetInt64("id"),
+		NetworkID:     s.GetText("network_id"),
+		MemberAddress: s.GetText("member_address"),
+		DisplayName:   s.GetText("display_name"),
+		Description:   s.GetText("description"),
+		Owner:         s.GetText("owner"),
+		AssetTag:      s.GetText("asset_tag"),
+		Notes:         s.GetText("notes"),
+	})
+	return nil
+}
+
+// Devices returns the selected devices, keyed by member address.
+func (sel *devicesSelector) Devices() map[string]Device {
+	devices := make(map[string]Device)
+	for _, device := range sel.devices {
+		devices[device.MemberAddress] = device
+	}
+	return devices
+}
+
+// Expirations
+
+type expirationsSelector struct {
+	expirations []Expiration
+}
+
+func newExpirationsSelector() *expirationsSelector {
+	return &expirationsSelector{
+		expirations: make([]Expiration, 0),
+	}
+}
+
+func (sel *expirationsSelector) Step(s *sqlite.Stmt) error {
+	sel.expirations = append(sel.expirations, Expiration{
+		ID:            s.GetInt64("id"),
+		NetworkID:     s.GetText("network_id"),
+		MemberAddress: s.GetText("member_address"),
+		Time:          time.UnixMilli(s.GetInt64("expiration_time")),
+	})
+	return nil
+}
+
+func (sel *expirationsSelector) Expirations() []Expiration {
+	return sel.expirations
+}
+
+// Reservations
+
+type reservationsSelector struct {
+	reservations []Reservation
+}
+
+func newReservationsSelector() *reservationsSelector {
+	return &reservationsSelector{
+		reservations: make([]Reservation, 0),
+	}
+}
+
+func (sel *reservationsSelector) Step(s *sqlite.Stmt) error {
+	sel.reservations = append(sel.reservations, Reservation{
+		ID:            s.GetInt64("id"),
+		NetworkID:     s.GetText("network_id"),
+		IPAddress:     s.GetText("ip_address"),
+		MemberAddress: s.GetText("member_address"),
+		Expiration:    time.UnixMilli(s.GetInt64("expiration_time")),
+	})
+	return nil
+}
+
+func (sel *reservationsSelector) Reservations() []Reservation {
+	return sel.reservations
+}
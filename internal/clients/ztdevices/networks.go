@@ -0,0 +1,46 @@
+package ztdevices
+
+import (
+	"context"
+	_ "embed"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sargassum-world/godest/database"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+//go:embed queries/delete-devices.sql
+var rawDeleteDevicesQuery string
+var deleteDevicesQuery string = strings.TrimSpace(rawDeleteDevicesQuery)
+
+//go:embed queries/delete-expirations.sql
+var rawDeleteExpirationsQuery string
+var deleteExpirationsQuery string = strings.TrimSpace(rawDeleteExpirationsQuery)
+
+//go:embed queries/delete-reservations.sql
+var rawDeleteReservationsQuery string
+var deleteReservationsQuery string = strings.TrimSpace(rawDeleteReservationsQuery)
+
+// DeleteNetwork deletes the information, authorization expirations, and IP address reservations
+// of all members of the network, e.g. after the network itself was deleted.
+func (c *Client) DeleteNetwork(ctx context.Context, networkID string) (err error) {
+	conn, err := c.db.AcquireWriter(ctx)
+	if err != nil {
+		return errors.Wrap(err, "couldn't acquire writer to delete network devices")
+	}
+	defer c.db.ReleaseWriter(conn)
+	defer sqlitex.Save(conn)(&err)
+
+	selection := newDevicesSelection(networkID)
+	if err = database.ExecuteDelete(conn, deleteDevicesQuery, selection); err != nil {
+		return errors.Wrapf(err, "couldn't delete devices of network %s", networkID)
+	}
+	if err = database.ExecuteDelete(conn, deleteExpirationsQuery, selection); err != nil {
+		return errors.Wrapf(err, "couldn't delete authorization expirations of network %s", networkID)
+	}
+	if err = database.ExecuteDelete(conn, deleteReservationsQuery, selection); err != nil {
+		return errors.Wrapf(err, "couldn't delete ip address reservations of network %s", networkID)
+	}
+	return nil
+}
@@ -0,0 +1,4 @@
+delete from ztdevices_device
+where
+  ztdevices_device.network_id = $network_id
+  and ztdevices_device.member_address = $member_address
@@ -0,0 +1,2 @@
+delete from ztdevices_device
+where ztdevices_device.network_id = $network_id
@@ -0,0 +1,4 @@
+delete from ztdevices_expiration
+where
+  ztdevices_expiration.network_id = $network_id
+  and ztdevices_expiration.member_address = $member_address
@@ -0,0 +1,2 @@
+delete from ztdevices_expiration
+where ztdevices_expiration.network_id = $network_id
@@ -0,0 +1,4 @@
+delete from ztdevices_reservation
+where
+  ztdevices_reservation.network_id = $network_id
+  and ztdevices_reservation.ip_address = $ip_address
@@ -0,0 +1,2 @@
+delete from ztdevices_reservation
+where ztdevices_reservation.expiration_time <= $time
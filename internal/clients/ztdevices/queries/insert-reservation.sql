@@ -0,0 +1,2 @@
+insert into ztdevices_reservation (network_id, ip_address, member_address, expiration_time)
+values ($network_id, $ip_address, $member_address, $expiration_time)
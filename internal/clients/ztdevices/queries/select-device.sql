@@ -0,0 +1,11 @@
+select
+  d.id             as id,
+  d.network_id     as network_id,
+  d.member_address as member_address,
+  d.display_name   as display_name,
+  d.description    as description,
+  d.owner          as owner,
+  d.asset_tag      as asset_tag,
+  d.notes          as notes
+from ztdevices_device as d
+where d.network_id = $network_id and d.member_address = $member_address
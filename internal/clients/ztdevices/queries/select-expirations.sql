@@ -0,0 +1,8 @@
+select
+  e.id              as id,
+  e.network_id      as network_id,
+  e.member_address  as member_address,
+  e.expiration_time as expiration_time
+from ztdevices_expiration as e
+where e.network_id = $network_id
+order by e.member_address asc
@@ -0,0 +1,9 @@
+select
+  r.id              as id,
+  r.network_id      as network_id,
+  r.ip_address      as ip_address,
+  r.member_address  as member_address,
+  r.expiration_time as expiration_time
+from ztdevices_reservation as r
+where r.network_id = $network_id
+order by r.ip_address asc
@@ -0,0 +1,11 @@
+insert into ztdevices_device (
+  network_id, member_address, display_name, description, owner, asset_tag, notes
+)
+values ($network_id, $member_address, $display_name, $description, $owner, $asset_tag, $notes)
+on conflict (network_id, member_address) do update
+set
+  display_name = excluded.display_name,
+  description = excluded.description,
+  owner = excluded.owner,
+  asset_tag = excluded.asset_tag,
+  notes = excluded.notes;
@@ -0,0 +1,4 @@
+insert into ztdevices_expiration (network_id, member_address, expiration_time)
+values ($network_id, $member_address, $expiration_time)
+on conflict (network_id, member_address) do update
+set expiration_time = excluded.expiration_time;
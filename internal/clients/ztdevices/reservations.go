@@ -0,0 +1,111 @@
+package ztdevices
+
+import (
+	"context"
+	_ "embed"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sargassum-world/godest/database"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+//go:embed queries/delete-reservations-expired.sql
+var rawDeleteExpiredReservationsQuery string
+var deleteExpiredReservationsQuery string = strings.TrimSpace(rawDeleteExpiredReservationsQuery)
+
+//go:embed queries/select-reservations.sql
+var rawSelectReservationsQuery string
+var selectReservationsQuery string = strings.TrimSpace(rawSelectReservationsQuery)
+
+//go:embed queries/insert-reservation.sql
+var rawInsertReservationQuery string
+var insertReservationQuery string = strings.TrimSpace(rawInsertReservationQuery)
+
+// GetReservedIPAddresses returns the IP addresses in the network whose reservations haven't expired
+// by the specified time.
+func (c *Client) GetReservedIPAddresses(
+	ctx context.Context, networkID string, t time.Time,
+) (map[string]bool, error) {
+	sel := newReservationsSelector()
+	if err := c.db.ExecuteSelection(
+		ctx, selectReservationsQuery, newDevicesSelection(networkID), sel.Step,
+	); err != nil {
+		return nil, errors.Wrapf(err, "couldn't get ip address reservations of network %s", networkID)
+	}
+	reserved := make(map[string]bool)
+	for _, reservation := range sel.Reservations() {
+		if reservation.Expiration.After(t) {
+			reserved[reservation.IPAddress] = true
+		}
+	}
+	return reserved, nil
+}
+
+// ReserveIPAddress reserves the IP address chosen for the network member until the expiration.
+// The choose function is given the IP addresses which are already reserved in the network, and it
+// should choose an address which isn't reserved. Reservations are made in a single transaction, so
+// that concurrent reservations can't choose the same address.
+func (c *Client) ReserveIPAddress(
+	ctx context.Context, networkID, memberAddress string, expiration time.Time,
+	choose func(reserved map[string]bool) (ipAddress string, err error),
+) (reservation Reservation, err error) {
+	conn, err := c.db.AcquireWriter(ctx)
+	if err != nil {
+		return Reservation{}, errors.Wrap(err, "couldn't acquire writer to reserve ip address")
+	}
+	defer c.db.ReleaseWriter(conn)
+	defer sqlitex.Save(conn)(&err)
+
+	if err = database.ExecuteDelete(
+		conn, deleteExpiredReservationsQuery, newExpirationsTimeSelection(time.Now()),
+	); err != nil {
+		return Reservation{}, errors.Wrap(err, "couldn't delete expired ip address reservations")
+	}
+	sel := newReservationsSelector()
+	if err = database.ExecuteSelection(
+		conn, selectReservationsQuery, newDevicesSelection(networkID), sel.Step,
+	); err != nil {
+		return Reservation{}, errors.Wrapf(
+			err, "couldn't get ip address reservations of network %s", networkID,
+		)
+	}
+	reserved := make(map[string]bool)
+	for _, existing := range sel.Reservations() {
+		reserved[existing.IPAddress] = true
+	}
+
+	reservation = Reservation{
+		NetworkID:     networkID,
+		MemberAddress: memberAddress,
+		Expiration:    expiration,
+	}
+	if reservation.IPAddress, err = choose(reserved); err != nil {
+		return Reservation{}, err
+	}
+	if reservation.ID, err = database.ExecuteInsertionForID(
+		conn, insertReservationQuery, reservation.newInsertion(),
+	); err != nil {
+		return Reservation{}, errors.Wrapf(
+			err, "couldn't reserve ip address %s in network %s", reservation.IPAddress, networkID,
+		)
+	}
+	return reservation, nil
+}
+
+//go:embed queries/delete-reservation.sql
+var rawDeleteReservationQuery string
+var deleteReservationQuery string = strings.TrimSpace(rawDeleteReservationQuery)
+
+// DeleteReservation releases the reserved IP address, e.g. if it couldn't be assigned.
+func (c *Client) DeleteReservation(ctx context.Context, networkID, ipAddress string) error {
+	if err := c.db.ExecuteDelete(
+		ctx, deleteReservationQuery, newReservationSelection(networkID, ipAddress),
+	); err != nil {
+		return errors.Wrapf(
+			err, "couldn't delete reservation of ip address %s in network %s", ipAddress, networkID,
+		)
+	}
+	return nil
+}
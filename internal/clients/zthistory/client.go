@@ -0,0 +1,20 @@
+// Package zthistory provides a high-level client for the history of the configurations of Zerotier
+// networks
+package zthistory
+
+import (
+	"github.com/sargassum-world/godest"
+	"github.com/sargassum-world/godest/database"
+)
+
+type Client struct {
+	Logger godest.Logger
+	db     *database.DB
+}
+
+func NewClient(db *database.DB, l godest.Logger) *Client {
+	return &Client{
+		Logger: l,
+		db:     db,
+	}
+}
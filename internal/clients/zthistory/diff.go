@@ -0,0 +1,90 @@
+package zthistory
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+type DiffKind string
+
+const (
+	DiffSame    DiffKind = "same"
+	DiffAdded   DiffKind = "added"
+	DiffRemoved DiffKind = "removed"
+)
+
+// DiffLine is a line of the difference between the configurations in two snapshots.
+type DiffLine struct {
+	Kind DiffKind
+	Text string
+}
+
+func formatNetwork(network zerotier.ControllerNetwork) ([]string, error) {
+	formatted, err := json.MarshalIndent(network, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(string(formatted), "\n"), nil
+}
+
+// diffLines computes a line-based diff from a longest common subsequence of the lines.
+func diffLines(from, to []string) []DiffLine {
+	// common[i][j] is the length of the longest common subsequence of from[i:] and to[j:]
+	common := make([][]int, len(from)+1)
+	for i := range common {
+		common[i] = make([]int, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			switch {
+			case from[i] == to[j]:
+				common[i][j] = common[i+1][j+1] + 1
+			case common[i+1][j] >= common[i][j+1]:
+				common[i][j] = common[i+1][j]
+			default:
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	diff := make([]DiffLine, 0, len(from)+len(to))
+	i, j := 0, 0
+	for i < len(from) && j < len(to) {
+		switch {
+		case from[i] == to[j]:
+			diff = append(diff, DiffLine{Kind: DiffSame, Text: from[i]})
+			i++
+			j++
+		case common[i+1][j] >= common[i][j+1]:
+			diff = append(diff, DiffLine{Kind: DiffRemoved, Text: from[i]})
+			i++
+		default:
+			diff = append(diff, DiffLine{Kind: DiffAdded, Text: to[j]})
+			j++
+		}
+	}
+	for ; i < len(from); i++ {
+		diff = append(diff, DiffLine{Kind: DiffRemoved, Text: from[i]})
+	}
+	for ; j < len(to); j++ {
+		diff = append(diff, DiffLine{Kind: DiffAdded, Text: to[j]})
+	}
+	return diff
+}
+
+// DiffSnapshots computes the changes to the network's configuration from one snapshot to another.
+func DiffSnapshots(from, to Snapshot) ([]DiffLine, error) {
+	fromLines, err := formatNetwork(from.Network)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't format config at revision %d", from.Revision)
+	}
+	toLines, err := formatNetwork(to.Network)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't format config at revision %d", to.Revision)
+	}
+	return diffLines(fromLines, toLines), nil
+}
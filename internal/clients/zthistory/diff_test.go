@@ -0,0 +1,41 @@
+package zthistory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	same := func(text string) DiffLine { return DiffLine{Kind: DiffSame, Text: text} }
+	added := func(text string) DiffLine { return DiffLine{Kind: DiffAdded, Text: text} }
+	removed := func(text string) DiffLine { return DiffLine{Kind: DiffRemoved, Text: text} }
+
+	testCases := []struct {
+		name string
+		from []string
+		to   []string
+		want []DiffLine
+	}{
+		{"empty", nil, nil, []DiffLine{}},
+		{"identical", []string{"a", "b"}, []string{"a", "b"}, []DiffLine{same("a"), same("b")}},
+		{"all added", nil, []string{"a", "b"}, []DiffLine{added("a"), added("b")}},
+		{"all removed", []string{"a", "b"}, nil, []DiffLine{removed("a"), removed("b")}},
+		{
+			"changed line",
+			[]string{"{", `"name": "a"`, "}"},
+			[]string{"{", `"name": "b"`, "}"},
+			[]DiffLine{same("{"), removed(`"name": "a"`), added(`"name": "b"`), same("}")},
+		},
+		{
+			"insertion and deletion",
+			[]string{"a", "b", "c", "d"},
+			[]string{"a", "c", "d", "e"},
+			[]DiffLine{same("a"), removed("b"), same("c"), same("d"), added("e")},
+		},
+	}
+	for _, testCase := range testCases {
+		if got := diffLines(testCase.from, testCase.to); !reflect.DeepEqual(got, testCase.want) {
+			t.Errorf("%s: got %+v, want %+v", testCase.name, got, testCase.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package zthistory
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"zombiezen.com/go/sqlite"
+
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+// Snapshot is the full configuration of a network at a revision.
+type Snapshot struct {
+	ID        int64
+	NetworkID string
+	Revision  int64
+	Time      time.Time
+	// Author is the user who made the change which produced the revision, or an empty string if the
+	// change wasn't made through Fluitans or couldn't be attributed to a user
+	Author  string
+	Network zerotier.ControllerNetwork
+}
+
+// NewSnapshot makes a snapshot of the network's configuration at its current revision.
+func NewSnapshot(network zerotier.ControllerNetwork, author string, t time.Time) (Snapshot, error) {
+	if network.Id == nil || network.Revision == nil {
+		return Snapshot{}, errors.New("network has no id or revision")
+	}
+	return Snapshot{
+		NetworkID: *network.Id,
+		Revision:  int64(*network.Revision),
+		Time:      t,
+		Author:    author,
+		Network:   network,
+	}, nil
+}
+
+func (s Snapshot) newInsertion() (map[string]interface{}, error) {
+	networkConfig, err := json.Marshal(s.Network)
+	if err != nil {
+		return nil, errors.Wrapf(
+			err, "couldn't serialize config of network %s at revision %d", s.NetworkID, s.Revision,
+		)
+	}
+	return map[string]interface{}{
+		"$network_id":     s.NetworkID,
+		"$revision":       s.Revision,
+		"$snapshot_time":  s.Time.UnixMilli(),
+		"$author":         s.Author,
+		"$network_config": string(networkConfig),
+	}, nil
+}
+
+func newSnapshotsSelection(networkID string) map[string]interface{} {
+	return map[string]interface{}{
+		"$network_id": networkID,
+	}
+}
+
+func newSnapshotSelection(networkID string, revision int64) map[string]interface{} {
+	return map[string]interface{}{
+		"$network_id": networkID,
+		"$revision":   revision,
+	}
+}
+
+// Snapshots
+
+type snapshotsSelector struct {
+	snapshots []Snapshot
+}
+
+func newSnapshotsSelector() *snapshotsSelector {
+	return &snapshotsSelector{
+		snapshots: make([]Snapshot, 0),
+	}
+}
+
+func (sel *snapshotsSelector) Step(s *sqlite.Stmt) error {
+	snapshot := Snapshot{
+		ID:        s.GetInt64("id"),
+		NetworkID: s.GetText("network_id"),
+		Revision:  s.GetInt64("revision"),
+		Time:      time.UnixMilli(s.GetInt64("snapshot_time")),
+		Author:    s.GetText("author"),
+	}
+	if err := json.Unmarshal([]byte(s.GetText("network_config")), &snapshot.Network); err != nil {
+		return errors.Wrapf(
+			err, "couldn't parse config of network %s at revision %d",
+			snapshot.NetworkID, snapshot.Revision,
+		)
+	}
+	sel.snapshots = append(sel.snapshots, snapshot)
+	return nil
+}
+
+func (sel *snapshotsSelector) Snapshots() []Snapshot {
+	return sel.snapshots
+}
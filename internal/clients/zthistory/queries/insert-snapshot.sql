@@ -0,0 +1,7 @@
+insert into zthistory_snapshot (
+  network_id, revision, snapshot_time, author, network_config
+)
+values ($network_id, $revision, $snapshot_time, $author, $network_config)
+on conflict (network_id, revision) do update
+set author = excluded.author
+where zthistory_snapshot.author = '' and excluded.author != '';
@@ -0,0 +1,9 @@
+select
+  s.id             as id,
+  s.network_id     as network_id,
+  s.revision       as revision,
+  s.snapshot_time  as snapshot_time,
+  s.author         as author,
+  s.network_config as network_config
+from zthistory_snapshot as s
+where s.network_id = $network_id and s.revision = $revision
@@ -0,0 +1,85 @@
+package zthistory
+
+import (
+	"context"
+	_ "embed"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+// All Snapshots
+
+//go:embed queries/select-snapshots.sql
+var rawSelectSnapshotsQuery string
+var selectSnapshotsQuery string = strings.TrimSpace(rawSelectSnapshotsQuery)
+
+// GetSnapshots returns the snapshots of the network, from the latest revision to the earliest.
+func (c *Client) GetSnapshots(ctx context.Context, networkID string) ([]Snapshot, error) {
+	sel := newSnapshotsSelector()
+	if err := c.db.ExecuteSelection(
+		ctx, selectSnapshotsQuery, newSnapshotsSelection(networkID), sel.Step,
+	); err != nil {
+		return nil, errors.Wrapf(err, "couldn't get snapshots of network %s", networkID)
+	}
+	return sel.Snapshots(), nil
+}
+
+// Individual Snapshot
+
+//go:embed queries/select-snapshot.sql
+var rawSelectSnapshotQuery string
+var selectSnapshotQuery string = strings.TrimSpace(rawSelectSnapshotQuery)
+
+func (c *Client) GetSnapshot(
+	ctx context.Context, networkID string, revision int64,
+) (*Snapshot, error) {
+	sel := newSnapshotsSelector()
+	if err := c.db.ExecuteSelection(
+		ctx, selectSnapshotQuery, newSnapshotSelection(networkID, revision), sel.Step,
+	); err != nil {
+		return nil, errors.Wrapf(
+			err, "couldn't get snapshot of network %s at revision %d", networkID, revision,
+		)
+	}
+	snapshots := sel.Snapshots()
+	if len(snapshots) == 0 {
+		return nil, nil
+	}
+	return &snapshots[0], nil
+}
+
+//go:embed queries/insert-snapshot.sql
+var rawInsertSnapshotQuery string
+var insertSnapshotQuery string = strings.TrimSpace(rawInsertSnapshotQuery)
+
+// AddSnapshot records the snapshot, unless a snapshot of the same revision of the network was
+// already recorded. An existing snapshot without an author is attributed to the snapshot's author.
+func (c *Client) AddSnapshot(ctx context.Context, snapshot Snapshot) error {
+	insertion, err := snapshot.newInsertion()
+	if err != nil {
+		return err
+	}
+	if err = c.db.ExecuteInsertion(ctx, insertSnapshotQuery, insertion); err != nil {
+		return errors.Wrapf(
+			err, "couldn't add snapshot of network %s at revision %d",
+			snapshot.NetworkID, snapshot.Revision,
+		)
+	}
+	return nil
+}
+
+// RecordNetwork records a snapshot of the network's current configuration, attributed to the
+// provided author.
+func (c *Client) RecordNetwork(
+	ctx context.Context, network zerotier.ControllerNetwork, author string,
+) error {
+	snapshot, err := NewSnapshot(network, author, time.Now())
+	if err != nil {
+		return err
+	}
+	return c.AddSnapshot(ctx, snapshot)
+}
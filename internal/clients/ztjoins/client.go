@@ -0,0 +1,20 @@
+// Package ztjoins provides a high-level client for automatically authorizing devices which request
+// to join Zerotier networks
+package ztjoins
+
+import (
+	"github.com/sargassum-world/godest"
+	"github.com/sargassum-world/godest/database"
+)
+
+type Client struct {
+	Logger godest.Logger
+	db     *database.DB
+}
+
+func NewClient(db *database.DB, l godest.Logger) *Client {
+	return &Client{
+		Logger: l,
+		db:     db,
+	}
+}
@@ -0,0 +1,45 @@
+package ztjoins
+
+import (
+	"context"
+	_ "embed"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// All Decisions
+
+//go:embed queries/select-decisions.sql
+var rawSelectDecisionsQuery string
+var selectDecisionsQuery string = strings.TrimSpace(rawSelectDecisionsQuery)
+
+// GetDecisions returns the latest decisions for the devices which requested to join the network,
+// from the most recent to the least recent.
+func (c *Client) GetDecisions(ctx context.Context, networkID string) ([]Decision, error) {
+	sel := newDecisionsSelector()
+	if err := c.db.ExecuteSelection(
+		ctx, selectDecisionsQuery, newDecisionsSelection(networkID), sel.Step,
+	); err != nil {
+		return nil, errors.Wrapf(err, "couldn't get join decisions of network %s", networkID)
+	}
+	return sel.Decisions(), nil
+}
+
+// Individual Decision
+
+//go:embed queries/upsert-decision.sql
+var rawUpsertDecisionQuery string
+var upsertDecisionQuery string = strings.TrimSpace(rawUpsertDecisionQuery)
+
+// RecordDecision stores the decision as the member's latest decision, unless the member's latest
+// decision already had the same outcome for the same reason.
+func (c *Client) RecordDecision(ctx context.Context, decision Decision) error {
+	if err := c.db.ExecuteInsertion(ctx, upsertDecisionQuery, decision.newUpsert()); err != nil {
+		return errors.Wrapf(
+			err, "couldn't record join decision for network %s member %s",
+			decision.NetworkID, decision.MemberAddress,
+		)
+	}
+	return nil
+}
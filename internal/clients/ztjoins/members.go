@@ -0,0 +1,51 @@
+package ztjoins
+
+import (
+	"context"
+	_ "embed"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// All Authorized Members
+
+//go:embed queries/select-authorized-members.sql
+var rawSelectAuthorizedMembersQuery string
+var selectAuthorizedMembersQuery string = strings.TrimSpace(rawSelectAuthorizedMembersQuery)
+
+// GetAuthorizedMembers returns the set of addresses of the network's members which have been
+// recorded as authorized at some point, even if they were deauthorized later.
+func (c *Client) GetAuthorizedMembers(
+	ctx context.Context, networkID string,
+) (map[string]bool, error) {
+	sel := newAuthorizedMembersSelector()
+	if err := c.db.ExecuteSelection(
+		ctx, selectAuthorizedMembersQuery, newAuthorizedMembersSelection(networkID), sel.Step,
+	); err != nil {
+		return nil, errors.Wrapf(err, "couldn't get authorized members of network %s", networkID)
+	}
+	return sel.MemberAddresses(), nil
+}
+
+// Individual Authorized Member
+
+//go:embed queries/insert-authorized-member.sql
+var rawInsertAuthorizedMemberQuery string
+var insertAuthorizedMemberQuery string = strings.TrimSpace(rawInsertAuthorizedMemberQuery)
+
+// AddAuthorizedMember records that the network member was authorized, so that join policies won't
+// be applied to it if it's deauthorized later. Members which were already recorded are ignored.
+func (c *Client) AddAuthorizedMember(
+	ctx context.Context, networkID, memberAddress string, t time.Time,
+) error {
+	if err := c.db.ExecuteInsertion(
+		ctx, insertAuthorizedMemberQuery, newAuthorizedMemberInsertion(networkID, memberAddress, t),
+	); err != nil {
+		return errors.Wrapf(
+			err, "couldn't record authorization of network %s member %s", networkID, memberAddress,
+		)
+	}
+	return nil
+}
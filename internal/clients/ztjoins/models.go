@@ -0,0 +1,191 @@
+package ztjoins
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"zombiezen.com/go/sqlite"
+)
+
+// Policy is a set of rules for automatically authorizing devices which request to join a network.
+type Policy struct {
+	ID        int64
+	NetworkID string
+	// RequireApproval disables automatic authorization, so that every device must be authorized
+	// manually
+	RequireApproval bool
+	// MaxAutoApprovals is the maximum number of devices to authorize automatically, or 0 for no limit
+	MaxAutoApprovals int64
+	AutoApprovals    int64
+	// EnrollmentStart and EnrollmentEnd bound the open enrollment window, during which any device is
+	// authorized automatically; they're zero if there's no window
+	EnrollmentStart time.Time
+	EnrollmentEnd   time.Time
+	// Allowlist is the member addresses and identities of devices which are authorized automatically
+	Allowlist []string
+}
+
+func unixMilliOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func timeOrZero(unixMilli int64) time.Time {
+	if unixMilli == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(unixMilli)
+}
+
+func (p Policy) newUpsert() (map[string]interface{}, error) {
+	allowlist := p.Allowlist
+	if allowlist == nil {
+		allowlist = []string{}
+	}
+	rawAllowlist, err := json.Marshal(allowlist)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't serialize allowlist of network %s", p.NetworkID)
+	}
+	return map[string]interface{}{
+		"$network_id":         p.NetworkID,
+		"$require_approval":   p.RequireApproval,
+		"$max_auto_approvals": p.MaxAutoApprovals,
+		"$enrollment_start":   unixMilliOrZero(p.EnrollmentStart),
+		"$enrollment_end":     unixMilliOrZero(p.EnrollmentEnd),
+		"$allowlist":          string(rawAllowlist),
+	}, nil
+}
+
+func newPolicySelection(networkID string) map[string]interface{} {
+	return map[string]interface{}{
+		"$network_id": networkID,
+	}
+}
+
+// Decision is a record of whether a device which requested to join a network was authorized
+// automatically, and why.
+type Decision struct {
+	ID            int64
+	NetworkID     string
+	MemberAddress string
+	Time          time.Time
+	Accepted      bool
+	Reason        string
+}
+
+func (d Decision) newUpsert() map[string]interface{} {
+	return map[string]interface{}{
+		"$network_id":     d.NetworkID,
+		"$member_address": d.MemberAddress,
+		"$decision_time":  d.Time.UnixMilli(),
+		"$accepted":       d.Accepted,
+		"$reason":         d.Reason,
+	}
+}
+
+func newDecisionsSelection(networkID string) map[string]interface{} {
+	return map[string]interface{}{
+		"$network_id": networkID,
+	}
+}
+
+// Policies
+
+type policiesSelector struct {
+	policies []Policy
+}
+
+func newPoliciesSelector() *policiesSelector {
+	return &policiesSelector{
+		policies: make([]Policy, 0),
+	}
+}
+
+func (sel *policiesSelector) Step(s *sqlite.Stmt) error {
+	policy := Policy{
+		ID:               s.GetInt64("id"),
+		NetworkID:        s.GetText("network_id"),
+		RequireApproval:  s.GetBool("require_approval"),
+		MaxAutoApprovals: s.GetInt64("max_auto_approvals"),
+		AutoApprovals:    s.GetInt64("auto_approvals"),
+		EnrollmentStart:  timeOrZero(s.GetInt64("enrollment_start")),
+		EnrollmentEnd:    timeOrZero(s.GetInt64("enrollment_end")),
+	}
+	if err := json.Unmarshal([]byte(s.GetText("allowlist")), &policy.Allowlist); err != nil {
+		return errors.Wrapf(err, "couldn't parse allowlist of network %s", policy.NetworkID)
+	}
+	sel.policies = append(sel.policies, policy)
+	return nil
+}
+
+func (sel *policiesSelector) Policies() []Policy {
+	return sel.policies
+}
+
+// Decisions
+
+type decisionsSelector struct {
+	decisions []Decision
+}
+
+func newDecisionsSelector() *decisionsSelector {
+	return &decisionsSelector{
+		decisions: make([]Decision, 0),
+	}
+}
+
+func (sel *decisionsSelector) Step(s *sqlite.Stmt) error {
+	sel.decisions = append(sel.decisions, Decision{
+		ID:            s.GetInt64("id"),
+		NetworkID:     s.GetText("network_id"),
+		MemberAddress: s.GetText("member_address"),
+		Time:          time.UnixMilli(s.GetInt64("decision_time")),
+		Accepted:      s.GetBool("accepted"),
+		Reason:        s.GetText("reason"),
+	})
+	return nil
+}
+
+func (sel *decisionsSelector) Decisions() []Decision {
+	return sel.decisions
+}
+
+// Authorized Members
+
+func newAuthorizedMemberInsertion(
+	networkID, memberAddress string, t time.Time,
+) map[string]interface{} {
+	return map[string]interface{}{
+		"$network_id":               networkID,
+		"$member_address":           memberAddress,
+		"$first_authorization_time": t.UnixMilli(),
+	}
+}
+
+func newAuthorizedMembersSelection(networkID string) map[string]interface{} {
+	return map[string]interface{}{
+		"$network_id": networkID,
+	}
+}
+
+type authorizedMembersSelector struct {
+	memberAddresses map[string]bool
+}
+
+func newAuthorizedMembersSelector() *authorizedMembersSelector {
+	return &authorizedMembersSelector{
+		memberAddresses: make(map[string]bool),
+	}
+}
+
+func (sel *authorizedMembersSelector) Step(s *sqlite.Stmt) error {
+	sel.memberAddresses[s.GetText("member_address")] = true
+	return nil
+}
+
+func (sel *authorizedMembersSelector) MemberAddresses() map[string]bool {
+	return sel.memberAddresses
+}
@@ -0,0 +1,46 @@
+package ztjoins
+
+import (
+	"context"
+	_ "embed"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sargassum-world/godest/database"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+//go:embed queries/delete-policy.sql
+var rawDeletePolicyQuery string
+var deletePolicyQuery string = strings.TrimSpace(rawDeletePolicyQuery)
+
+//go:embed queries/delete-decisions.sql
+var rawDeleteDecisionsQuery string
+var deleteDecisionsQuery string = strings.TrimSpace(rawDeleteDecisionsQuery)
+
+//go:embed queries/delete-authorized-members.sql
+var rawDeleteAuthorizedMembersQuery string
+var deleteAuthorizedMembersQuery string = strings.TrimSpace(rawDeleteAuthorizedMembersQuery)
+
+// DeleteNetwork deletes the join policy, join decisions, and records of authorized members of the
+// network, e.g. after the network itself was deleted.
+func (c *Client) DeleteNetwork(ctx context.Context, networkID string) (err error) {
+	conn, err := c.db.AcquireWriter(ctx)
+	if err != nil {
+		return errors.Wrap(err, "couldn't acquire writer to delete network join records")
+	}
+	defer c.db.ReleaseWriter(conn)
+	defer sqlitex.Save(conn)(&err)
+
+	selection := newPolicySelection(networkID)
+	if err = database.ExecuteDelete(conn, deletePolicyQuery, selection); err != nil {
+		return errors.Wrapf(err, "couldn't delete join policy of network %s", networkID)
+	}
+	if err = database.ExecuteDelete(conn, deleteDecisionsQuery, selection); err != nil {
+		return errors.Wrapf(err, "couldn't delete join decisions of network %s", networkID)
+	}
+	if err = database.ExecuteDelete(conn, deleteAuthorizedMembersQuery, selection); err != nil {
+		return errors.Wrapf(err, "couldn't delete authorized members of network %s", networkID)
+	}
+	return nil
+}
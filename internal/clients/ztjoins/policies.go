@@ -0,0 +1,153 @@
+package ztjoins
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+// All Policies
+
+//go:embed queries/select-policies.sql
+var rawSelectPoliciesQuery string
+var selectPoliciesQuery string = strings.TrimSpace(rawSelectPoliciesQuery)
+
+// GetPolicies returns the join policies of all networks which have one.
+func (c *Client) GetPolicies(ctx context.Context) ([]Policy, error) {
+	sel := newPoliciesSelector()
+	if err := c.db.ExecuteSelection(ctx, selectPoliciesQuery, nil, sel.Step); err != nil {
+		return nil, errors.Wrap(err, "couldn't get join policies")
+	}
+	return sel.Policies(), nil
+}
+
+// Individual Policy
+
+//go:embed queries/select-policy.sql
+var rawSelectPolicyQuery string
+var selectPolicyQuery string = strings.TrimSpace(rawSelectPolicyQuery)
+
+// GetPolicy returns the join policy of the network, or nil if the network has no join policy.
+func (c *Client) GetPolicy(ctx context.Context, networkID string) (*Policy, error) {
+	sel := newPoliciesSelector()
+	if err := c.db.ExecuteSelection(
+		ctx, selectPolicyQuery, newPolicySelection(networkID), sel.Step,
+	); err != nil {
+		return nil, errors.Wrapf(err, "couldn't get join policy of network %s", networkID)
+	}
+	policies := sel.Policies()
+	if len(policies) == 0 {
+		return nil, nil
+	}
+	return &policies[0], nil
+}
+
+//go:embed queries/upsert-policy.sql
+var rawUpsertPolicyQuery string
+var upsertPolicyQuery string = strings.TrimSpace(rawUpsertPolicyQuery)
+
+// SetPolicy stores the join policy of the network, replacing any previous policy but keeping the
+// count of devices which were already authorized automatically.
+func (c *Client) SetPolicy(ctx context.Context, policy Policy) error {
+	params, err := policy.newUpsert()
+	if err != nil {
+		return err
+	}
+	if err = c.db.ExecuteInsertion(ctx, upsertPolicyQuery, params); err != nil {
+		return errors.Wrapf(err, "couldn't set join policy of network %s", policy.NetworkID)
+	}
+	return nil
+}
+
+//go:embed queries/update-policy-auto-approvals-increment.sql
+var rawIncrementAutoApprovalsQuery string
+var incrementAutoApprovalsQuery string = strings.TrimSpace(rawIncrementAutoApprovalsQuery)
+
+func (c *Client) IncrementAutoApprovals(ctx context.Context, networkID string) error {
+	if err := c.db.ExecuteUpdate(
+		ctx, incrementAutoApprovalsQuery, newPolicySelection(networkID),
+	); err != nil {
+		return errors.Wrapf(err, "couldn't count automatic authorization in network %s", networkID)
+	}
+	return nil
+}
+
+//go:embed queries/update-policy-auto-approvals-reset.sql
+var rawResetAutoApprovalsQuery string
+var resetAutoApprovalsQuery string = strings.TrimSpace(rawResetAutoApprovalsQuery)
+
+func (c *Client) ResetAutoApprovals(ctx context.Context, networkID string) error {
+	if err := c.db.ExecuteUpdate(
+		ctx, resetAutoApprovalsQuery, newPolicySelection(networkID),
+	); err != nil {
+		return errors.Wrapf(
+			err, "couldn't reset count of automatic authorizations in network %s", networkID,
+		)
+	}
+	return nil
+}
+
+// Policy Evaluation
+
+// EnrollmentOpen checks whether the open enrollment window includes the time.
+func (p Policy) EnrollmentOpen(t time.Time) bool {
+	if p.EnrollmentEnd.IsZero() {
+		return false
+	}
+	return !t.Before(p.EnrollmentStart) && t.Before(p.EnrollmentEnd)
+}
+
+// Allows checks whether the member's address or identity is on the allowlist, returning the
+// matching allowlist entry.
+func (p Policy) Allows(member zerotier.ControllerNetworkMember) (entry string, allowed bool) {
+	for _, entry := range p.Allowlist {
+		if member.Address != nil && entry == *member.Address {
+			return entry, true
+		}
+		if member.Identity != nil && entry == *member.Identity {
+			return entry, true
+		}
+	}
+	return "", false
+}
+
+// Evaluate decides whether to automatically authorize the member, which requested to join the
+// network at the time.
+func (p Policy) Evaluate(member zerotier.ControllerNetworkMember, t time.Time) Decision {
+	decision := Decision{NetworkID: p.NetworkID, Time: t}
+	if member.Address != nil {
+		decision.MemberAddress = *member.Address
+	}
+	if p.RequireApproval {
+		decision.Reason = "the network requires manual approval of all devices"
+		return decision
+	}
+	if p.MaxAutoApprovals > 0 && p.AutoApprovals >= p.MaxAutoApprovals {
+		decision.Reason = fmt.Sprintf(
+			"the limit of %d automatically authorized devices was reached", p.MaxAutoApprovals,
+		)
+		return decision
+	}
+	if entry, allowed := p.Allows(member); allowed {
+		decision.Accepted = true
+		if member.Address != nil && entry == *member.Address {
+			decision.Reason = "the device's address is on the allowlist"
+		} else {
+			decision.Reason = "the device's identity is on the allowlist"
+		}
+		return decision
+	}
+	if p.EnrollmentOpen(t) {
+		decision.Accepted = true
+		decision.Reason = "the device requested to join during open enrollment"
+		return decision
+	}
+	decision.Reason = "the device isn't on the allowlist, and enrollment isn't open"
+	return decision
+}
@@ -0,0 +1,2 @@
+delete from ztjoins_authorized_member
+where ztjoins_authorized_member.network_id = $network_id
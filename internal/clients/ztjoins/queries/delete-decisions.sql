@@ -0,0 +1,2 @@
+delete from ztjoins_decision
+where ztjoins_decision.network_id = $network_id
@@ -0,0 +1,2 @@
+delete from ztjoins_policy
+where ztjoins_policy.network_id = $network_id
@@ -0,0 +1,3 @@
+insert into ztjoins_authorized_member (network_id, member_address, first_authorization_time)
+values ($network_id, $member_address, $first_authorization_time)
+on conflict (network_id, member_address) do nothing
@@ -0,0 +1,8 @@
+select
+  m.id                       as id,
+  m.network_id               as network_id,
+  m.member_address           as member_address,
+  m.first_authorization_time as first_authorization_time
+from ztjoins_authorized_member as m
+where m.network_id = $network_id
+order by m.member_address asc
@@ -0,0 +1,10 @@
+select
+  d.id             as id,
+  d.network_id     as network_id,
+  d.member_address as member_address,
+  d.decision_time  as decision_time,
+  d.accepted       as accepted,
+  d.reason         as reason
+from ztjoins_decision as d
+where d.network_id = $network_id
+order by d.decision_time desc, d.member_address asc
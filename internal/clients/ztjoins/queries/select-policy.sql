@@ -0,0 +1,11 @@
+select
+  p.id                 as id,
+  p.network_id         as network_id,
+  p.require_approval   as require_approval,
+  p.max_auto_approvals as max_auto_approvals,
+  p.auto_approvals     as auto_approvals,
+  p.enrollment_start   as enrollment_start,
+  p.enrollment_end     as enrollment_end,
+  p.allowlist          as allowlist
+from ztjoins_policy as p
+where p.network_id = $network_id
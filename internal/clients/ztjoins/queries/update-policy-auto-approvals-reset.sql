@@ -0,0 +1,3 @@
+update ztjoins_policy
+set auto_approvals = 0
+where ztjoins_policy.network_id = $network_id
@@ -0,0 +1,10 @@
+insert into ztjoins_decision (network_id, member_address, decision_time, accepted, reason)
+values ($network_id, $member_address, $decision_time, $accepted, $reason)
+on conflict (network_id, member_address) do update
+set
+  decision_time = excluded.decision_time,
+  accepted = excluded.accepted,
+  reason = excluded.reason
+where
+  ztjoins_decision.accepted != excluded.accepted
+  or ztjoins_decision.reason != excluded.reason;
@@ -0,0 +1,15 @@
+insert into ztjoins_policy (
+  network_id, require_approval, max_auto_approvals, auto_approvals, enrollment_start,
+  enrollment_end, allowlist
+)
+values (
+  $network_id, $require_approval, $max_auto_approvals, 0, $enrollment_start,
+  $enrollment_end, $allowlist
+)
+on conflict (network_id) do update
+set
+  require_approval = excluded.require_approval,
+  max_auto_approvals = excluded.max_auto_approvals,
+  enrollment_start = excluded.enrollment_start,
+  enrollment_end = excluded.enrollment_end,
+  allowlist = excluded.allowlist;
@@ -0,0 +1,20 @@
+// Package zttags provides a high-level client for management of the names of tags, values of
+// tags, and capabilities in Zerotier networks
+package zttags
+
+import (
+	"github.com/sargassum-world/godest"
+	"github.com/sargassum-world/godest/database"
+)
+
+type Client struct {
+	Logger godest.Logger
+	db     *database.DB
+}
+
+func NewClient(db *database.DB, l godest.Logger) *Client {
+	return &Client{
+		Logger: l,
+		db:     db,
+	}
+}
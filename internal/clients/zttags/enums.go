@@ -0,0 +1,68 @@
+package zttags
+
+import (
+	"context"
+	_ "embed"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// All Enums
+
+//go:embed queries/select-enums.sql
+var rawSelectEnumsQuery string
+var selectEnumsQuery string = strings.TrimSpace(rawSelectEnumsQuery)
+
+// GetEnums returns the enums of the tags of the network, keyed by tag id and ordered by value.
+func (c *Client) GetEnums(ctx context.Context, networkID string) (map[uint64][]Enum, error) {
+	sel := newEnumsSelector()
+	if err := c.db.ExecuteSelection(
+		ctx, selectEnumsQuery, newEnumsSelection(networkID), sel.Step,
+	); err != nil {
+		return nil, errors.Wrapf(err, "couldn't get tag enums of network %s", networkID)
+	}
+	return sel.Enums(), nil
+}
+
+//go:embed queries/delete-tag-enums.sql
+var rawDeleteTagEnumsQuery string
+var deleteTagEnumsQuery string = strings.TrimSpace(rawDeleteTagEnumsQuery)
+
+func (c *Client) DeleteTagEnums(ctx context.Context, networkID string, tagID uint64) error {
+	if err := c.db.ExecuteDelete(
+		ctx, deleteTagEnumsQuery, newTagEnumsDelete(networkID, tagID),
+	); err != nil {
+		return errors.Wrapf(err, "couldn't delete enums of network %s tag %d", networkID, tagID)
+	}
+	return nil
+}
+
+// Individual Enum
+
+//go:embed queries/insert-enum.sql
+var rawInsertEnumQuery string
+var insertEnumQuery string = strings.TrimSpace(rawInsertEnumQuery)
+
+func (c *Client) AddEnum(ctx context.Context, enum Enum) (id int64, err error) {
+	id, err = c.db.ExecuteInsertionForID(ctx, insertEnumQuery, enum.newInsertion())
+	if err != nil {
+		return 0, errors.Wrapf(
+			err, "couldn't add enum %s for network %s tag %d", enum.Name, enum.NetworkID, enum.TagID,
+		)
+	}
+	return id, nil
+}
+
+//go:embed queries/delete-enum.sql
+var rawDeleteEnumQuery string
+var deleteEnumQuery string = strings.TrimSpace(rawDeleteEnumQuery)
+
+func (c *Client) DeleteEnum(ctx context.Context, enum Enum) error {
+	if err := c.db.ExecuteDelete(ctx, deleteEnumQuery, enum.newDelete()); err != nil {
+		return errors.Wrapf(
+			err, "couldn't delete enum %d of network %s tag %d", enum.Value, enum.NetworkID, enum.TagID,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package zttags
+
+import (
+	"zombiezen.com/go/sqlite"
+)
+
+// Enum is a name for a value of a tag in a network.
+type Enum struct {
+	ID        int64
+	NetworkID string
+	TagID     uint64
+	Value     uint64
+	Name      string // Must be unique among the enums of the tag
+}
+
+func (e Enum) newInsertion() map[string]interface{} {
+	return map[string]interface{}{
+		"$network_id": e.NetworkID,
+		"$tag_id":     int64(e.TagID),
+		"$value":      int64(e.Value),
+		"$name":       e.Name,
+	}
+}
+
+func (e Enum) newDelete() map[string]interface{} {
+	return map[string]interface{}{
+		"$network_id": e.NetworkID,
+		"$tag_id":     int64(e.TagID),
+		"$value":      int64(e.Value),
+	}
+}
+
+func newTagEnumsDelete(networkID string, tagID uint64) map[string]interface{} {
+	return map[string]interface{}{
+		"$network_id": networkID,
+		"$tag_id":     int64(tagID),
+	}
+}
+
+func newEnumsSelection(networkID string) map[string]interface{} {
+	return map[string]interface{}{
+		"$network_id": networkID,
+	}
+}
+
+// Enums
+
+type enumsSelector struct {
+	enums []Enum
+}
+
+func newEnumsSelector() *enumsSelector {
+	return &enumsSelector{
+		enums: make([]Enum, 0),
+	}
+}
+
+func (sel *enumsSelector) Step(s *sqlite.Stmt) error {
+	sel.enums = append(sel.enums, Enum{
+		ID:        s.GetInt64("id"),
+		NetworkID: s.GetText("network_id"),
+		TagID:     uint64(s.GetInt64("tag_id")),
+		Value:     uint64(s.GetInt64("value")),
+		Name:      s.GetText("name"),
+	})
+	return nil
+}
+
+// Enums returns the selected enums, grouped by tag id.
+func (sel *enumsSelector) Enums() map[uint64][]Enum {
+	enums := make(map[uint64][]Enum)
+	for _, enum := range sel.enums {
+		enums[enum.TagID] = append(enums[enum.TagID], enum)
+	}
+	return enums
+}
+
+// Names
+
+func newTagInsertion(networkID string, tagID uint64, name string) map[string]interface{} {
+	return map[string]interface{}{
+		"$network_id": networkID,
+		"$tag_id":     int64(tagID),
+		"$name":       name,
+	}
+}
+
+func newCapabilityInsertion(
+	networkID string, capabilityID uint64, name string,
+) map[string]interface{} {
+	return map[string]interface{}{
+		"$network_id":    networkID,
+		"$capability_id": int64(capabilityID),
+		"$name":          name,
+	}
+}
+
+func newTagDelete(networkID string, tagID uint64) map[string]interface{} {
+	return map[string]interface{}{
+		"$network_id": networkID,
+		"$tag_id":     int64(tagID),
+	}
+}
+
+func newCapabilityDelete(networkID string, capabilityID uint64) map[string]interface{} {
+	return map[string]interface{}{
+		"$network_id":    networkID,
+		"$capability_id": int64(capabilityID),
+	}
+}
+
+func newNetworkSelection(networkID string) map[string]interface{} {
+	return map[string]interface{}{
+		"$network_id": networkID,
+	}
+}
+
+// namesSelector selects names keyed by the ids in the idColumn.
+type namesSelector struct {
+	idColumn string
+	names    map[uint64]string
+}
+
+func newNamesSelector(idColumn string) *namesSelector {
+	return &namesSelector{
+		idColumn: idColumn,
+		names:    make(map[uint64]string),
+	}
+}
+
+func (sel *namesSelector) Step(s *sqlite.Stmt) error {
+	sel.names[uint64(s.GetInt64(sel.idColumn))] = s.GetText("name")
+	return nil
+}
+
+func (sel *namesSelector) Names() map[uint64]string {
+	return sel.names
+}
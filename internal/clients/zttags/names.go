@@ -0,0 +1,131 @@
+package zttags
+
+import (
+	"context"
+	_ "embed"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sargassum-world/godest/database"
+	"zombiezen.com/go/sqlite/sqlitex"
+
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+//go:embed queries/select-tags.sql
+var rawSelectTagsQuery string
+var selectTagsQuery string = strings.TrimSpace(rawSelectTagsQuery)
+
+//go:embed queries/select-capabilities.sql
+var rawSelectCapabilitiesQuery string
+var selectCapabilitiesQuery string = strings.TrimSpace(rawSelectCapabilitiesQuery)
+
+// GetRuleNames returns the names of the network's capabilities, tags, and tag values, for writing
+// the network's rules in the rules language.
+func (c *Client) GetRuleNames(ctx context.Context, networkID string) (zerotier.RuleNames, error) {
+	names := zerotier.NewRuleNames()
+	tagsSel := newNamesSelector("tag_id")
+	if err := c.db.ExecuteSelection(
+		ctx, selectTagsQuery, newNetworkSelection(networkID), tagsSel.Step,
+	); err != nil {
+		return zerotier.RuleNames{}, errors.Wrapf(
+			err, "couldn't get tag names of network %s", networkID,
+		)
+	}
+	names.Tags = tagsSel.Names()
+	capabilitiesSel := newNamesSelector("capability_id")
+	if err := c.db.ExecuteSelection(
+		ctx, selectCapabilitiesQuery, newNetworkSelection(networkID), capabilitiesSel.Step,
+	); err != nil {
+		return zerotier.RuleNames{}, errors.Wrapf(
+			err, "couldn't get capability names of network %s", networkID,
+		)
+	}
+	names.Capabilities = capabilitiesSel.Names()
+
+	enums, err := c.GetEnums(ctx, networkID)
+	if err != nil {
+		return zerotier.RuleNames{}, err
+	}
+	for tagID, tagEnums := range enums {
+		names.TagValues[tagID] = make(map[uint64]string, len(tagEnums))
+		for _, enum := range tagEnums {
+			names.TagValues[tagID][enum.Value] = enum.Name
+		}
+	}
+	return names, nil
+}
+
+//go:embed queries/delete-tag.sql
+var rawDeleteTagQuery string
+var deleteTagQuery string = strings.TrimSpace(rawDeleteTagQuery)
+
+//go:embed queries/delete-capability.sql
+var rawDeleteCapabilityQuery string
+var deleteCapabilityQuery string = strings.TrimSpace(rawDeleteCapabilityQuery)
+
+//go:embed queries/insert-tag.sql
+var rawInsertTagQuery string
+var insertTagQuery string = strings.TrimSpace(rawInsertTagQuery)
+
+//go:embed queries/insert-capability.sql
+var rawInsertCapabilityQuery string
+var insertCapabilityQuery string = strings.TrimSpace(rawInsertCapabilityQuery)
+
+// SetRuleNames replaces the names of the network's capabilities, tags, and tag values with the
+// names from source code in the rules language. Only the names of capabilities and tags defined in
+// the source are replaced, so names of tag values set for other tags are kept.
+func (c *Client) SetRuleNames(
+	ctx context.Context, networkID string, names zerotier.RuleNames,
+) (err error) {
+	conn, err := c.db.AcquireWriter(ctx)
+	if err != nil {
+		return errors.Wrap(err, "couldn't acquire writer to set rule names")
+	}
+	defer c.db.ReleaseWriter(conn)
+	defer sqlitex.Save(conn)(&err)
+
+	// Every tag defined in the rules language has a name, so the tag names list all defined tags
+	for tagID, name := range names.Tags {
+		if err = database.ExecuteDelete(
+			conn, deleteTagEnumsQuery, newTagEnumsDelete(networkID, tagID),
+		); err != nil {
+			return errors.Wrapf(err, "couldn't delete enums of network %s tag %d", networkID, tagID)
+		}
+		if err = database.ExecuteDelete(
+			conn, deleteTagQuery, newTagDelete(networkID, tagID),
+		); err != nil {
+			return errors.Wrapf(err, "couldn't delete name of network %s tag %d", networkID, tagID)
+		}
+		if err = database.ExecuteInsertion(
+			conn, insertTagQuery, newTagInsertion(networkID, tagID, name),
+		); err != nil {
+			return errors.Wrapf(err, "couldn't add name %s for network %s tag %d", name, networkID, tagID)
+		}
+		for value, valueName := range names.TagValues[tagID] {
+			enum := Enum{NetworkID: networkID, TagID: tagID, Value: value, Name: valueName}
+			if err = database.ExecuteInsertion(conn, insertEnumQuery, enum.newInsertion()); err != nil {
+				return errors.Wrapf(
+					err, "couldn't add enum %s for network %s tag %d", valueName, networkID, tagID,
+				)
+			}
+		}
+	}
+	for capabilityID, name := range names.Capabilities {
+		if err = database.ExecuteDelete(
+			conn, deleteCapabilityQuery, newCapabilityDelete(networkID, capabilityID),
+		); err != nil {
+			return errors.Wrapf(
+				err, "couldn't delete name of network %s capability %d", networkID, capabilityID,
+			)
+		}
+		if err = database.ExecuteInsertion(
+			conn, insertCapabilityQuery, newCapabilityInsertion(networkID, capabilityID, name),
+		); err != nil {
+			return errors.Wrapf(
+				err, "couldn't add name %s for network %s capability %d", name, networkID, capabilityID,
+			)
+		}
+	}
+	return nil
+}
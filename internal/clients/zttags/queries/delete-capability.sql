@@ -0,0 +1,4 @@
+delete from zttags_capability
+where
+  zttags_capability.network_id = $network_id
+  and zttags_capability.capability_id = $capability_id
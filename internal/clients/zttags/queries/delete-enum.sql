@@ -0,0 +1,5 @@
+delete from zttags_enum
+where
+  zttags_enum.network_id = $network_id
+  and zttags_enum.tag_id = $tag_id
+  and zttags_enum.value = $value
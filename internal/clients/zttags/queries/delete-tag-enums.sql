@@ -0,0 +1,4 @@
+delete from zttags_enum
+where
+  zttags_enum.network_id = $network_id
+  and zttags_enum.tag_id = $tag_id
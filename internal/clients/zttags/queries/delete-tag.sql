@@ -0,0 +1,4 @@
+delete from zttags_tag
+where
+  zttags_tag.network_id = $network_id
+  and zttags_tag.tag_id = $tag_id
@@ -0,0 +1,2 @@
+insert into zttags_capability (network_id, capability_id, name)
+values ($network_id, $capability_id, $name);
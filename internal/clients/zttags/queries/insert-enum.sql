@@ -0,0 +1,2 @@
+insert into zttags_enum (network_id, tag_id, value, name)
+values ($network_id, $tag_id, $value, $name);
@@ -0,0 +1,2 @@
+insert into zttags_tag (network_id, tag_id, name)
+values ($network_id, $tag_id, $name);
@@ -0,0 +1,8 @@
+select
+  c.id            as id,
+  c.network_id    as network_id,
+  c.capability_id as capability_id,
+  c.name          as name
+from zttags_capability as c
+where c.network_id = $network_id
+order by c.capability_id asc
@@ -0,0 +1,9 @@
+select
+  e.id         as id,
+  e.network_id as network_id,
+  e.tag_id     as tag_id,
+  e.value      as value,
+  e.name       as name
+from zttags_enum as e
+where e.network_id = $network_id
+order by e.tag_id asc, e.value asc
@@ -0,0 +1,8 @@
+select
+  t.id         as id,
+  t.network_id as network_id,
+  t.tag_id     as tag_id,
+  t.name       as name
+from zttags_tag as t
+where t.network_id = $network_id
+order by t.tag_id asc
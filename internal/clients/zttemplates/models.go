@@ -0,0 +1,140 @@
+package zttemplates
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"zombiezen.com/go/sqlite"
+
+	"github.com/sargassum-world/fluitans/pkg/zerotier"
+)
+
+// DefaultTemplateName is the name of the template seeded by the database migrations, which is used
+// for new networks when no template is chosen.
+const DefaultTemplateName = "default"
+
+type Template struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"` // Must be unique for display purposes!
+	Description string `json:"description"`
+	// Network holds the settings given to each network created from the template
+	Network zerotier.ControllerNetwork `json:"network"`
+	// NameByDNS requires each network created from the template to be named by DNS
+	NameByDNS bool `json:"nameByDNS"`
+}
+
+// NewTemplateNetwork keeps only the settings of the network which can be given to a new network
+// by a template.
+func NewTemplateNetwork(network zerotier.ControllerNetwork) zerotier.ControllerNetwork {
+	return zerotier.ControllerNetwork{
+		Capabilities:      network.Capabilities,
+		EnableBroadcast:   network.EnableBroadcast,
+		IpAssignmentPools: network.IpAssignmentPools,
+		Mtu:               network.Mtu,
+		MulticastLimit:    network.MulticastLimit,
+		Private:           network.Private,
+		Routes:            network.Routes,
+		Rules:             network.Rules,
+		Tags:              network.Tags,
+		V4AssignMode:      network.V4AssignMode,
+		V6AssignMode:      network.V6AssignMode,
+	}
+}
+
+// NewNetworkRequest makes the request body to generate a network from the template, with the
+// provided name (which may be empty).
+func (t Template) NewNetworkRequest(
+	name string,
+) zerotier.GenerateControllerNetworkJSONRequestBody {
+	body := NewTemplateNetwork(t.Network)
+	if name != "" {
+		body.Name = &name
+	}
+	return body
+}
+
+func (t Template) newInsertion(networkConfig string) map[string]interface{} {
+	return map[string]interface{}{
+		"$name":           t.Name,
+		"$description":    t.Description,
+		"$network_config": networkConfig,
+		"$name_by_dns":    t.NameByDNS,
+	}
+}
+
+func (t Template) newUpdate(networkConfig string) map[string]interface{} {
+	return map[string]interface{}{
+		"$id":             t.ID,
+		"$name":           t.Name,
+		"$description":    t.Description,
+		"$network_config": networkConfig,
+		"$name_by_dns":    t.NameByDNS,
+	}
+}
+
+func (t Template) newDelete() map[string]interface{} {
+	return map[string]interface{}{
+		"$id": t.ID,
+	}
+}
+
+func newTemplateSelection(id int64) map[string]interface{} {
+	return map[string]interface{}{
+		"$id": id,
+	}
+}
+
+func newTemplateNameSelection(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"$name": name,
+	}
+}
+
+func serializeNetworkConfig(t Template) (string, error) {
+	networkConfig, err := json.Marshal(NewTemplateNetwork(t.Network))
+	if err != nil {
+		return "", errors.Wrapf(err, "couldn't serialize network config for template %s", t.Name)
+	}
+	return string(networkConfig), nil
+}
+
+// Templates
+
+type templatesSelector struct {
+	ids       []int64
+	templates map[int64]Template
+}
+
+func newTemplatesSelector() *templatesSelector {
+	return &templatesSelector{
+		ids:       make([]int64, 0),
+		templates: make(map[int64]Template),
+	}
+}
+
+func (sel *templatesSelector) Step(s *sqlite.Stmt) error {
+	id := s.GetInt64("id")
+	if _, ok := sel.templates[id]; !ok {
+		var network zerotier.ControllerNetwork
+		if err := json.Unmarshal([]byte(s.GetText("network_config")), &network); err != nil {
+			return errors.Wrapf(err, "couldn't parse network config for template with id %d", id)
+		}
+		sel.templates[id] = Template{
+			ID:          id,
+			Name:        s.GetText("name"),
+			Description: s.GetText("description"),
+			Network:     network,
+			NameByDNS:   s.GetBool("name_by_dns"),
+		}
+		sel.ids = append(sel.ids, id)
+	}
+	return nil
+}
+
+func (sel *templatesSelector) Templates() []Template {
+	templates := make([]Template, len(sel.ids))
+	for i, id := range sel.ids {
+		templates[i] = sel.templates[id]
+	}
+	return templates
+}
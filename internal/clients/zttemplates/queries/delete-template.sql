@@ -0,0 +1,2 @@
+delete from zttemplates_template
+where zttemplates_template.id = $id
@@ -0,0 +1,2 @@
+insert into zttemplates_template (name, description, network_config, name_by_dns)
+values ($name, $description, $network_config, $name_by_dns);
@@ -0,0 +1,8 @@
+select
+  t.id             as id,
+  t.name           as name,
+  t.description    as description,
+  t.network_config as network_config,
+  t.name_by_dns    as name_by_dns
+from zttemplates_template as t
+order by t.id asc
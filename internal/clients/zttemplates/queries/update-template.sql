@@ -0,0 +1,7 @@
+update zttemplates_template
+set
+  name = $name,
+  description = $description,
+  network_config = $network_config,
+  name_by_dns = $name_by_dns
+where zttemplates_template.id = $id
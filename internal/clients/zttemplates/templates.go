@@ -0,0 +1,109 @@
+package zttemplates
+
+import (
+	"context"
+	_ "embed"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// All Templates
+
+//go:embed queries/select-templates.sql
+var rawSelectTemplatesQuery string
+var selectTemplatesQuery string = strings.TrimSpace(rawSelectTemplatesQuery)
+
+func (c *Client) GetTemplates(ctx context.Context) ([]Template, error) {
+	sel := newTemplatesSelector()
+	if err := c.db.ExecuteSelection(ctx, selectTemplatesQuery, nil, sel.Step); err != nil {
+		return nil, errors.Wrap(err, "couldn't get network templates")
+	}
+	return sel.Templates(), nil
+}
+
+// Individual Template
+
+//go:embed queries/select-template.sql
+var rawSelectTemplateQuery string
+var selectTemplateQuery string = strings.TrimSpace(rawSelectTemplateQuery)
+
+func (c *Client) GetTemplate(ctx context.Context, id int64) (*Template, error) {
+	sel := newTemplatesSelector()
+	if err := c.db.ExecuteSelection(
+		ctx, selectTemplateQuery, newTemplateSelection(id), sel.Step,
+	); err != nil {
+		return nil, errors.Wrapf(err, "couldn't get network template with id %d", id)
+	}
+	templates := sel.Templates()
+	if len(templates) == 0 {
+		return nil, nil
+	}
+	return &templates[0], nil
+}
+
+//go:embed queries/select-template-by-name.sql
+var rawSelectTemplateByNameQuery string
+var selectTemplateByNameQuery string = strings.TrimSpace(rawSelectTemplateByNameQuery)
+
+func (c *Client) FindTemplate(ctx context.Context, name string) (*Template, error) {
+	sel := newTemplatesSelector()
+	if err := c.db.ExecuteSelection(
+		ctx, selectTemplateByNameQuery, newTemplateNameSelection(name), sel.Step,
+	); err != nil {
+		return nil, errors.Wrapf(err, "couldn't get network template with name %s", name)
+	}
+	templates := sel.Templates()
+	if len(templates) == 0 {
+		return nil, nil
+	}
+	return &templates[0], nil
+}
+
+//go:embed queries/insert-template.sql
+var rawInsertTemplateQuery string
+var insertTemplateQuery string = strings.TrimSpace(rawInsertTemplateQuery)
+
+func (c *Client) AddTemplate(ctx context.Context, template Template) (id int64, err error) {
+	networkConfig, err := serializeNetworkConfig(template)
+	if err != nil {
+		return 0, err
+	}
+	id, err = c.db.ExecuteInsertionForID(
+		ctx, insertTemplateQuery, template.newInsertion(networkConfig),
+	)
+	if err != nil {
+		return 0, errors.Wrapf(err, "couldn't add network template %s", template.Name)
+	}
+	return id, nil
+}
+
+//go:embed queries/update-template.sql
+var rawUpdateTemplateQuery string
+var updateTemplateQuery string = strings.TrimSpace(rawUpdateTemplateQuery)
+
+func (c *Client) UpdateTemplate(ctx context.Context, template Template) error {
+	networkConfig, err := serializeNetworkConfig(template)
+	if err != nil {
+		return err
+	}
+	if err = c.db.ExecuteUpdate(
+		ctx, updateTemplateQuery, template.newUpdate(networkConfig),
+	); err != nil {
+		return errors.Wrapf(err, "couldn't update network template with id %d", template.ID)
+	}
+	return nil
+}
+
+//go:embed queries/delete-template.sql
+var rawDeleteTemplateQuery string
+var deleteTemplateQuery string = strings.TrimSpace(rawDeleteTemplateQuery)
+
+func (c *Client) DeleteTemplate(ctx context.Context, id int64) error {
+	if err := c.db.ExecuteDelete(
+		ctx, deleteTemplateQuery, Template{ID: id}.newDelete(),
+	); err != nil {
+		return errors.Wrapf(err, "couldn't delete network template with id %d", id)
+	}
+	return nil
+}
@@ -2,18 +2,29 @@
 package models
 
 import (
+	"github.com/pkg/errors"
+
 	"github.com/sargassum-world/fluitans/pkg/desec"
+	"github.com/sargassum-world/fluitans/pkg/secrets"
+	"github.com/sargassum-world/fluitans/pkg/transport"
 )
 
 type DNSServer struct {
-	Server            string  `json:"server"`
-	API               string  `json:"api"`
-	Name              string  `json:"name"` // Must be unique for display purposes!
-	Description       string  `json:"description"`
-	Authtoken         string  `json:"authtoken"`
-	NetworkCostWeight float32 `json:"local"`
+	Server            string             `json:"server"`
+	API               string             `json:"api"`
+	Name              string             `json:"name"` // Must be unique for display purposes!
+	Description       string             `json:"description"`
+	Authtoken         secrets.Redacted   `json:"authtoken"`
+	NetworkCostWeight float32            `json:"local"`
+	Transport         transport.Settings `json:"transport"`
 }
 
 func (s DNSServer) NewClient() (*desec.ClientWithResponses, error) {
-	return desec.NewAuthClientWithResponses(s.Server, s.Authtoken)
+	httpClient, err := transport.GetHTTPClient(s.Server, s.Transport)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't make http client for %s", s.Server)
+	}
+	return desec.NewAuthClientWithResponses(
+		s.Server, s.Authtoken.Reveal(), desec.WithHTTPClient(httpClient),
+	)
 }
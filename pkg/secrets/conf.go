@@ -0,0 +1,93 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sargassum-world/godest/env"
+)
+
+const envPrefix = "SECRETS_"
+
+type Config struct {
+	Key          []byte
+	PreviousKeys [][]byte
+}
+
+func GetConfig() (c Config, err error) {
+	c.Key, err = getKey()
+	if err != nil {
+		return Config{}, errors.Wrap(err, "couldn't make key-encryption key config")
+	}
+
+	c.PreviousKeys, err = getPreviousKeys()
+	if err != nil {
+		return Config{}, errors.Wrap(err, "couldn't make previous key-encryption keys config")
+	}
+	return c, nil
+}
+
+func readEnvOrFile(varName string) (string, error) {
+	if value := os.Getenv(varName); value != "" {
+		return value, nil
+	}
+	filename := os.Getenv(varName + "_FILE")
+	if filename == "" {
+		return "", nil
+	}
+	value, err := os.ReadFile(filename)
+	if err != nil {
+		return "", errors.Wrapf(err, "couldn't read file %s specified by %s_FILE", filename, varName)
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+func parseKeys(rawKeys string) (keys [][]byte, err error) {
+	for _, rawKey := range strings.FieldsFunc(rawKeys, func(r rune) bool {
+		return r == ',' || r == '\n' || r == ' '
+	}) {
+		key, err := base64.StdEncoding.DecodeString(rawKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't parse key as base64")
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func getKey() ([]byte, error) {
+	rawKey, err := readEnvOrFile(envPrefix + "KEY")
+	if err != nil {
+		return nil, err
+	}
+	if rawKey == "" {
+		// Secrets sealed with a randomly-generated key can't be opened after a restart, so we only
+		// generate a key for the user to record (env.GetKey prints it), and refuse to use it
+		if _, err = env.GetKey(envPrefix+"KEY", KeySize); err != nil {
+			return nil, err
+		}
+		return nil, errors.Errorf(
+			"%sKEY or %sKEY_FILE must be set to a persistent key, such as the key printed above",
+			envPrefix, envPrefix,
+		)
+	}
+
+	keys, err := parseKeys(rawKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) != 1 {
+		return nil, errors.Errorf("expected exactly one key but found %d", len(keys))
+	}
+	return keys[0], nil
+}
+
+func getPreviousKeys() ([][]byte, error) {
+	rawKeys, err := readEnvOrFile(envPrefix + "PREVIOUS_KEYS")
+	if err != nil {
+		return nil, err
+	}
+	return parseKeys(rawKeys)
+}
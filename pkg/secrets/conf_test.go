@@ -0,0 +1,53 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetConfigRequiresKey(t *testing.T) {
+	t.Setenv(envPrefix+"KEY", "")
+	t.Setenv(envPrefix+"KEY_FILE", "")
+	if _, err := GetConfig(); err == nil {
+		t.Error("got config without a persistent key")
+	}
+}
+
+func TestGetConfig(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, KeySize)
+	previousKey := bytes.Repeat([]byte{2}, KeySize)
+	t.Setenv(envPrefix+"KEY", base64.StdEncoding.EncodeToString(key))
+	t.Setenv(envPrefix+"PREVIOUS_KEYS", base64.StdEncoding.EncodeToString(previousKey))
+	c, err := GetConfig()
+	if err != nil {
+		t.Fatalf("couldn't get config: %s", err)
+	}
+	if !bytes.Equal(c.Key, key) {
+		t.Errorf("key is %x, want %x", c.Key, key)
+	}
+	if len(c.PreviousKeys) != 1 || !bytes.Equal(c.PreviousKeys[0], previousKey) {
+		t.Errorf("previous keys are %x, want [%x]", c.PreviousKeys, previousKey)
+	}
+}
+
+func TestGetConfigFromFile(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, KeySize)
+	filename := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(
+		filename, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0o600,
+	); err != nil {
+		t.Fatalf("couldn't write key file: %s", err)
+	}
+	t.Setenv(envPrefix+"KEY", "")
+	t.Setenv(envPrefix+"KEY_FILE", filename)
+	c, err := GetConfig()
+	if err != nil {
+		t.Fatalf("couldn't get config: %s", err)
+	}
+	if !bytes.Equal(c.Key, key) {
+		t.Errorf("key is %x, want %x", c.Key, key)
+	}
+}
@@ -0,0 +1,195 @@
+// Package secrets provides envelope encryption for secrets stored at rest, and a string type
+// which redacts secrets from formatted output.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// KeySize is the required size of a key-encryption key, in bytes.
+const KeySize = 32
+
+const (
+	sealedPrefix    = "sealed"
+	sealedVersion   = "v1"
+	sealedSeparator = ":"
+	sealedParts     = 5
+	keyIDSize       = 4
+)
+
+var encoding = base64.RawStdEncoding
+
+// Keyring seals secrets with envelope encryption: each secret is encrypted with a freshly-generated
+// data key, and the data key is encrypted with the keyring's current key-encryption key. Previous
+// key-encryption keys can be kept in the keyring so that secrets sealed with them can still be
+// opened, and then re-sealed with the current key.
+type Keyring struct {
+	currentID string
+	keys      map[string]cipher.AEAD
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func computeKeyID(key []byte) string {
+	hash := sha256.Sum256(key)
+	return hex.EncodeToString(hash[:keyIDSize])
+}
+
+func NewKeyring(current []byte, previous ...[]byte) (*Keyring, error) {
+	k := &Keyring{
+		keys: make(map[string]cipher.AEAD),
+	}
+	for i, key := range append([][]byte{current}, previous...) {
+		if len(key) != KeySize {
+			return nil, errors.Errorf("key %d has length %d instead of %d", i, len(key), KeySize)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "couldn't make cipher for key %d", i)
+		}
+		id := computeKeyID(key)
+		if _, ok := k.keys[id]; !ok {
+			k.keys[id] = aead
+		}
+		if i == 0 {
+			k.currentID = id
+		}
+	}
+	return k, nil
+}
+
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Wrap(err, "couldn't generate nonce")
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed data is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+// Seal encrypts the plaintext with the current key, returning an opaque string which can be
+// stored. An empty plaintext is sealed as an empty string.
+func (k *Keyring) Seal(plaintext Redacted) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	dataKey := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", errors.Wrap(err, "couldn't generate data key")
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", errors.Wrap(err, "couldn't make cipher for data key")
+	}
+	wrappedKey, err := seal(k.keys[k.currentID], dataKey, []byte(k.currentID))
+	if err != nil {
+		return "", errors.Wrap(err, "couldn't encrypt data key")
+	}
+	ciphertext, err := seal(dataAEAD, []byte(plaintext), wrappedKey)
+	if err != nil {
+		return "", errors.Wrap(err, "couldn't encrypt secret")
+	}
+
+	return strings.Join([]string{
+		sealedPrefix, sealedVersion, k.currentID,
+		encoding.EncodeToString(wrappedKey), encoding.EncodeToString(ciphertext),
+	}, sealedSeparator), nil
+}
+
+func parseSealed(sealed string) (keyID string, wrappedKey, ciphertext []byte, err error) {
+	parts := strings.Split(sealed, sealedSeparator)
+	if len(parts) != sealedParts || parts[0] != sealedPrefix {
+		return "", nil, nil, errors.New("secret is not sealed")
+	}
+	if parts[1] != sealedVersion {
+		return "", nil, nil, errors.Errorf("unknown sealed secret version %s", parts[1])
+	}
+	if wrappedKey, err = encoding.DecodeString(parts[3]); err != nil {
+		return "", nil, nil, errors.Wrap(err, "couldn't decode encrypted data key")
+	}
+	if ciphertext, err = encoding.DecodeString(parts[4]); err != nil {
+		return "", nil, nil, errors.Wrap(err, "couldn't decode encrypted secret")
+	}
+	return parts[2], wrappedKey, ciphertext, nil
+}
+
+// Open decrypts a string produced by Seal, using whichever key in the keyring it was sealed with.
+func (k *Keyring) Open(sealed string) (Redacted, error) {
+	if sealed == "" {
+		return "", nil
+	}
+
+	keyID, wrappedKey, ciphertext, err := parseSealed(sealed)
+	if err != nil {
+		return "", err
+	}
+	keyAEAD, ok := k.keys[keyID]
+	if !ok {
+		return "", errors.Errorf("secret was sealed with unknown key %s", keyID)
+	}
+	dataKey, err := open(keyAEAD, wrappedKey, []byte(keyID))
+	if err != nil {
+		return "", errors.Wrapf(err, "couldn't decrypt data key with key %s", keyID)
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", errors.Wrap(err, "couldn't make cipher for data key")
+	}
+	plaintext, err := open(dataAEAD, ciphertext, wrappedKey)
+	if err != nil {
+		return "", errors.Wrap(err, "couldn't decrypt secret")
+	}
+	return Redacted(plaintext), nil
+}
+
+// IsSealed checks whether the string looks like it was produced by Seal.
+func IsSealed(s string) bool {
+	return strings.HasPrefix(s, sealedPrefix+sealedSeparator)
+}
+
+// NeedsResealing checks whether a stored string is either unsealed or was sealed with a key other
+// than the keyring's current key.
+func (k *Keyring) NeedsResealing(stored string) bool {
+	if stored == "" {
+		return false
+	}
+	keyID, _, _, err := parseSealed(stored)
+	return err != nil || keyID != k.currentID
+}
+
+// Reseal re-encrypts a stored string with the keyring's current key. Strings which were never
+// sealed are treated as plaintext, so that secrets stored before encryption was introduced can be
+// migrated.
+func (k *Keyring) Reseal(stored string) (string, error) {
+	if !IsSealed(stored) {
+		return k.Seal(Redacted(stored))
+	}
+	plaintext, err := k.Open(stored)
+	if err != nil {
+		return "", err
+	}
+	return k.Seal(plaintext)
+}
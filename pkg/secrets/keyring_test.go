@@ -0,0 +1,168 @@
+package secrets
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func newTestKey(fill byte) []byte {
+	return bytes.Repeat([]byte{fill}, KeySize)
+}
+
+func newTestKeyring(t *testing.T, current []byte, previous ...[]byte) *Keyring {
+	t.Helper()
+	k, err := NewKeyring(current, previous...)
+	if err != nil {
+		t.Fatalf("couldn't make keyring: %s", err)
+	}
+	return k
+}
+
+func TestNewKeyringRejectsShortKeys(t *testing.T) {
+	if _, err := NewKeyring(make([]byte, KeySize-1)); err == nil {
+		t.Error("made a keyring with a short current key")
+	}
+	if _, err := NewKeyring(newTestKey(1), make([]byte, KeySize+1)); err == nil {
+		t.Error("made a keyring with a long previous key")
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	k := newTestKeyring(t, newTestKey(1))
+	const plaintext = "0123456789abcdefghijklmn"
+	sealed, err := k.Seal(plaintext)
+	if err != nil {
+		t.Fatalf("couldn't seal secret: %s", err)
+	}
+	if !IsSealed(sealed) {
+		t.Errorf("sealed secret %q doesn't look sealed", sealed)
+	}
+	if strings.Contains(sealed, plaintext) {
+		t.Errorf("sealed secret %q contains the plaintext", sealed)
+	}
+	resealed, err := k.Seal(plaintext)
+	if err != nil {
+		t.Fatalf("couldn't seal secret again: %s", err)
+	}
+	if resealed == sealed {
+		t.Error("sealing the same secret twice produced the same result")
+	}
+
+	opened, err := k.Open(sealed)
+	if err != nil {
+		t.Fatalf("couldn't open sealed secret: %s", err)
+	}
+	if opened.Reveal() != plaintext {
+		t.Errorf("opened secret is %q, want %q", opened.Reveal(), plaintext)
+	}
+}
+
+func TestSealOpenEmpty(t *testing.T) {
+	k := newTestKeyring(t, newTestKey(1))
+	sealed, err := k.Seal("")
+	if err != nil || sealed != "" {
+		t.Errorf("sealed empty secret as %q (error %v), want an empty string", sealed, err)
+	}
+	opened, err := k.Open("")
+	if err != nil || opened != "" {
+		t.Errorf("opened empty string as %q (error %v), want an empty secret", opened, err)
+	}
+}
+
+func TestOpenRejectsInvalidSecrets(t *testing.T) {
+	k := newTestKeyring(t, newTestKey(1))
+	sealed, err := k.Seal("secret")
+	if err != nil {
+		t.Fatalf("couldn't seal secret: %s", err)
+	}
+	parts := strings.Split(sealed, sealedSeparator)
+	tampered := append([]string{}, parts...)
+	tampered[4] = encoding.EncodeToString(bytes.Repeat([]byte{0}, 64))
+	wrongVersion := append([]string{}, parts...)
+	wrongVersion[1] = "v0"
+
+	testCases := []struct {
+		name   string
+		sealed string
+	}{
+		{"plaintext", "secret"},
+		{"tampered ciphertext", strings.Join(tampered, sealedSeparator)},
+		{"unknown version", strings.Join(wrongVersion, sealedSeparator)},
+	}
+	for _, testCase := range testCases {
+		if _, err := k.Open(testCase.sealed); err == nil {
+			t.Errorf("%s: opened without an error", testCase.name)
+		}
+	}
+
+	other := newTestKeyring(t, newTestKey(2))
+	if _, err := other.Open(sealed); err == nil {
+		t.Error("opened a secret sealed with an unknown key")
+	}
+}
+
+func TestNeedsResealing(t *testing.T) {
+	previous := newTestKeyring(t, newTestKey(1))
+	current := newTestKeyring(t, newTestKey(2), newTestKey(1))
+	sealedWithPrevious, err := previous.Seal("secret")
+	if err != nil {
+		t.Fatalf("couldn't seal secret with previous key: %s", err)
+	}
+	sealedWithCurrent, err := current.Seal("secret")
+	if err != nil {
+		t.Fatalf("couldn't seal secret with current key: %s", err)
+	}
+
+	testCases := []struct {
+		name   string
+		stored string
+		want   bool
+	}{
+		{"empty", "", false},
+		{"plaintext", "secret", true},
+		{"sealed with previous key", sealedWithPrevious, true},
+		{"sealed with current key", sealedWithCurrent, false},
+	}
+	for _, testCase := range testCases {
+		if got := current.NeedsResealing(testCase.stored); got != testCase.want {
+			t.Errorf("%s: NeedsResealing is %t, want %t", testCase.name, got, testCase.want)
+		}
+	}
+}
+
+func TestReseal(t *testing.T) {
+	previous := newTestKeyring(t, newTestKey(1))
+	current := newTestKeyring(t, newTestKey(2), newTestKey(1))
+	sealedWithPrevious, err := previous.Seal("secret")
+	if err != nil {
+		t.Fatalf("couldn't seal secret with previous key: %s", err)
+	}
+
+	testCases := []struct {
+		name   string
+		stored string
+	}{
+		{"plaintext", "secret"},
+		{"sealed with previous key", sealedWithPrevious},
+	}
+	for _, testCase := range testCases {
+		resealed, err := current.Reseal(testCase.stored)
+		if err != nil {
+			t.Errorf("%s: couldn't reseal: %s", testCase.name, err)
+			continue
+		}
+		if current.NeedsResealing(resealed) {
+			t.Errorf("%s: resealed secret still needs resealing", testCase.name)
+		}
+		opened, err := current.Open(resealed)
+		if err != nil || opened.Reveal() != "secret" {
+			t.Errorf("%s: opened resealed secret as %q (error %v)", testCase.name, opened, err)
+		}
+	}
+
+	// Secrets sealed with a key which was removed from the keyring can't be recovered
+	if _, err = newTestKeyring(t, newTestKey(2)).Reseal(sealedWithPrevious); err == nil {
+		t.Error("resealed a secret sealed with an unknown key")
+	}
+}
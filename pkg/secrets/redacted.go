@@ -0,0 +1,31 @@
+package secrets
+
+import (
+	"encoding/json"
+)
+
+const redactedPlaceholder = "[redacted]"
+
+// Redacted is a string holding a secret. It's replaced by a placeholder when it's formatted (e.g.
+// in log messages or rendered templates) or marshaled to JSON, so the actual value can only be
+// obtained by explicitly calling Reveal.
+type Redacted string
+
+func (r Redacted) Reveal() string {
+	return string(r)
+}
+
+func (r Redacted) String() string {
+	if r == "" {
+		return ""
+	}
+	return redactedPlaceholder
+}
+
+func (r Redacted) GoString() string {
+	return `"` + r.String() + `"`
+}
+
+func (r Redacted) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
@@ -0,0 +1,51 @@
+package transport
+
+import (
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sargassum-world/godest/env"
+
+	"github.com/sargassum-world/fluitans/pkg/secrets"
+)
+
+func readFile(varName string) (string, error) {
+	filename := os.Getenv(varName)
+	if filename == "" {
+		return "", nil
+	}
+	value, err := os.ReadFile(filename)
+	if err != nil {
+		return "", errors.Wrapf(err, "couldn't read file %s specified by %s", filename, varName)
+	}
+	return string(value), nil
+}
+
+// GetSettings loads transport settings from environment variables with the specified prefix:
+// TIMEOUT (in seconds), CA_FILE, CLIENT_CERT_FILE, CLIENT_KEY_FILE, and PROXY.
+func GetSettings(envPrefix string) (s Settings, err error) {
+	rawTimeout, err := env.GetFloat32(envPrefix+"TIMEOUT", float32(DefaultTimeout.Seconds()))
+	if err != nil {
+		return Settings{}, errors.Wrap(err, "couldn't make timeout config")
+	}
+	s.Timeout = time.Duration(rawTimeout * float32(time.Second))
+
+	if s.CABundle, err = readFile(envPrefix + "CA_FILE"); err != nil {
+		return Settings{}, errors.Wrap(err, "couldn't make CA bundle config")
+	}
+	if s.ClientCert, err = readFile(envPrefix + "CLIENT_CERT_FILE"); err != nil {
+		return Settings{}, errors.Wrap(err, "couldn't make client certificate config")
+	}
+	clientKey, err := readFile(envPrefix + "CLIENT_KEY_FILE")
+	if err != nil {
+		return Settings{}, errors.Wrap(err, "couldn't make client key config")
+	}
+	s.ClientKey = secrets.Redacted(clientKey)
+	s.ProxyURL = env.GetString(envPrefix+"PROXY", "")
+
+	if err = s.Validate(); err != nil {
+		return Settings{}, errors.Wrap(err, "invalid transport settings")
+	}
+	return s, nil
+}
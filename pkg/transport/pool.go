@@ -0,0 +1,71 @@
+package transport
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"sync"
+)
+
+type pooledClient struct {
+	fingerprint [sha256.Size]byte
+	client      *http.Client
+}
+
+// Pool reuses one HTTP client per server, so that connections to each server are kept alive
+// across requests. If a server's settings change, its previous client is replaced.
+type Pool struct {
+	mu      sync.Mutex
+	clients map[string]pooledClient
+}
+
+func NewPool() *Pool {
+	return &Pool{
+		clients: make(map[string]pooledClient),
+	}
+}
+
+// Get returns the HTTP client for the server with the specified settings.
+func (p *Pool) Get(server string, s Settings) (*http.Client, error) {
+	fingerprint := s.fingerprint()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if pooled, ok := p.clients[server]; ok {
+		if pooled.fingerprint == fingerprint {
+			return pooled.client, nil
+		}
+		pooled.client.CloseIdleConnections()
+	}
+	client, err := s.NewHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	p.clients[server] = pooledClient{
+		fingerprint: fingerprint,
+		client:      client,
+	}
+	return client, nil
+}
+
+// Remove closes the idle connections of the server's HTTP client and forgets the client.
+func (p *Pool) Remove(server string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if pooled, ok := p.clients[server]; ok {
+		pooled.client.CloseIdleConnections()
+		delete(p.clients, server)
+	}
+}
+
+var defaultPool = NewPool()
+
+// GetHTTPClient returns the HTTP client for the server from a process-wide pool.
+func GetHTTPClient(server string, s Settings) (*http.Client, error) {
+	return defaultPool.Get(server, s)
+}
+
+// RemoveHTTPClient removes the server's HTTP client from the process-wide pool.
+func RemoveHTTPClient(server string) {
+	defaultPool.Remove(server)
+}
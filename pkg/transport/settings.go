@@ -0,0 +1,146 @@
+// Package transport provides HTTP clients with configurable timeouts, trusted certificate
+// authorities, client certificates, and proxies, which can be reused across requests.
+package transport
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/sargassum-world/fluitans/pkg/secrets"
+)
+
+// DefaultTimeout is the request timeout used when none is specified.
+const DefaultTimeout = 30 * time.Second
+
+// Settings configures how HTTP requests are made to a server. The zero value of each field selects
+// Go's default behavior, except that a zero Timeout means that requests never time out.
+type Settings struct {
+	Timeout    time.Duration    `json:"timeout"`
+	CABundle   string           `json:"caBundle"`   // PEM-encoded certificates to trust
+	ClientCert string           `json:"clientCert"` // PEM-encoded certificate chain for mTLS
+	ClientKey  secrets.Redacted `json:"clientKey"`  // PEM-encoded private key of ClientCert
+	ProxyURL   string           `json:"proxyUrl"`
+}
+
+// NewDefaultSettings returns the settings used for servers which weren't configured otherwise.
+func NewDefaultSettings() Settings {
+	return Settings{
+		Timeout: DefaultTimeout,
+	}
+}
+
+func (s Settings) newTLSConfig() (*tls.Config, error) {
+	if s.CABundle == "" && s.ClientCert == "" && s.ClientKey == "" {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if s.CABundle != "" {
+		// The custom CA bundle is trusted in addition to the system's trusted CAs
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(s.CABundle)) {
+			return nil, errors.New("CA bundle doesn't contain any PEM-encoded certificates")
+		}
+		config.RootCAs = pool
+	}
+	if s.ClientCert != "" || s.ClientKey != "" {
+		if s.ClientCert == "" || s.ClientKey == "" {
+			return nil, errors.New("client certificate and client key must be specified together")
+		}
+		cert, err := tls.X509KeyPair([]byte(s.ClientCert), []byte(s.ClientKey.Reveal()))
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't load client certificate and key")
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+func (s Settings) newProxy() (func(*http.Request) (*url.URL, error), error) {
+	if s.ProxyURL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	proxyURL, err := url.Parse(s.ProxyURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't parse proxy url %s", s.ProxyURL)
+	}
+	switch proxyURL.Scheme {
+	default:
+		return nil, errors.Errorf("unsupported proxy url scheme %s", proxyURL.Scheme)
+	case "http", "https", "socks5":
+	}
+	if proxyURL.Host == "" {
+		return nil, errors.Errorf("proxy url %s has no host", s.ProxyURL)
+	}
+	return http.ProxyURL(proxyURL), nil
+}
+
+// Validate checks whether an HTTP client can be made from the settings.
+func (s Settings) Validate() error {
+	if s.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	if _, err := s.newTLSConfig(); err != nil {
+		return err
+	}
+	if _, err := s.newProxy(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewHTTPClient makes a new HTTP client with its own connection pool. Most callers should instead
+// use a Pool, so that connections are reused across requests.
+func (s Settings) NewHTTPClient() (*http.Client, error) {
+	if s.Timeout < 0 {
+		return nil, errors.New("timeout must not be negative")
+	}
+	tlsConfig, err := s.newTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := s.newProxy()
+	if err != nil {
+		return nil, err
+	}
+
+	baseTransport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, errors.New("default http transport has an unexpected type")
+	}
+	transport := baseTransport.Clone()
+	transport.Proxy = proxy
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   s.Timeout,
+	}, nil
+}
+
+// fingerprint identifies the settings without retaining the client key in memory.
+func (s Settings) fingerprint() [sha256.Size]byte {
+	hash := sha256.New()
+	_ = binary.Write(hash, binary.BigEndian, int64(s.Timeout))
+	for _, field := range []string{s.CABundle, s.ClientCert, s.ClientKey.Reveal(), s.ProxyURL} {
+		_ = binary.Write(hash, binary.BigEndian, int64(len(field)))
+		hash.Write([]byte(field))
+	}
+	var fingerprint [sha256.Size]byte
+	copy(fingerprint[:], hash.Sum(nil))
+	return fingerprint
+}
@@ -0,0 +1,581 @@
+package zerotier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// RuleType is the type of a rule in a network's flow rules.
+type RuleType string
+
+const (
+	RuleActionDrop     RuleType = "ACTION_DROP"
+	RuleActionAccept   RuleType = "ACTION_ACCEPT"
+	RuleActionTee      RuleType = "ACTION_TEE"
+	RuleActionWatch    RuleType = "ACTION_WATCH"
+	RuleActionRedirect RuleType = "ACTION_REDIRECT"
+	RuleActionBreak    RuleType = "ACTION_BREAK"
+	RuleActionPriority RuleType = "ACTION_PRIORITY"
+
+	RuleMatchSourceZerotierAddress RuleType = "MATCH_SOURCE_ZEROTIER_ADDRESS"
+	RuleMatchDestZerotierAddress   RuleType = "MATCH_DEST_ZEROTIER_ADDRESS"
+	RuleMatchVLANID                RuleType = "MATCH_VLAN_ID"
+	RuleMatchVLANPCP               RuleType = "MATCH_VLAN_PCP"
+	RuleMatchVLANDEI               RuleType = "MATCH_VLAN_DEI"
+	RuleMatchMACSource             RuleType = "MATCH_MAC_SOURCE"
+	RuleMatchMACDest               RuleType = "MATCH_MAC_DEST"
+	RuleMatchIPv4Source            RuleType = "MATCH_IPV4_SOURCE"
+	RuleMatchIPv4Dest              RuleType = "MATCH_IPV4_DEST"
+	RuleMatchIPv6Source            RuleType = "MATCH_IPV6_SOURCE"
+	RuleMatchIPv6Dest              RuleType = "MATCH_IPV6_DEST"
+	RuleMatchIPTOS                 RuleType = "MATCH_IP_TOS"
+	RuleMatchIPProtocol            RuleType = "MATCH_IP_PROTOCOL"
+	RuleMatchEtherType             RuleType = "MATCH_ETHERTYPE"
+	RuleMatchICMP                  RuleType = "MATCH_ICMP"
+	RuleMatchIPSourcePortRange     RuleType = "MATCH_IP_SOURCE_PORT_RANGE"
+	RuleMatchIPDestPortRange       RuleType = "MATCH_IP_DEST_PORT_RANGE"
+	RuleMatchCharacteristics       RuleType = "MATCH_CHARACTERISTICS"
+	RuleMatchFrameSizeRange        RuleType = "MATCH_FRAME_SIZE_RANGE"
+	RuleMatchRandom                RuleType = "MATCH_RANDOM"
+	RuleMatchTagsDifference        RuleType = "MATCH_TAGS_DIFFERENCE"
+	RuleMatchTagsBitwiseAnd        RuleType = "MATCH_TAGS_BITWISE_AND"
+	RuleMatchTagsBitwiseOr         RuleType = "MATCH_TAGS_BITWISE_OR"
+	RuleMatchTagsBitwiseXor        RuleType = "MATCH_TAGS_BITWISE_XOR"
+	RuleMatchTagsEqual             RuleType = "MATCH_TAGS_EQUAL"
+	RuleMatchTagSender             RuleType = "MATCH_TAG_SENDER"
+	RuleMatchTagReceiver           RuleType = "MATCH_TAG_RECEIVER"
+	RuleMatchIntegerRange          RuleType = "MATCH_INTEGER_RANGE"
+)
+
+// IsAction reports whether the rule type is an action, rather than a match.
+func (t RuleType) IsAction() bool {
+	return strings.HasPrefix(string(t), "ACTION_")
+}
+
+// Rule is a rule in a network's flow rules, in the representation used by the ZeroTier controller
+// API. Each type of rule only uses some of the fields; the rest are left nil.
+type Rule struct {
+	Type RuleType `json:"type"`
+	// Not inverts the result of a match
+	Not bool `json:"not,omitempty"`
+	// Or combines a match with the preceding match by a logical OR rather than a logical AND
+	Or bool `json:"or,omitempty"`
+
+	// Fields of actions
+	Address   *string `json:"address,omitempty"`
+	Flags     *uint64 `json:"flags,omitempty"`
+	Length    *uint64 `json:"length,omitempty"`
+	QoSBucket *uint64 `json:"qosBucket,omitempty"`
+
+	// Fields of matches
+	ZT          *string      `json:"zt,omitempty"`
+	VLANID      *uint64      `json:"vlanId,omitempty"`
+	VLANPCP     *uint64      `json:"vlanPcp,omitempty"`
+	VLANDEI     *uint64      `json:"vlanDei,omitempty"`
+	MAC         *string      `json:"mac,omitempty"`
+	IP          *string      `json:"ip,omitempty"`
+	Mask        *RuleInteger `json:"mask,omitempty"`
+	Start       *RuleInteger `json:"start,omitempty"`
+	End         *RuleInteger `json:"end,omitempty"`
+	IPProtocol  *uint64      `json:"ipProtocol,omitempty"`
+	EtherType   *uint64      `json:"etherType,omitempty"`
+	ICMPType    *uint64      `json:"icmpType,omitempty"`
+	ICMPCode    *uint64      `json:"icmpCode,omitempty"`
+	Probability *uint64      `json:"probability,omitempty"`
+	ID          *uint64      `json:"id,omitempty"`
+	Value       *uint64      `json:"value,omitempty"`
+	Idx         *uint64      `json:"idx,omitempty"`
+	Little      *bool        `json:"little,omitempty"`
+	Bits        *uint64      `json:"bits,omitempty"`
+}
+
+// RuleInteger is a bitmask or a range bound in a rule. The ZeroTier controller API represents it
+// as a number in most rules, but as a hexadecimal string in MATCH_CHARACTERISTICS rules (for the
+// mask) and in MATCH_INTEGER_RANGE rules (for the start and end of the range).
+type RuleInteger struct {
+	Value uint64
+	Hex   bool
+}
+
+var errInvalidRuleInteger = errors.New("must be a number or a hexadecimal string")
+
+// ruleIntegerIsHex reports whether the controller API represents the named field as a
+// hexadecimal string in rules of the type.
+func ruleIntegerIsHex(ruleType RuleType, field string) bool {
+	switch ruleType {
+	default:
+		return false
+	case RuleMatchCharacteristics:
+		return field == "mask"
+	case RuleMatchIntegerRange:
+		return field == "start" || field == "end"
+	}
+}
+
+func (m RuleInteger) MarshalJSON() ([]byte, error) {
+	if m.Hex {
+		return json.Marshal(fmt.Sprintf("%016x", m.Value))
+	}
+	return json.Marshal(m.Value)
+}
+
+func (m *RuleInteger) UnmarshalJSON(data []byte) error {
+	var rawHex string
+	if err := json.Unmarshal(data, &rawHex); err == nil {
+		const base = 16
+		const bitSize = 64
+		value, err := strconv.ParseUint(strings.TrimPrefix(rawHex, "0x"), base, bitSize)
+		if err != nil {
+			return errInvalidRuleInteger
+		}
+		m.Value = value
+		m.Hex = true
+		return nil
+	}
+	if err := json.Unmarshal(data, &m.Value); err != nil {
+		return errInvalidRuleInteger
+	}
+	m.Hex = false
+	return nil
+}
+
+func (m RuleInteger) String() string {
+	if m.Hex {
+		return fmt.Sprintf("0x%x", m.Value)
+	}
+	return strconv.FormatUint(m.Value, 10)
+}
+
+// Capability is a capability in a network, in the representation used by the ZeroTier controller
+// API. A capability is a set of rules which are only evaluated for members which have the
+// capability, and which are evaluated before the network's rules.
+type Capability struct {
+	ID uint64 `json:"id"`
+	// Default makes the capability apply to members which haven't been assigned any capabilities
+	Default bool   `json:"default,omitempty"`
+	Rules   []Rule `json:"rules"`
+}
+
+// Tag is a tag in a network, in the representation used by the ZeroTier controller API. Each
+// member of the network can have a value for the tag, which rules can match on.
+type Tag struct {
+	ID uint64 `json:"id"`
+	// Default is the value of the tag for members which haven't been assigned a value
+	Default *uint64 `json:"default,omitempty"`
+}
+
+// Conversion
+
+// recodeJSON converts a value into another type with the same JSON representation.
+func recodeJSON(value interface{}, result interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return errors.Wrap(err, "couldn't serialize")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber() // so that masks and integer ranges don't lose precision as float64s
+	if err = decoder.Decode(result); err != nil {
+		return errors.Wrap(err, "couldn't deserialize")
+	}
+	return nil
+}
+
+// NewRules converts rules from the untyped representation in a ControllerNetwork. Rules from the
+// controller are trusted, so they aren't validated.
+func NewRules(rawRules []map[string]interface{}) ([]Rule, error) {
+	rules := make([]Rule, 0, len(rawRules))
+	if err := recodeJSON(rawRules, &rules); err != nil {
+		return nil, errors.Wrap(err, "couldn't convert rules")
+	}
+	return rules, nil
+}
+
+// RawRules converts rules into the untyped representation in a ControllerNetwork.
+func RawRules(rules []Rule) ([]map[string]interface{}, error) {
+	rawRules := make([]map[string]interface{}, 0, len(rules))
+	if err := recodeJSON(rules, &rawRules); err != nil {
+		return nil, errors.Wrap(err, "couldn't convert rules")
+	}
+	return rawRules, nil
+}
+
+// NewCapabilities converts capabilities from the untyped representation in a ControllerNetwork.
+func NewCapabilities(rawCapabilities []map[string]interface{}) ([]Capability, error) {
+	capabilities := make([]Capability, 0, len(rawCapabilities))
+	if err := recodeJSON(rawCapabilities, &capabilities); err != nil {
+		return nil, errors.Wrap(err, "couldn't convert capabilities")
+	}
+	return capabilities, nil
+}
+
+// RawCapabilities converts capabilities into the untyped representation in a ControllerNetwork.
+func RawCapabilities(capabilities []Capability) ([]map[string]interface{}, error) {
+	rawCapabilities := make([]map[string]interface{}, 0, len(capabilities))
+	if err := recodeJSON(capabilities, &rawCapabilities); err != nil {
+		return nil, errors.Wrap(err, "couldn't convert capabilities")
+	}
+	return rawCapabilities, nil
+}
+
+// NewTags converts tags from the untyped representation in a ControllerNetwork.
+func NewTags(rawTags []map[string]interface{}) ([]Tag, error) {
+	tags := make([]Tag, 0, len(rawTags))
+	if err := recodeJSON(rawTags, &tags); err != nil {
+		return nil, errors.Wrap(err, "couldn't convert tags")
+	}
+	return tags, nil
+}
+
+// RawTags converts tags into the untyped representation in a ControllerNetwork.
+func RawTags(tags []Tag) ([]map[string]interface{}, error) {
+	rawTags := make([]map[string]interface{}, 0, len(tags))
+	if err := recodeJSON(tags, &rawTags); err != nil {
+		return nil, errors.Wrap(err, "couldn't convert tags")
+	}
+	return rawTags, nil
+}
+
+// Validation
+
+// RuleError is a problem with a rule in a list of rules.
+type RuleError struct {
+	// Index is the position of the rule in the list, starting from 0
+	Index int
+	// Field is the JSON name of the field with the problem, or empty if the problem is with the
+	// rule as a whole
+	Field   string
+	Message string
+}
+
+func (e RuleError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("rule %d: %s", e.Index+1, e.Message)
+	}
+	return fmt.Sprintf("rule %d: %s %s", e.Index+1, e.Field, e.Message)
+}
+
+// RuleErrors is a list of problems with the rules in a list of rules.
+type RuleErrors []RuleError
+
+func (e RuleErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+type ruleField struct {
+	name     string
+	required bool
+	max      uint64 // only used for numeric fields
+}
+
+const (
+	maxUint8  = math.MaxUint8
+	maxUint16 = math.MaxUint16
+	maxUint32 = math.MaxUint32
+)
+
+var (
+	portRangeFields = []ruleField{{"start", true, maxUint16}, {"end", true, maxUint16}}
+	tagFields       = []ruleField{{"id", true, maxUint32}, {"value", true, maxUint32}}
+)
+
+var ruleSchemas = map[RuleType][]ruleField{
+	RuleActionDrop:   {},
+	RuleActionAccept: {},
+	RuleActionTee: {
+		{"address", true, 0}, {"flags", false, maxUint32}, {"length", false, maxUint16},
+	},
+	RuleActionWatch: {
+		{"address", true, 0}, {"flags", false, maxUint32}, {"length", false, maxUint16},
+	},
+	RuleActionRedirect: {{"address", true, 0}, {"flags", false, maxUint32}},
+	RuleActionBreak:    {},
+	RuleActionPriority: {{"qosBucket", true, 8}},
+
+	RuleMatchSourceZerotierAddress: {{"zt", true, 0}},
+	RuleMatchDestZerotierAddress:   {{"zt", true, 0}},
+	RuleMatchVLANID:                {{"vlanId", true, 4095}},
+	RuleMatchVLANPCP:               {{"vlanPcp", true, 7}},
+	RuleMatchVLANDEI:               {{"vlanDei", true, 1}},
+	RuleMatchMACSource:             {{"mac", true, 0}},
+	RuleMatchMACDest:               {{"mac", true, 0}},
+	RuleMatchIPv4Source:            {{"ip", true, 0}},
+	RuleMatchIPv4Dest:              {{"ip", true, 0}},
+	RuleMatchIPv6Source:            {{"ip", true, 0}},
+	RuleMatchIPv6Dest:              {{"ip", true, 0}},
+	RuleMatchIPTOS: {
+		{"mask", true, maxUint8}, {"start", true, maxUint8}, {"end", true, maxUint8},
+	},
+	RuleMatchIPProtocol:        {{"ipProtocol", true, maxUint8}},
+	RuleMatchEtherType:         {{"etherType", true, maxUint16}},
+	RuleMatchICMP:              {{"icmpType", true, maxUint8}, {"icmpCode", false, maxUint8}},
+	RuleMatchIPSourcePortRange: portRangeFields,
+	RuleMatchIPDestPortRange:   portRangeFields,
+	RuleMatchCharacteristics:   {{"mask", true, math.MaxUint64}},
+	RuleMatchFrameSizeRange:    portRangeFields,
+	RuleMatchRandom:            {{"probability", true, maxUint32}},
+	RuleMatchTagsDifference:    tagFields,
+	RuleMatchTagsBitwiseAnd:    tagFields,
+	RuleMatchTagsBitwiseOr:     tagFields,
+	RuleMatchTagsBitwiseXor:    tagFields,
+	RuleMatchTagsEqual:         tagFields,
+	RuleMatchTagSender:         tagFields,
+	RuleMatchTagReceiver:       tagFields,
+	RuleMatchIntegerRange: {
+		{"start", true, math.MaxUint64},
+		{"end", true, math.MaxUint64},
+		{"idx", true, maxUint16},
+		{"little", false, 0},
+		{"bits", true, 64},
+	},
+}
+
+// numberFields returns the rule's numeric fields, keyed by JSON name.
+func (r Rule) numberFields() map[string]*uint64 {
+	numbers := map[string]*uint64{
+		"flags":       r.Flags,
+		"length":      r.Length,
+		"qosBucket":   r.QoSBucket,
+		"vlanId":      r.VLANID,
+		"vlanPcp":     r.VLANPCP,
+		"vlanDei":     r.VLANDEI,
+		"ipProtocol":  r.IPProtocol,
+		"etherType":   r.EtherType,
+		"icmpType":    r.ICMPType,
+		"icmpCode":    r.ICMPCode,
+		"probability": r.Probability,
+		"id":          r.ID,
+		"value":       r.Value,
+		"idx":         r.Idx,
+		"bits":        r.Bits,
+	}
+	for name, value := range r.integerFields() {
+		if value != nil {
+			numbers[name] = &value.Value
+		}
+	}
+	return numbers
+}
+
+// integerFields returns the rule's fields which may be represented as hexadecimal strings, keyed
+// by JSON name.
+func (r Rule) integerFields() map[string]*RuleInteger {
+	return map[string]*RuleInteger{
+		"mask":  r.Mask,
+		"start": r.Start,
+		"end":   r.End,
+	}
+}
+
+// stringFields returns the rule's string fields, keyed by JSON name.
+func (r Rule) stringFields() map[string]*string {
+	return map[string]*string{
+		"address": r.Address,
+		"zt":      r.ZT,
+		"mac":     r.MAC,
+		"ip":      r.IP,
+	}
+}
+
+// setFields returns the JSON names of the fields used by the rule, other than its type.
+func (r Rule) setFields() map[string]bool {
+	fields := make(map[string]bool)
+	for name, value := range r.numberFields() {
+		if value != nil {
+			fields[name] = true
+		}
+	}
+	for name, value := range r.stringFields() {
+		if value != nil {
+			fields[name] = true
+		}
+	}
+	if r.Little != nil {
+		fields["little"] = true
+	}
+	return fields
+}
+
+var zerotierAddressParser = regexp.MustCompile(`^[0-9a-f]{10}$`)
+
+func validateRuleString(ruleType RuleType, name, value string) string {
+	switch name {
+	default:
+		return ""
+	case "address", "zt":
+		if !zerotierAddressParser.MatchString(value) {
+			return "must be a ZeroTier address of 10 lowercase hexadecimal digits"
+		}
+	case "mac":
+		const macLength = 6
+		if mac, err := net.ParseMAC(value); err != nil || len(mac) != macLength {
+			return "must be a MAC address, e.g. 01:23:45:67:89:ab"
+		}
+	case "ip":
+		prefix, err := netip.ParsePrefix(value)
+		switch ruleType {
+		case RuleMatchIPv4Source, RuleMatchIPv4Dest:
+			if err != nil || !prefix.Addr().Is4() {
+				return "must be an IPv4 address with a prefix length, e.g. 10.0.0.0/8"
+			}
+		case RuleMatchIPv6Source, RuleMatchIPv6Dest:
+			if err != nil || !prefix.Addr().Is6() || prefix.Addr().Is4In6() {
+				return "must be an IPv6 address with a prefix length, e.g. fd00::/8"
+			}
+		}
+	}
+	return ""
+}
+
+// Validate checks the rule against the fields used by its type, returning a RuleError (with the
+// provided index) for each problem.
+func (r Rule) Validate(index int) []RuleError {
+	schema, known := ruleSchemas[r.Type]
+	if !known {
+		return []RuleError{{
+			Index: index, Field: "type", Message: fmt.Sprintf("%q is unknown", r.Type),
+		}}
+	}
+
+	ruleErrors := make([]RuleError, 0)
+	if r.Type.IsAction() && r.Not {
+		ruleErrors = append(ruleErrors, RuleError{
+			Index: index, Field: "not", Message: "can only be set on match rules",
+		})
+	}
+	if r.Type.IsAction() && r.Or {
+		ruleErrors = append(ruleErrors, RuleError{
+			Index: index, Field: "or", Message: "can only be set on match rules",
+		})
+	}
+
+	setFields := r.setFields()
+	usedFields := make(map[string]bool, len(schema))
+	numbers := r.numberFields()
+	texts := r.stringFields()
+	for _, field := range schema {
+		usedFields[field.name] = true
+		if !setFields[field.name] {
+			if field.required {
+				ruleErrors = append(ruleErrors, RuleError{
+					Index: index, Field: field.name, Message: "is required",
+				})
+			}
+			continue
+		}
+		if value, isNumber := numbers[field.name]; isNumber && *value > field.max {
+			ruleErrors = append(ruleErrors, RuleError{
+				Index: index, Field: field.name, Message: fmt.Sprintf("must be at most %d", field.max),
+			})
+		}
+		if value, isString := texts[field.name]; isString {
+			if message := validateRuleString(r.Type, field.name, *value); message != "" {
+				ruleErrors = append(ruleErrors, RuleError{
+					Index: index, Field: field.name, Message: message,
+				})
+			}
+		}
+	}
+	for _, field := range sortedKeys(setFields) {
+		if !usedFields[field] {
+			ruleErrors = append(ruleErrors, RuleError{
+				Index: index, Field: field, Message: fmt.Sprintf("isn't used by %s rules", r.Type),
+			})
+		}
+	}
+
+	if r.Start != nil && r.End != nil && r.Start.Value > r.End.Value {
+		ruleErrors = append(ruleErrors, RuleError{
+			Index: index, Field: "end", Message: "must not be less than start",
+		})
+	}
+	if r.Type == RuleMatchIntegerRange && r.Bits != nil && *r.Bits == 0 {
+		ruleErrors = append(ruleErrors, RuleError{
+			Index: index, Field: "bits", Message: "must be at least 1",
+		})
+	}
+	return ruleErrors
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ValidateRules checks each rule against the fields used by its type. It returns a RuleErrors
+// with every problem, or nil if there were no problems.
+func ValidateRules(rules []Rule) error {
+	ruleErrors := make(RuleErrors, 0)
+	for i, rule := range rules {
+		ruleErrors = append(ruleErrors, rule.Validate(i)...)
+	}
+	if len(ruleErrors) > 0 {
+		return ruleErrors
+	}
+	return nil
+}
+
+// Parsing
+
+func newRuleDecodingError(index int, err error) RuleError {
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		return RuleError{
+			Index: index, Field: typeErr.Field, Message: fmt.Sprintf("can't be a %s", typeErr.Value),
+		}
+	case errors.Is(err, errInvalidRuleInteger):
+		return RuleError{Index: index, Message: "each of mask, start, and end " + err.Error()}
+	default:
+		return RuleError{Index: index, Message: strings.TrimPrefix(err.Error(), "json: ")}
+	}
+}
+
+// ParseRules parses and validates rules from a JSON array of rule objects, such as one entered by
+// a user. Problems with individual rules are returned as a RuleErrors.
+func ParseRules(document []byte) ([]Rule, error) {
+	rawRules := make([]json.RawMessage, 0)
+	if err := json.Unmarshal(document, &rawRules); err != nil {
+		return nil, errors.Wrap(err, "rules must be a JSON array of rule objects")
+	}
+
+	rules := make([]Rule, len(rawRules))
+	ruleErrors := make(RuleErrors, 0)
+	for i, rawRule := range rawRules {
+		decoder := json.NewDecoder(bytes.NewReader(rawRule))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&rules[i]); err != nil {
+			ruleErrors = append(ruleErrors, newRuleDecodingError(i, err))
+			continue
+		}
+		ruleErrors = append(ruleErrors, rules[i].Validate(i)...)
+
+		// The controller API expects integers in different representations for different rule types
+		for name, value := range rules[i].integerFields() {
+			if value != nil {
+				value.Hex = ruleIntegerIsHex(rules[i].Type, name)
+			}
+		}
+	}
+	if len(ruleErrors) > 0 {
+		return nil, ruleErrors
+	}
+	return rules, nil
+}
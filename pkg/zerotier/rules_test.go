@@ -0,0 +1,145 @@
+package zerotier
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// controllerNetworkPayload is a network as returned by the ZeroTier controller API, with rules
+// which use each representation of integers.
+const controllerNetworkPayload = `{
+	"id": "8056c2e21c000001",
+	"name": "example",
+	"rules": [
+		{
+			"type": "MATCH_INTEGER_RANGE", "not": false, "or": false,
+			"start": "0000000000000000", "end": "00000000000000ff", "idx": 20, "little": false,
+			"bits": 8
+		},
+		{"type": "MATCH_CHARACTERISTICS", "not": true, "or": false, "mask": "0000000000000004"},
+		{"type": "MATCH_IP_DEST_PORT_RANGE", "not": false, "or": false, "start": 80, "end": 443},
+		{"type": "MATCH_IP_TOS", "not": false, "or": false, "mask": 252, "start": 0, "end": 8},
+		{"type": "ACTION_ACCEPT"},
+		{"type": "ACTION_DROP"}
+	]
+}`
+
+func TestNewRulesDecodesControllerPayload(t *testing.T) {
+	var network ControllerNetwork
+	if err := json.Unmarshal([]byte(controllerNetworkPayload), &network); err != nil {
+		t.Fatalf("couldn't unmarshal network: %s", err)
+	}
+	rules, err := NewRules(*network.Rules)
+	if err != nil {
+		t.Fatalf("couldn't convert rules: %s", err)
+	}
+	if len(rules) != len(*network.Rules) {
+		t.Fatalf("got %d rules, want %d", len(rules), len(*network.Rules))
+	}
+	if err = ValidateRules(rules); err != nil {
+		t.Errorf("rules from the controller are invalid: %s", err)
+	}
+
+	integers := []struct {
+		name  string
+		value *RuleInteger
+		want  RuleInteger
+	}{
+		{"integer range start", rules[0].Start, RuleInteger{Value: 0, Hex: true}},
+		{"integer range end", rules[0].End, RuleInteger{Value: 0xff, Hex: true}},
+		{"characteristics mask", rules[1].Mask, RuleInteger{Value: 4, Hex: true}},
+		{"port range start", rules[2].Start, RuleInteger{Value: 80}},
+		{"port range end", rules[2].End, RuleInteger{Value: 443}},
+		{"tos mask", rules[3].Mask, RuleInteger{Value: 252}},
+	}
+	for _, integer := range integers {
+		if integer.value == nil {
+			t.Errorf("%s is missing", integer.name)
+			continue
+		}
+		if *integer.value != integer.want {
+			t.Errorf("%s is %+v, want %+v", integer.name, *integer.value, integer.want)
+		}
+	}
+}
+
+func TestRawRulesRoundTrip(t *testing.T) {
+	var network ControllerNetwork
+	if err := json.Unmarshal([]byte(controllerNetworkPayload), &network); err != nil {
+		t.Fatalf("couldn't unmarshal network: %s", err)
+	}
+	rules, err := NewRules(*network.Rules)
+	if err != nil {
+		t.Fatalf("couldn't convert rules: %s", err)
+	}
+	rawRules, err := RawRules(rules)
+	if err != nil {
+		t.Fatalf("couldn't convert rules back: %s", err)
+	}
+	if start := rawRules[0]["start"]; start != "0000000000000000" {
+		t.Errorf("integer range start is %#v, want a hexadecimal string", start)
+	}
+	if end := rawRules[0]["end"]; end != "00000000000000ff" {
+		t.Errorf("integer range end is %#v, want a hexadecimal string", end)
+	}
+	if mask := rawRules[1]["mask"]; mask != "0000000000000004" {
+		t.Errorf("characteristics mask is %#v, want a hexadecimal string", mask)
+	}
+	if start, ok := rawRules[2]["start"].(json.Number); !ok || start.String() != "80" {
+		t.Errorf("port range start is %#v, want a number", rawRules[2]["start"])
+	}
+
+	roundTripped, err := NewRules(rawRules)
+	if err != nil {
+		t.Fatalf("couldn't convert rules again: %s", err)
+	}
+	if !reflect.DeepEqual(roundTripped, rules) {
+		t.Errorf("rules changed in a round trip:\ngot  %+v\nwant %+v", roundTripped, rules)
+	}
+}
+
+func TestParseRulesSetsIntegerRepresentations(t *testing.T) {
+	rules, err := ParseRules([]byte(`[
+		{"type": "MATCH_INTEGER_RANGE", "start": 16, "end": "0x20", "idx": 0, "bits": 8},
+		{"type": "MATCH_CHARACTERISTICS", "mask": 4},
+		{"type": "MATCH_IP_SOURCE_PORT_RANGE", "start": 16, "end": 32},
+		{"type": "ACTION_ACCEPT"}
+	]`))
+	if err != nil {
+		t.Fatalf("couldn't parse rules: %s", err)
+	}
+	encoded, err := json.Marshal(rules)
+	if err != nil {
+		t.Fatalf("couldn't marshal rules: %s", err)
+	}
+	const want = `[` +
+		`{"type":"MATCH_INTEGER_RANGE","start":"0000000000000010","end":"0000000000000020",` +
+		`"idx":0,"bits":8},` +
+		`{"type":"MATCH_CHARACTERISTICS","mask":"0000000000000004"},` +
+		`{"type":"MATCH_IP_SOURCE_PORT_RANGE","start":16,"end":32},` +
+		`{"type":"ACTION_ACCEPT"}` +
+		`]`
+	if string(encoded) != want {
+		t.Errorf("got %s, want %s", encoded, want)
+	}
+}
+
+func TestParseRulesRejectsInvalidIntegers(t *testing.T) {
+	testCases := []struct {
+		name     string
+		document string
+	}{
+		{"non-hexadecimal string", `[{"type": "MATCH_INTEGER_RANGE", "start": "zz", "end": "ff"}]`},
+		{"boolean", `[{"type": "MATCH_IP_TOS", "mask": true, "start": 0, "end": 1}]`},
+		{
+			"reversed range",
+			`[{"type": "MATCH_INTEGER_RANGE", "start": "ff", "end": "0", "idx": 0, "bits": 8}]`,
+		},
+	}
+	for _, testCase := range testCases {
+		if _, err := ParseRules([]byte(testCase.document)); err == nil {
+			t.Errorf("%s: parsed without an error", testCase.name)
+		}
+	}
+}
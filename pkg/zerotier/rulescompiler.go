@@ -0,0 +1,1014 @@
+package zerotier
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// This file implements a compiler for ZeroTier's rules language, which is documented at
+// https://docs.zerotier.com/zerotier/rules. Source code in the rules language is a sequence of
+// statements, each terminated by a semicolon:
+//
+//   - A rule statement is an action (drop, accept, break, tee, watch, or redirect), followed by any
+//     number of matches, optionally joined by "and" or "or" and inverted by "not".
+//   - A tag statement defines a tag with an id, a default value, and names for its values (enum)
+//     and for its bits (flag), which can then be used in tag matches (tdiff, tand, tor, txor, teq,
+//     tseq, and treq).
+//   - A cap statement defines a capability with an id, made of the rule statements which follow
+//     it, until an empty statement.
+//   - A macro statement defines a macro with parameters, made of the rule statements which follow
+//     it, until an empty statement. An include statement expands a macro in place, replacing each
+//     $parameter in the macro with the corresponding argument.
+//
+// Comments start with a # and run to the end of the line.
+
+// CompileError is a problem in source code in the rules language.
+type CompileError struct {
+	// Line is the line of the problem in the source code, starting from 1
+	Line int
+	// Column is the column of the problem in the source code, starting from 1
+	Column  int
+	Message string
+}
+
+func (e CompileError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// CompiledRules is the result of compiling source code in the rules language.
+type CompiledRules struct {
+	Rules        []Rule
+	Capabilities []Capability
+	Tags         []Tag
+	// Names are the names of the capabilities, tags, and tag values, which the controller doesn't
+	// store
+	Names RuleNames
+}
+
+// RuleNames are the names given to capabilities, tags, and tag values in the rules language.
+type RuleNames struct {
+	// Capabilities are capability names, keyed by capability id
+	Capabilities map[uint64]string
+	// Tags are tag names, keyed by tag id
+	Tags map[uint64]string
+	// TagValues are names of tag values (from enums and flags), keyed by tag id and then by value
+	TagValues map[uint64]map[uint64]string
+}
+
+// NewRuleNames makes an empty set of names.
+func NewRuleNames() RuleNames {
+	return RuleNames{
+		Capabilities: make(map[uint64]string),
+		Tags:         make(map[uint64]string),
+		TagValues:    make(map[uint64]map[uint64]string),
+	}
+}
+
+// Names
+
+var ruleEtherTypes = map[string]uint64{
+	"ipv4":      0x0800,
+	"arp":       0x0806,
+	"wol":       0x0842,
+	"rarp":      0x8035,
+	"appletalk": 0x809b,
+	"aarp":      0x80f3,
+	"ipx":       0x8137,
+	"ipv6":      0x86dd,
+	"lldp":      0x88cc,
+}
+
+var ruleIPProtocols = map[string]uint64{
+	"icmp":    1,
+	"igmp":    2,
+	"tcp":     6,
+	"udp":     17,
+	"ipv6":    41,
+	"gre":     47,
+	"esp":     50,
+	"ah":      51,
+	"icmp6":   58,
+	"ospf":    89,
+	"pim":     103,
+	"sctp":    132,
+	"udplite": 136,
+}
+
+// ruleCharacteristics maps the names of packet characteristics to their bits in the masks of
+// MATCH_CHARACTERISTICS rules.
+var ruleCharacteristics = map[string]uint64{
+	"inbound":   63,
+	"multicast": 62,
+	"broadcast": 61,
+	"ipauth":    60,
+	"macauth":   59,
+	"tcp_fin":   0,
+	"tcp_syn":   1,
+	"tcp_rst":   2,
+	"tcp_psh":   3,
+	"tcp_ack":   4,
+	"tcp_urg":   5,
+	"tcp_ece":   6,
+	"tcp_cwr":   7,
+	"tcp_ns":    8,
+	"tcp_rs2":   9,
+	"tcp_rs1":   10,
+	"tcp_rs0":   11,
+}
+
+var ruleTagMatches = map[string]RuleType{
+	"tdiff": RuleMatchTagsDifference,
+	"tand":  RuleMatchTagsBitwiseAnd,
+	"tor":   RuleMatchTagsBitwiseOr,
+	"txor":  RuleMatchTagsBitwiseXor,
+	"teq":   RuleMatchTagsEqual,
+	"tseq":  RuleMatchTagSender,
+	"treq":  RuleMatchTagReceiver,
+}
+
+var ruleNameParser = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// Tokenization
+
+type ruleToken struct {
+	text   string
+	line   int
+	column int
+}
+
+func (t ruleToken) errorf(format string, args ...interface{}) error {
+	return CompileError{Line: t.line, Column: t.column, Message: fmt.Sprintf(format, args...)}
+}
+
+const ruleDelimiters = ";(),"
+
+func tokenizeRules(source string) (tokens []ruleToken, end ruleToken) {
+	lines := strings.Split(source, "\n")
+	for i, line := range lines {
+		runes := []rune(line)
+		start := -1
+		addWord := func(end int) {
+			if start >= 0 {
+				tokens = append(tokens, ruleToken{
+					text: string(runes[start:end]), line: i + 1, column: start + 1,
+				})
+				start = -1
+			}
+		}
+	scan:
+		for j, r := range runes {
+			switch {
+			case r == '#':
+				addWord(j)
+				break scan
+			case unicode.IsSpace(r):
+				addWord(j)
+			case strings.ContainsRune(ruleDelimiters, r):
+				addWord(j)
+				tokens = append(tokens, ruleToken{text: string(r), line: i + 1, column: j + 1})
+			default:
+				if start < 0 {
+					start = j
+				}
+			}
+		}
+		if start >= 0 {
+			addWord(len(runes))
+		}
+	}
+	lastLine := []rune(lines[len(lines)-1])
+	return tokens, ruleToken{line: len(lines), column: len(lastLine) + 1}
+}
+
+// Compilation
+
+type ruleMacro struct {
+	params []string
+	body   []ruleToken
+}
+
+type ruleTagDefinition struct {
+	token  ruleToken
+	index  int // position of the tag in CompiledRules.Tags
+	values map[string]uint64
+}
+
+// ruleTagReference is a tag match whose tag and value are resolved after all tags are defined.
+type ruleTagReference struct {
+	capability int // position in CompiledRules.Capabilities, or -1 for the network's rules
+	rule       int // position of the rule in its list of rules
+	tag        ruleToken
+	value      ruleToken
+}
+
+// maxMacroExpansions bounds macro expansion, so that a macro which includes itself is reported as
+// an error rather than being expanded forever.
+const maxMacroExpansions = 1000
+
+type rulesCompiler struct {
+	tokens     []ruleToken
+	position   int
+	end        ruleToken
+	expansions int
+
+	macros         map[string]ruleMacro
+	tags           map[string]*ruleTagDefinition
+	tagIDs         map[uint64]ruleToken
+	capabilityIDs  map[uint64]ruleToken
+	capabilityName map[string]ruleToken
+	tagReferences  []ruleTagReference
+	result         CompiledRules
+}
+
+// CompileRules compiles source code in the rules language into rules, capabilities, and tags for
+// a network. Problems in the source code are returned as a CompileError.
+func CompileRules(source string) (CompiledRules, error) {
+	tokens, end := tokenizeRules(source)
+	c := rulesCompiler{
+		tokens:         tokens,
+		end:            end,
+		macros:         make(map[string]ruleMacro),
+		tags:           make(map[string]*ruleTagDefinition),
+		tagIDs:         make(map[uint64]ruleToken),
+		capabilityIDs:  make(map[uint64]ruleToken),
+		capabilityName: make(map[string]ruleToken),
+		result: CompiledRules{
+			Rules:        make([]Rule, 0),
+			Capabilities: make([]Capability, 0),
+			Tags:         make([]Tag, 0),
+			Names:        NewRuleNames(),
+		},
+	}
+	if err := c.compile(); err != nil {
+		return CompiledRules{}, err
+	}
+	return c.result, nil
+}
+
+func (c *rulesCompiler) next() (ruleToken, bool) {
+	if c.position >= len(c.tokens) {
+		return c.end, false
+	}
+	token := c.tokens[c.position]
+	c.position++
+	return token, true
+}
+
+func (c *rulesCompiler) expect(expected string) (ruleToken, error) {
+	token, ok := c.next()
+	if !ok {
+		return token, token.errorf("expected %s, but the rules ended", expected)
+	}
+	return token, nil
+}
+
+func (c *rulesCompiler) expectText(text string) error {
+	token, err := c.expect(strconv.Quote(text))
+	if err != nil {
+		return err
+	}
+	if token.text != text {
+		return token.errorf("expected %q, but found %q", text, token.text)
+	}
+	return nil
+}
+
+func (c *rulesCompiler) expectName(expected string) (ruleToken, error) {
+	token, err := c.expect(expected)
+	if err != nil {
+		return token, err
+	}
+	if !ruleNameParser.MatchString(token.text) {
+		return token, token.errorf(
+			"expected %s of letters, digits, underscores, and hyphens, but found %q",
+			expected, token.text,
+		)
+	}
+	return token, nil
+}
+
+func (c *rulesCompiler) compile() error {
+	for {
+		token, ok := c.next()
+		if !ok {
+			break
+		}
+		var err error
+		switch token.text {
+		case ";":
+			// Empty statements are allowed
+		case "macro":
+			err = c.compileMacro()
+		case "include":
+			err = c.expandMacro(token)
+		case "tag":
+			err = c.compileTag()
+		case "cap":
+			err = c.compileCapability()
+		default:
+			var rules []Rule
+			rules, err = c.compileRule(token, -1, len(c.result.Rules))
+			c.result.Rules = append(c.result.Rules, rules...)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return c.resolveTagReferences()
+}
+
+// Macros
+
+func (c *rulesCompiler) compileMacro() error {
+	name, err := c.expectName("a macro name")
+	if err != nil {
+		return err
+	}
+	if _, defined := c.macros[name.text]; defined {
+		return name.errorf("macro %s is already defined", name.text)
+	}
+	if err = c.expectText("("); err != nil {
+		return err
+	}
+	macro := ruleMacro{params: make([]string, 0)}
+	for {
+		param, err := c.expect("a macro parameter or \")\"")
+		if err != nil {
+			return err
+		}
+		if param.text == ")" && len(macro.params) == 0 {
+			break
+		}
+		if !ruleNameParser.MatchString(param.text) {
+			return param.errorf("expected a macro parameter name, but found %q", param.text)
+		}
+		macro.params = append(macro.params, param.text)
+		delimiter, err := c.expect("\",\" or \")\"")
+		if err != nil {
+			return err
+		}
+		if delimiter.text == ")" {
+			break
+		}
+		if delimiter.text != "," {
+			return delimiter.errorf("expected \",\" or \")\", but found %q", delimiter.text)
+		}
+	}
+
+	// The body runs until an empty statement, i.e. a semicolon at the start of a statement
+	statementStart := true
+	inInclude := false
+	for {
+		token, err := c.expect("the end of the macro (an empty statement)")
+		if err != nil {
+			return err
+		}
+		switch {
+		case statementStart && token.text == ";":
+			c.macros[name.text] = macro
+			return nil
+		case token.text == "macro" || token.text == "cap" || token.text == "tag":
+			return token.errorf("macros can only contain rules and includes, but found %q", token.text)
+		case token.text == "include":
+			inInclude = true
+			statementStart = false
+		case inInclude && token.text == ")":
+			// Includes aren't terminated by semicolons
+			inInclude = false
+			statementStart = true
+		default:
+			statementStart = token.text == ";"
+		}
+		macro.body = append(macro.body, token)
+	}
+}
+
+func (c *rulesCompiler) expandMacro(include ruleToken) error {
+	name, err := c.expectName("a macro name")
+	if err != nil {
+		return err
+	}
+	macro, defined := c.macros[name.text]
+	if !defined {
+		return name.errorf("macro %s isn't defined", name.text)
+	}
+	if err = c.expectText("("); err != nil {
+		return err
+	}
+	args := make(map[string]string, len(macro.params))
+	for i, param := range macro.params {
+		arg, err := c.expect(fmt.Sprintf("a value for macro parameter %s", param))
+		if err != nil {
+			return err
+		}
+		if strings.Contains(ruleDelimiters, arg.text) {
+			return arg.errorf("expected a value for macro parameter %s, but found %q", param, arg.text)
+		}
+		args["$"+param] = arg.text
+		if i < len(macro.params)-1 {
+			if err = c.expectText(","); err != nil {
+				return err
+			}
+		}
+	}
+	if err = c.expectText(")"); err != nil {
+		return err
+	}
+
+	c.expansions++
+	if c.expansions > maxMacroExpansions {
+		return include.errorf(
+			"macros were expanded more than %d times; does macro %s include itself?",
+			maxMacroExpansions, name.text,
+		)
+	}
+	expanded := make([]ruleToken, 0, len(c.tokens)+len(macro.body))
+	expanded = append(expanded, c.tokens[:c.position]...)
+	for _, token := range macro.body {
+		if strings.HasPrefix(token.text, "$") {
+			arg, ok := args[token.text]
+			if !ok {
+				return token.errorf("macro %s has no parameter %s", name.text, token.text[1:])
+			}
+			token.text = arg
+		}
+		expanded = append(expanded, token)
+	}
+	c.tokens = append(expanded, c.tokens[c.position:]...)
+	return nil
+}
+
+// Tags
+
+func (c *rulesCompiler) compileTag() error {
+	name, err := c.expectName("a tag name")
+	if err != nil {
+		return err
+	}
+	if _, defined := c.tags[name.text]; defined {
+		return name.errorf("tag %s is already defined", name.text)
+	}
+	definition := &ruleTagDefinition{token: name, values: make(map[string]uint64)}
+	valueNames := make(map[uint64]string)
+	var id *uint64
+	var defaultValue *ruleToken
+	for {
+		token, err := c.expect("id, enum, flag, default, or \";\"")
+		if err != nil {
+			return err
+		}
+		switch token.text {
+		default:
+			return token.errorf("expected id, enum, flag, default, or \";\", but found %q", token.text)
+		case "id":
+			if id != nil {
+				return token.errorf("tag %s already has an id", name.text)
+			}
+			idToken, value, err := c.compileNumber(maxUint32)
+			if err != nil {
+				return err
+			}
+			if _, used := c.tagIDs[value]; used {
+				return idToken.errorf("tag id %d is already used", value)
+			}
+			id = &value
+		case "enum", "flag":
+			maxValue := uint64(maxUint32)
+			if token.text == "flag" {
+				const maxBit = 31
+				maxValue = maxBit
+			}
+			_, value, err := c.compileNumber(maxValue)
+			if err != nil {
+				return err
+			}
+			if token.text == "flag" {
+				value = 1 << value
+			}
+			valueName, err := c.expectName("a name for the tag value")
+			if err != nil {
+				return err
+			}
+			if _, defined := definition.values[valueName.text]; defined {
+				return valueName.errorf("tag %s already has a value named %s", name.text, valueName.text)
+			}
+			if previous, named := valueNames[value]; named {
+				// Each value can only have one name, so that the name can be stored and shown again
+				return valueName.errorf(
+					"tag %s already has a name for value %d: %s", name.text, value, previous,
+				)
+			}
+			definition.values[valueName.text] = value
+			valueNames[value] = valueName.text
+		case "default":
+			valueToken, err := c.expect("a default value")
+			if err != nil {
+				return err
+			}
+			defaultValue = &valueToken
+		case ";":
+			if id == nil {
+				return token.errorf("tag %s needs an id", name.text)
+			}
+			tag := Tag{ID: *id}
+			if defaultValue != nil {
+				value, err := definition.resolve(*defaultValue)
+				if err != nil {
+					return err
+				}
+				tag.Default = &value
+			}
+			definition.index = len(c.result.Tags)
+			c.result.Tags = append(c.result.Tags, tag)
+			c.result.Names.Tags[*id] = name.text
+			c.result.Names.TagValues[*id] = valueNames
+			c.tags[name.text] = definition
+			c.tagIDs[*id] = name
+			return nil
+		}
+	}
+}
+
+func (d ruleTagDefinition) resolve(token ruleToken) (uint64, error) {
+	if value, named := d.values[token.text]; named {
+		return value, nil
+	}
+	value, err := parseRuleNumber(token, maxUint32)
+	if err != nil {
+		return 0, token.errorf(
+			"expected a value of tag %s, either a number or a name defined by an enum or flag, "+
+				"but found %q",
+			d.token.text, token.text,
+		)
+	}
+	return value, nil
+}
+
+func (c *rulesCompiler) resolveTagReferences() error {
+	for _, reference := range c.tagReferences {
+		definition, defined := c.tags[reference.tag.text]
+		if !defined {
+			return reference.tag.errorf("tag %s isn't defined", reference.tag.text)
+		}
+		value, err := definition.resolve(reference.value)
+		if err != nil {
+			return err
+		}
+		rules := c.result.Rules
+		if reference.capability >= 0 {
+			rules = c.result.Capabilities[reference.capability].Rules
+		}
+		id := c.result.Tags[definition.index].ID
+		rules[reference.rule].ID = &id
+		rules[reference.rule].Value = &value
+	}
+	return nil
+}
+
+// Capabilities
+
+func (c *rulesCompiler) compileCapability() error {
+	name, err := c.expectName("a capability name")
+	if err != nil {
+		return err
+	}
+	if _, defined := c.capabilityName[name.text]; defined {
+		return name.errorf("capability %s is already defined", name.text)
+	}
+	index := len(c.result.Capabilities)
+	capability := Capability{Rules: make([]Rule, 0)}
+	var id *uint64
+	for {
+		token, err := c.expect("the end of the capability (an empty statement)")
+		if err != nil {
+			return err
+		}
+		switch token.text {
+		case "id":
+			if id != nil {
+				return token.errorf("capability %s already has an id", name.text)
+			}
+			idToken, value, err := c.compileNumber(maxUint32)
+			if err != nil {
+				return err
+			}
+			if _, used := c.capabilityIDs[value]; used {
+				return idToken.errorf("capability id %d is already used", value)
+			}
+			id = &value
+		case "default":
+			capability.Default = true
+		case "include":
+			if err = c.expandMacro(token); err != nil {
+				return err
+			}
+		case "macro", "tag", "cap":
+			return token.errorf(
+				"capabilities can only contain rules and includes, but found %q", token.text,
+			)
+		case ";":
+			if id == nil {
+				return token.errorf("capability %s needs an id", name.text)
+			}
+			capability.ID = *id
+			c.result.Capabilities = append(c.result.Capabilities, capability)
+			c.result.Names.Capabilities[*id] = name.text
+			c.capabilityName[name.text] = name
+			c.capabilityIDs[*id] = name
+			return nil
+		default:
+			rules, err := c.compileRule(token, index, len(capability.Rules))
+			if err != nil {
+				return err
+			}
+			capability.Rules = append(capability.Rules, rules...)
+		}
+	}
+}
+
+// Rules
+
+// compileRule compiles a rule statement into its matches followed by its action. The capability
+// and offset are the position where the rules will be added, for resolving tags in tag matches.
+func (c *rulesCompiler) compileRule(token ruleToken, capability, offset int) ([]Rule, error) {
+	action, err := c.compileAction(token)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]Rule, 0)
+	var not, or *ruleToken
+	for {
+		token, err := c.expect("a match or \";\"")
+		if err != nil {
+			return nil, err
+		}
+		switch token.text {
+		case ";":
+			if not != nil {
+				return nil, not.errorf("expected a match after \"not\"")
+			}
+			if or != nil {
+				return nil, or.errorf("expected a match after \"or\"")
+			}
+			return append(rules, action), nil
+		case "and":
+			if or != nil {
+				return nil, token.errorf("expected a match after \"or\", but found \"and\"")
+			}
+		case "or":
+			if or != nil || not != nil {
+				return nil, token.errorf("expected a match, but found \"or\"")
+			}
+			if len(rules) == 0 {
+				// The controller would combine the match with an implicit match of every packet
+				return nil, token.errorf("expected a match before \"or\"")
+			}
+			or = &token
+		case "not":
+			if not != nil {
+				return nil, token.errorf("expected a match, but found \"not\"")
+			}
+			not = &token
+		default:
+			rule, err := c.compileMatch(token, capability, offset+len(rules))
+			if err != nil {
+				return nil, err
+			}
+			rule.Not = not != nil
+			rule.Or = or != nil
+			rules = append(rules, rule)
+			not = nil
+			or = nil
+		}
+	}
+}
+
+func (c *rulesCompiler) compileAction(token ruleToken) (Rule, error) {
+	switch token.text {
+	default:
+		return Rule{}, token.errorf(
+			"expected a statement starting with drop, accept, break, tee, watch, redirect, tag, cap, "+
+				"macro, or include, but found %q",
+			token.text,
+		)
+	case "drop":
+		return Rule{Type: RuleActionDrop}, nil
+	case "accept":
+		return Rule{Type: RuleActionAccept}, nil
+	case "break":
+		return Rule{Type: RuleActionBreak}, nil
+	case "tee", "watch":
+		rule := Rule{Type: RuleActionTee}
+		if token.text == "watch" {
+			rule.Type = RuleActionWatch
+		}
+		_, length, err := c.compileNumber(maxUint16)
+		if err != nil {
+			return Rule{}, err
+		}
+		rule.Length = &length
+		if rule.Address, err = c.compileString(rule.Type, "address"); err != nil {
+			return Rule{}, err
+		}
+		return rule, nil
+	case "redirect":
+		rule := Rule{Type: RuleActionRedirect}
+		var err error
+		if rule.Address, err = c.compileString(rule.Type, "address"); err != nil {
+			return Rule{}, err
+		}
+		return rule, nil
+	}
+}
+
+func (c *rulesCompiler) compileMatch(token ruleToken, capability, index int) (Rule, error) {
+	if ruleType, isTagMatch := ruleTagMatches[token.text]; isTagMatch {
+		tag, err := c.expectName("a tag name")
+		if err != nil {
+			return Rule{}, err
+		}
+		value, err := c.expect("a tag value")
+		if err != nil {
+			return Rule{}, err
+		}
+		c.tagReferences = append(c.tagReferences, ruleTagReference{
+			capability: capability, rule: index, tag: tag, value: value,
+		})
+		return Rule{Type: ruleType}, nil
+	}
+
+	var rule Rule
+	var err error
+	switch token.text {
+	default:
+		return Rule{}, token.errorf("expected a match or \";\", but found %q", token.text)
+	case "ztsrc", "ztdest":
+		rule.Type = RuleMatchSourceZerotierAddress
+		if token.text == "ztdest" {
+			rule.Type = RuleMatchDestZerotierAddress
+		}
+		rule.ZT, err = c.compileString(rule.Type, "zt")
+	case "vlan":
+		rule.Type = RuleMatchVLANID
+		rule.VLANID, err = c.compileNumberField(4095)
+	case "vlanpcp":
+		rule.Type = RuleMatchVLANPCP
+		rule.VLANPCP, err = c.compileNumberField(7)
+	case "vlandei":
+		rule.Type = RuleMatchVLANDEI
+		rule.VLANDEI, err = c.compileNumberField(1)
+	case "ethertype":
+		rule.Type = RuleMatchEtherType
+		rule.EtherType, err = c.compileNamedNumber(ruleEtherTypes, "an ethertype", maxUint16)
+	case "macsrc", "macdest":
+		rule.Type = RuleMatchMACSource
+		if token.text == "macdest" {
+			rule.Type = RuleMatchMACDest
+		}
+		rule.MAC, err = c.compileMAC(rule.Type)
+	case "ipsrc", "ipdest":
+		rule, err = c.compileIP(token.text == "ipsrc")
+	case "iptos":
+		rule.Type = RuleMatchIPTOS
+		var mask *uint64
+		if mask, err = c.compileNumberField(maxUint8); err != nil {
+			return Rule{}, err
+		}
+		rule.Mask = &RuleInteger{Value: *mask}
+		rule.Start, rule.End, err = c.compileRange(maxUint8)
+	case "ipprotocol":
+		rule.Type = RuleMatchIPProtocol
+		rule.IPProtocol, err = c.compileNamedNumber(ruleIPProtocols, "an IP protocol", maxUint8)
+	case "icmp":
+		rule, err = c.compileICMP()
+	case "sport", "dport":
+		rule.Type = RuleMatchIPSourcePortRange
+		if token.text == "dport" {
+			rule.Type = RuleMatchIPDestPortRange
+		}
+		rule.Start, rule.End, err = c.compileRange(maxUint16)
+	case "chr":
+		rule, err = c.compileCharacteristics()
+	case "framesize":
+		rule.Type = RuleMatchFrameSizeRange
+		rule.Start, rule.End, err = c.compileRange(maxUint16)
+	case "random":
+		rule, err = c.compileRandom()
+	}
+	if err != nil {
+		return Rule{}, err
+	}
+	return rule, nil
+}
+
+// Match Arguments
+
+func parseRuleNumber(token ruleToken, maxValue uint64) (uint64, error) {
+	const base = 0 // so that hexadecimal numbers can be written with a 0x prefix
+	const bitSize = 64
+	value, err := strconv.ParseUint(token.text, base, bitSize)
+	if err != nil || value > maxValue {
+		return 0, token.errorf("expected a number from 0 to %d, but found %q", maxValue, token.text)
+	}
+	return value, nil
+}
+
+func (c *rulesCompiler) compileNumber(maxValue uint64) (ruleToken, uint64, error) {
+	token, err := c.expect("a number")
+	if err != nil {
+		return token, 0, err
+	}
+	value, err := parseRuleNumber(token, maxValue)
+	return token, value, err
+}
+
+func (c *rulesCompiler) compileNumberField(maxValue uint64) (*uint64, error) {
+	_, value, err := c.compileNumber(maxValue)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+func (c *rulesCompiler) compileNamedNumber(
+	names map[string]uint64, expected string, maxValue uint64,
+) (*uint64, error) {
+	token, err := c.expect(expected)
+	if err != nil {
+		return nil, err
+	}
+	if value, named := names[strings.ToLower(token.text)]; named {
+		return &value, nil
+	}
+	value, err := parseRuleNumber(token, maxValue)
+	if err != nil {
+		return nil, token.errorf(
+			"expected %s, either a name or a number from 0 to %d, but found %q",
+			expected, maxValue, token.text,
+		)
+	}
+	return &value, nil
+}
+
+func (c *rulesCompiler) compileRange(maxValue uint64) (start, end *RuleInteger, err error) {
+	token, err := c.expect("a number or a range of numbers")
+	if err != nil {
+		return nil, nil, err
+	}
+	rawStart, rawEnd, isRange := strings.Cut(token.text, "-")
+	if !isRange {
+		rawEnd = rawStart
+	}
+	startValue, err := parseRuleNumber(ruleToken{rawStart, token.line, token.column}, maxValue)
+	if err != nil {
+		return nil, nil, err
+	}
+	endValue, err := parseRuleNumber(ruleToken{rawEnd, token.line, token.column}, maxValue)
+	if err != nil {
+		return nil, nil, err
+	}
+	if startValue > endValue {
+		return nil, nil, token.errorf("range %s must not end before it starts", token.text)
+	}
+	return &RuleInteger{Value: startValue}, &RuleInteger{Value: endValue}, nil
+}
+
+func (c *rulesCompiler) compileString(ruleType RuleType, field string) (*string, error) {
+	token, err := c.expect("a ZeroTier address")
+	if err != nil {
+		return nil, err
+	}
+	value := strings.ToLower(token.text)
+	if message := validateRuleString(ruleType, field, value); message != "" {
+		return nil, token.errorf("%q %s", token.text, message)
+	}
+	return &value, nil
+}
+
+func (c *rulesCompiler) compileMAC(ruleType RuleType) (*string, error) {
+	token, err := c.expect("a MAC address")
+	if err != nil {
+		return nil, err
+	}
+	if message := validateRuleString(ruleType, "mac", token.text); message != "" {
+		return nil, token.errorf("%q %s", token.text, message)
+	}
+	mac, err := net.ParseMAC(token.text)
+	if err != nil {
+		return nil, token.errorf("%q isn't a MAC address: %s", token.text, err)
+	}
+	value := mac.String()
+	return &value, nil
+}
+
+func (c *rulesCompiler) compileIP(source bool) (Rule, error) {
+	token, err := c.expect("an IP address")
+	if err != nil {
+		return Rule{}, err
+	}
+	var prefix netip.Prefix
+	if strings.Contains(token.text, "/") {
+		prefix, err = netip.ParsePrefix(token.text)
+	} else {
+		// A single address is matched exactly
+		var addr netip.Addr
+		if addr, err = netip.ParseAddr(token.text); err == nil {
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+	}
+	if err != nil || prefix.Addr().Is4In6() || prefix.Addr().Zone() != "" {
+		return Rule{}, token.errorf(
+			"expected an IPv4 or IPv6 address, optionally with a prefix length (e.g. 10.0.0.0/8), "+
+				"but found %q",
+			token.text,
+		)
+	}
+	// Bits of the address after the prefix length are ignored, so they're cleared for consistency
+	ip := prefix.Masked().String()
+	switch {
+	case prefix.Addr().Is4() && source:
+		return Rule{Type: RuleMatchIPv4Source, IP: &ip}, nil
+	case prefix.Addr().Is4():
+		return Rule{Type: RuleMatchIPv4Dest, IP: &ip}, nil
+	case source:
+		return Rule{Type: RuleMatchIPv6Source, IP: &ip}, nil
+	default:
+		return Rule{Type: RuleMatchIPv6Dest, IP: &ip}, nil
+	}
+}
+
+func (c *rulesCompiler) compileICMP() (Rule, error) {
+	rule := Rule{Type: RuleMatchICMP}
+	var err error
+	if rule.ICMPType, err = c.compileNumberField(maxUint8); err != nil {
+		return Rule{}, err
+	}
+	token, err := c.expect("an ICMP code, \"-\", or -1")
+	if err != nil {
+		return Rule{}, err
+	}
+	if token.text == "-" || token.text == "-1" {
+		// Any code is matched
+		return rule, nil
+	}
+	code, err := parseRuleNumber(token, maxUint8)
+	if err != nil {
+		return Rule{}, token.errorf(
+			"expected an ICMP code from 0 to %d, or \"-\" or -1 for any code, but found %q",
+			maxUint8, token.text,
+		)
+	}
+	rule.ICMPCode = &code
+	return rule, nil
+}
+
+func (c *rulesCompiler) compileCharacteristics() (Rule, error) {
+	mask := uint64(0)
+	for {
+		token, err := c.expect("a packet characteristic")
+		if err != nil {
+			return Rule{}, err
+		}
+		bit, known := ruleCharacteristics[strings.ToLower(token.text)]
+		if !known {
+			return Rule{}, token.errorf(
+				"expected a packet characteristic (%s), but found %q",
+				strings.Join(sortedRuleNames(ruleCharacteristics), ", "), token.text,
+			)
+		}
+		mask |= 1 << bit
+		if c.position >= len(c.tokens) || c.tokens[c.position].text != "," {
+			break
+		}
+		c.position++ // skip the comma
+	}
+	return Rule{Type: RuleMatchCharacteristics, Mask: &RuleInteger{Value: mask, Hex: true}}, nil
+}
+
+func (c *rulesCompiler) compileRandom() (Rule, error) {
+	token, err := c.expect("a probability")
+	if err != nil {
+		return Rule{}, err
+	}
+	const bitSize = 64
+	probability, err := strconv.ParseFloat(token.text, bitSize)
+	if err != nil || probability < 0 || probability > 1 {
+		return Rule{}, token.errorf("expected a probability from 0 to 1, but found %q", token.text)
+	}
+	value := uint64(math.Round(probability * maxUint32))
+	return Rule{Type: RuleMatchRandom, Probability: &value}, nil
+}
+
+func sortedRuleNames(names map[string]uint64) []string {
+	set := make(map[string]bool, len(names))
+	for name := range names {
+		set[name] = true
+	}
+	return sortedKeys(set)
+}
@@ -0,0 +1,177 @@
+package zerotier
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompileRules(t *testing.T) {
+	testCases := []struct {
+		name   string
+		source string
+		want   string // JSON of the compiled rules
+	}{
+		{"empty", "# nothing here\n", `[]`},
+		{"action", "drop;", `[{"type":"ACTION_DROP"}]`},
+		{
+			"matches",
+			"accept ethertype ipv4 and not ipprotocol tcp;",
+			`[{"type":"MATCH_ETHERTYPE","etherType":2048},` +
+				`{"type":"MATCH_IP_PROTOCOL","not":true,"ipProtocol":6},{"type":"ACTION_ACCEPT"}]`,
+		},
+		{
+			"or",
+			"accept ethertype arp or ethertype ipv4;",
+			`[{"type":"MATCH_ETHERTYPE","etherType":2054},` +
+				`{"type":"MATCH_ETHERTYPE","or":true,"etherType":2048},{"type":"ACTION_ACCEPT"}]`,
+		},
+		{
+			"masked ip prefix",
+			"accept ipdest 10.0.0.5/8;",
+			`[{"type":"MATCH_IPV4_DEST","ip":"10.0.0.0/8"},{"type":"ACTION_ACCEPT"}]`,
+		},
+		{
+			"single ip address",
+			"accept ipsrc fd00::1;",
+			`[{"type":"MATCH_IPV6_SOURCE","ip":"fd00::1/128"},{"type":"ACTION_ACCEPT"}]`,
+		},
+		{
+			"icmp with any code as -1",
+			"accept icmp 8 -1;",
+			`[{"type":"MATCH_ICMP","icmpType":8},{"type":"ACTION_ACCEPT"}]`,
+		},
+		{
+			"icmp with any code as -",
+			"accept icmp 8 -;",
+			`[{"type":"MATCH_ICMP","icmpType":8},{"type":"ACTION_ACCEPT"}]`,
+		},
+		{
+			"icmp with code",
+			"accept icmp 3 0;",
+			`[{"type":"MATCH_ICMP","icmpType":3,"icmpCode":0},{"type":"ACTION_ACCEPT"}]`,
+		},
+		{
+			"port range",
+			"accept dport 80-443;",
+			`[{"type":"MATCH_IP_DEST_PORT_RANGE","start":80,"end":443},{"type":"ACTION_ACCEPT"}]`,
+		},
+		{
+			"characteristics",
+			"drop chr tcp_syn,inbound;",
+			`[{"type":"MATCH_CHARACTERISTICS","mask":"8000000000000002"},{"type":"ACTION_DROP"}]`,
+		},
+		{
+			"tee",
+			"tee 128 0123456789;",
+			`[{"type":"ACTION_TEE","address":"0123456789","length":128}]`,
+		},
+		{
+			"macro",
+			"macro allow_port(port)\n  accept dport $port;\n;\ninclude allow_port(22)\ndrop;",
+			`[{"type":"MATCH_IP_DEST_PORT_RANGE","start":22,"end":22},{"type":"ACTION_ACCEPT"},` +
+				`{"type":"ACTION_DROP"}]`,
+		},
+	}
+	for _, testCase := range testCases {
+		compiled, err := CompileRules(testCase.source)
+		if err != nil {
+			t.Errorf("%s: couldn't compile: %s", testCase.name, err)
+			continue
+		}
+		encoded, err := json.Marshal(compiled.Rules)
+		if err != nil {
+			t.Errorf("%s: couldn't marshal rules: %s", testCase.name, err)
+			continue
+		}
+		if string(encoded) != testCase.want {
+			t.Errorf("%s: got %s, want %s", testCase.name, encoded, testCase.want)
+		}
+	}
+}
+
+func TestCompileRulesErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		source  string
+		line    int
+		column  int
+		message string
+	}{
+		{"leading or", "drop or ethertype ipv4;", 1, 6, `expected a match before "or"`},
+		{"trailing or", "drop ethertype ipv4 or;", 1, 21, `expected a match after "or"`},
+		{"missing semicolon", "accept", 1, 7, "the rules ended"},
+		{"unknown action", "allow;", 1, 1, `found "allow"`},
+		{"reversed range", "accept dport 443-80;", 1, 14, "must not end before it starts"},
+		{"large port", "accept dport 65536;", 1, 14, "from 0 to 65535"},
+		{"invalid icmp code", "accept icmp 8 -2;", 1, 15, "any code"},
+		{"invalid ip", "accept ipdest 10.0.0/8;", 1, 15, "expected an IPv4 or IPv6 address"},
+		{"undefined tag", "accept teq department 1;", 1, 12, "tag department isn't defined"},
+		{
+			"duplicate tag value", "tag t\n  id 1\n  enum 1 a\n  flag 0 b\n;", 4, 10,
+			"already has a name for value 1: a",
+		},
+		{"tag without id", "tag t\n  default 1\n;", 3, 1, "tag t needs an id"},
+		{"capability without id", "cap c\n  accept;\n;", 3, 1, "capability c needs an id"},
+	}
+	for _, testCase := range testCases {
+		_, err := CompileRules(testCase.source)
+		var compileErr CompileError
+		if !errorsAsCompileError(err, &compileErr) {
+			t.Errorf("%s: got error %v, want a CompileError", testCase.name, err)
+			continue
+		}
+		if compileErr.Line != testCase.line || compileErr.Column != testCase.column {
+			t.Errorf(
+				"%s: error is at line %d, column %d, want line %d, column %d", testCase.name,
+				compileErr.Line, compileErr.Column, testCase.line, testCase.column,
+			)
+		}
+		if !strings.Contains(compileErr.Message, testCase.message) {
+			t.Errorf("%s: error %q doesn't contain %q", testCase.name, compileErr.Message, testCase.message)
+		}
+	}
+}
+
+func errorsAsCompileError(err error, target *CompileError) bool {
+	compileErr, ok := err.(CompileError)
+	if ok {
+		*target = compileErr
+	}
+	return ok
+}
+
+func TestCompileRulesNames(t *testing.T) {
+	compiled, err := CompileRules(`
+tag department
+  id 1000
+  enum 100 engineering
+  flag 3 remote
+  default engineering
+;
+cap superuser
+  id 1
+  accept teq department engineering;
+;
+accept tor department remote;
+`)
+	if err != nil {
+		t.Fatalf("couldn't compile: %s", err)
+	}
+	want := RuleNames{
+		Capabilities: map[uint64]string{1: "superuser"},
+		Tags:         map[uint64]string{1000: "department"},
+		TagValues:    map[uint64]map[uint64]string{1000: {100: "engineering", 8: "remote"}},
+	}
+	if !reflect.DeepEqual(compiled.Names, want) {
+		t.Errorf("got names %+v, want %+v", compiled.Names, want)
+	}
+	if len(compiled.Tags) != 1 || compiled.Tags[0].Default == nil || *compiled.Tags[0].Default != 100 {
+		t.Errorf("got tags %+v, want tag 1000 with default 100", compiled.Tags)
+	}
+	tagMatch := compiled.Rules[0]
+	if tagMatch.ID == nil || *tagMatch.ID != 1000 || tagMatch.Value == nil || *tagMatch.Value != 8 {
+		t.Errorf("got tag match %+v, want tag 1000 and value 8", tagMatch)
+	}
+}
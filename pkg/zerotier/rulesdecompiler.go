@@ -0,0 +1,375 @@
+package zerotier
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DecompileRules converts a network's rules, capabilities, and tags into source code in the rules
+// language, which compiles back into the same rules, capabilities, and tags. Because the
+// controller doesn't store the names of capabilities, tags, and tag values, they're taken from the
+// provided names; capabilities and tags without usable names are named after their ids, and tag
+// values without usable names are written as numbers. Rules which can't be expressed in the rules
+// language (such as ACTION_PRIORITY and MATCH_INTEGER_RANGE rules) produce an error.
+func DecompileRules(
+	rules []Rule, capabilities []Capability, tags []Tag, names RuleNames,
+) (string, error) {
+	var b strings.Builder
+
+	// Tags
+	tagIDs := make(map[uint64]*uint64, len(tags))
+	for _, tag := range tags {
+		tagIDs[tag.ID] = tag.Default
+	}
+	addTagReferences := func(rules []Rule) {
+		for _, rule := range rules {
+			if _, isTagMatch := ruleTagMatchNames()[rule.Type]; isTagMatch && rule.ID != nil {
+				if _, defined := tagIDs[*rule.ID]; !defined {
+					tagIDs[*rule.ID] = nil
+				}
+			}
+		}
+	}
+	addTagReferences(rules)
+	for _, capability := range capabilities {
+		addTagReferences(capability.Rules)
+	}
+	sortedTagIDs := make([]uint64, 0, len(tagIDs))
+	for id := range tagIDs {
+		sortedTagIDs = append(sortedTagIDs, id)
+	}
+	sort.Slice(sortedTagIDs, func(i, j int) bool { return sortedTagIDs[i] < sortedTagIDs[j] })
+	capabilityIDs := make([]uint64, len(capabilities))
+	for i, capability := range capabilities {
+		capabilityIDs[i] = capability.ID
+	}
+	n := newRuleNamer(names, capabilityIDs, sortedTagIDs)
+	for _, id := range sortedTagIDs {
+		fmt.Fprintf(&b, "tag %s\n  id %d\n", n.tags[id], id)
+		values := make([]uint64, 0, len(n.tagValues[id]))
+		for value := range n.tagValues[id] {
+			values = append(values, value)
+		}
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+		for _, value := range values {
+			fmt.Fprintf(&b, "  enum %d %s\n", value, n.tagValues[id][value])
+		}
+		if defaultValue := tagIDs[id]; defaultValue != nil {
+			fmt.Fprintf(&b, "  default %s\n", n.tagValue(id, *defaultValue))
+		}
+		b.WriteString(";\n\n")
+	}
+
+	// Capabilities
+	for _, capability := range capabilities {
+		fmt.Fprintf(&b, "cap %s\n  id %d\n", n.capabilities[capability.ID], capability.ID)
+		if capability.Default {
+			b.WriteString("  default\n")
+		}
+		if err := n.decompileRuleStatements(&b, capability.Rules, "  "); err != nil {
+			return "", errors.Wrapf(err, "couldn't decompile capability %d", capability.ID)
+		}
+		b.WriteString(";\n\n")
+	}
+
+	// Rules
+	if err := n.decompileRuleStatements(&b, rules, ""); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// ruleNamer has the names to use for capabilities, tags, and tag values in decompiled source code.
+type ruleNamer struct {
+	capabilities map[uint64]string
+	tags         map[uint64]string
+	tagValues    map[uint64]map[uint64]string
+}
+
+// chooseRuleNames chooses a name for each id, using the provided names when they're valid names in
+// the rules language and aren't used for another id, and otherwise making a name from the id.
+func chooseRuleNames(ids []uint64, names map[uint64]string, prefix string) map[uint64]string {
+	chosen := make(map[uint64]string, len(ids))
+	used := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if name, ok := names[id]; ok && ruleNameParser.MatchString(name) && !used[name] {
+			chosen[id] = name
+			used[name] = true
+		}
+	}
+	for _, id := range ids {
+		if _, ok := chosen[id]; ok {
+			continue
+		}
+		name := fmt.Sprintf("%s_%d", prefix, id)
+		for used[name] {
+			name += "_"
+		}
+		chosen[id] = name
+		used[name] = true
+	}
+	return chosen
+}
+
+func newRuleNamer(names RuleNames, capabilityIDs, tagIDs []uint64) ruleNamer {
+	n := ruleNamer{
+		capabilities: chooseRuleNames(capabilityIDs, names.Capabilities, "cap"),
+		tags:         chooseRuleNames(tagIDs, names.Tags, "tag"),
+		tagValues:    make(map[uint64]map[uint64]string, len(tagIDs)),
+	}
+	for _, id := range tagIDs {
+		valueNames := make(map[uint64]string)
+		used := make(map[string]bool)
+		values := make([]uint64, 0, len(names.TagValues[id]))
+		for value := range names.TagValues[id] {
+			values = append(values, value)
+		}
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+		for _, value := range values {
+			name := names.TagValues[id][value]
+			if value > maxUint32 || !ruleNameParser.MatchString(name) || used[name] {
+				continue
+			}
+			valueNames[value] = name
+			used[name] = true
+		}
+		n.tagValues[id] = valueNames
+	}
+	return n
+}
+
+// tagValue returns the name of the tag value, or the value as a number if it has no name.
+func (n ruleNamer) tagValue(tagID, value uint64) string {
+	if name, ok := n.tagValues[tagID][value]; ok {
+		return name
+	}
+	return strconv.FormatUint(value, 10)
+}
+
+func ruleTagMatchNames() map[RuleType]string {
+	names := make(map[RuleType]string, len(ruleTagMatches))
+	for name, ruleType := range ruleTagMatches {
+		names[ruleType] = name
+	}
+	return names
+}
+
+func ruleNameOf(names map[string]uint64, value uint64) (string, bool) {
+	for _, name := range sortedRuleNames(names) {
+		if names[name] == value {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// decompileRuleStatements writes a rule statement for each action in the rules, with the matches
+// preceding the action.
+func (n ruleNamer) decompileRuleStatements(b *strings.Builder, rules []Rule, indent string) error {
+	matches := make([]string, 0)
+	for i, rule := range rules {
+		if !rule.Type.IsAction() {
+			match, err := n.decompileMatch(rule)
+			if err != nil {
+				return errors.Wrapf(err, "couldn't decompile rule %d", i+1)
+			}
+			switch {
+			case rule.Or && len(matches) == 0:
+				return errors.Errorf(
+					"couldn't decompile rule %d: a match combined by a logical OR with no preceding match "+
+						"can't be expressed in the rules language",
+					i+1,
+				)
+			case rule.Or:
+				match = "or " + match
+			case len(matches) > 0:
+				match = "and " + match
+			}
+			matches = append(matches, match)
+			continue
+		}
+
+		action, err := decompileAction(rule)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't decompile rule %d", i+1)
+		}
+		b.WriteString(indent + action)
+		if len(matches) == 0 {
+			b.WriteString(";\n")
+			continue
+		}
+		for _, match := range matches {
+			b.WriteString("\n" + indent + "  " + match)
+		}
+		b.WriteString("\n" + indent + ";\n")
+		matches = matches[:0]
+	}
+	if len(matches) > 0 {
+		return errors.New("rules end with matches which aren't followed by an action")
+	}
+	return nil
+}
+
+func derefRuleNumber(value *uint64) uint64 {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
+
+func derefRuleString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func decompileAction(rule Rule) (string, error) {
+	if rule.Flags != nil && *rule.Flags != 0 {
+		return "", errors.Errorf(
+			"%s rules with flags can't be expressed in the rules language", rule.Type,
+		)
+	}
+	switch rule.Type {
+	default:
+		return "", errors.Errorf("%s rules can't be expressed in the rules language", rule.Type)
+	case RuleActionDrop:
+		return "drop", nil
+	case RuleActionAccept:
+		return "accept", nil
+	case RuleActionBreak:
+		return "break", nil
+	case RuleActionTee:
+		return fmt.Sprintf("tee %d %s", derefRuleNumber(rule.Length), derefRuleString(rule.Address)), nil
+	case RuleActionWatch:
+		return fmt.Sprintf(
+			"watch %d %s", derefRuleNumber(rule.Length), derefRuleString(rule.Address),
+		), nil
+	case RuleActionRedirect:
+		return "redirect " + derefRuleString(rule.Address), nil
+	}
+}
+
+func derefRuleInteger(value *RuleInteger) uint64 {
+	if value == nil {
+		return 0
+	}
+	return value.Value
+}
+
+func decompileRange(start, end *RuleInteger) string {
+	if derefRuleInteger(start) == derefRuleInteger(end) {
+		return strconv.FormatUint(derefRuleInteger(start), 10)
+	}
+	return fmt.Sprintf("%d-%d", derefRuleInteger(start), derefRuleInteger(end))
+}
+
+func decompileNamedNumber(names map[string]uint64, value *uint64, hexDigits int) string {
+	if name, named := ruleNameOf(names, derefRuleNumber(value)); named {
+		return name
+	}
+	if hexDigits > 0 {
+		return fmt.Sprintf("0x%0*x", hexDigits, derefRuleNumber(value))
+	}
+	return strconv.FormatUint(derefRuleNumber(value), 10)
+}
+
+func decompileCharacteristics(mask *RuleInteger) (string, error) {
+	if mask == nil {
+		return "", errors.New("MATCH_CHARACTERISTICS rule has no mask")
+	}
+	names := make([]string, 0)
+	remaining := mask.Value
+	for _, name := range sortedRuleNames(ruleCharacteristics) {
+		bit := uint64(1) << ruleCharacteristics[name]
+		if remaining&bit != 0 {
+			names = append(names, name)
+			remaining &^= bit
+		}
+	}
+	if remaining != 0 {
+		return "", errors.Errorf(
+			"characteristics mask 0x%x has bits which can't be expressed in the rules language",
+			mask.Value,
+		)
+	}
+	return strings.Join(names, ","), nil
+}
+
+func (n ruleNamer) decompileMatch(rule Rule) (match string, err error) {
+	if name, isTagMatch := ruleTagMatchNames()[rule.Type]; isTagMatch {
+		id := derefRuleNumber(rule.ID)
+		match = fmt.Sprintf(
+			"%s %s %s", name, n.tags[id], n.tagValue(id, derefRuleNumber(rule.Value)),
+		)
+	} else if match, err = decompileNonTagMatch(rule); err != nil {
+		return "", err
+	}
+	if rule.Not {
+		return "not " + match, nil
+	}
+	return match, nil
+}
+
+func decompileNonTagMatch(rule Rule) (string, error) {
+	switch rule.Type {
+	default:
+		return "", errors.Errorf("%s rules can't be expressed in the rules language", rule.Type)
+	case RuleMatchSourceZerotierAddress:
+		return "ztsrc " + derefRuleString(rule.ZT), nil
+	case RuleMatchDestZerotierAddress:
+		return "ztdest " + derefRuleString(rule.ZT), nil
+	case RuleMatchVLANID:
+		return fmt.Sprintf("vlan %d", derefRuleNumber(rule.VLANID)), nil
+	case RuleMatchVLANPCP:
+		return fmt.Sprintf("vlanpcp %d", derefRuleNumber(rule.VLANPCP)), nil
+	case RuleMatchVLANDEI:
+		return fmt.Sprintf("vlandei %d", derefRuleNumber(rule.VLANDEI)), nil
+	case RuleMatchEtherType:
+		const etherTypeHexDigits = 4
+		return "ethertype " + decompileNamedNumber(
+			ruleEtherTypes, rule.EtherType, etherTypeHexDigits,
+		), nil
+	case RuleMatchMACSource:
+		return "macsrc " + derefRuleString(rule.MAC), nil
+	case RuleMatchMACDest:
+		return "macdest " + derefRuleString(rule.MAC), nil
+	case RuleMatchIPv4Source, RuleMatchIPv6Source:
+		return "ipsrc " + derefRuleString(rule.IP), nil
+	case RuleMatchIPv4Dest, RuleMatchIPv6Dest:
+		return "ipdest " + derefRuleString(rule.IP), nil
+	case RuleMatchIPTOS:
+		return fmt.Sprintf(
+			"iptos 0x%x %s", derefRuleInteger(rule.Mask), decompileRange(rule.Start, rule.End),
+		), nil
+	case RuleMatchIPProtocol:
+		return "ipprotocol " + decompileNamedNumber(ruleIPProtocols, rule.IPProtocol, 0), nil
+	case RuleMatchICMP:
+		code := "-"
+		if rule.ICMPCode != nil {
+			code = strconv.FormatUint(*rule.ICMPCode, 10)
+		}
+		return fmt.Sprintf("icmp %d %s", derefRuleNumber(rule.ICMPType), code), nil
+	case RuleMatchIPSourcePortRange:
+		return "sport " + decompileRange(rule.Start, rule.End), nil
+	case RuleMatchIPDestPortRange:
+		return "dport " + decompileRange(rule.Start, rule.End), nil
+	case RuleMatchCharacteristics:
+		characteristics, err := decompileCharacteristics(rule.Mask)
+		if err != nil {
+			return "", err
+		}
+		return "chr " + characteristics, nil
+	case RuleMatchFrameSizeRange:
+		return "framesize " + decompileRange(rule.Start, rule.End), nil
+	case RuleMatchRandom:
+		const precision = 8
+		const bitSize = 64
+		probability := float64(derefRuleNumber(rule.Probability)) / maxUint32
+		return "random " + strconv.FormatFloat(probability, 'g', precision, bitSize), nil
+	}
+}
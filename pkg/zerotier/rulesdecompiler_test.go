@@ -0,0 +1,171 @@
+package zerotier
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newTestRuleNumber(value uint64) *uint64 {
+	return &value
+}
+
+func TestDecompileRules(t *testing.T) {
+	testCases := []struct {
+		name  string
+		rules []Rule
+		tags  []Tag
+		names RuleNames
+		want  string
+	}{
+		{"empty", []Rule{}, nil, RuleNames{}, ""},
+		{
+			"matches",
+			[]Rule{
+				{Type: RuleMatchEtherType, Not: true, EtherType: newTestRuleNumber(0x0800)},
+				{Type: RuleMatchEtherType, Or: true, EtherType: newTestRuleNumber(0x86dd)},
+				{Type: RuleActionDrop},
+				{Type: RuleActionAccept},
+			},
+			nil,
+			RuleNames{},
+			"drop\n  not ethertype ipv4\n  or ethertype ipv6\n;\naccept;",
+		},
+		{
+			"unnamed tags",
+			[]Rule{
+				{Type: RuleMatchTagsEqual, ID: newTestRuleNumber(1000), Value: newTestRuleNumber(200)},
+				{Type: RuleActionAccept},
+			},
+			[]Tag{{ID: 1000, Default: newTestRuleNumber(100)}},
+			RuleNames{},
+			"tag tag_1000\n  id 1000\n  default 100\n;\n\naccept\n  teq tag_1000 200\n;",
+		},
+		{
+			"named tags",
+			[]Rule{
+				{Type: RuleMatchTagsEqual, ID: newTestRuleNumber(1000), Value: newTestRuleNumber(200)},
+				{Type: RuleActionAccept},
+			},
+			[]Tag{{ID: 1000, Default: newTestRuleNumber(100)}},
+			RuleNames{
+				Tags:      map[uint64]string{1000: "department"},
+				TagValues: map[uint64]map[uint64]string{1000: {100: "engineering", 200: "sales"}},
+			},
+			"tag department\n  id 1000\n  enum 100 engineering\n  enum 200 sales\n" +
+				"  default engineering\n;\n\naccept\n  teq department sales\n;",
+		},
+		{
+			"invalid and duplicate names",
+			[]Rule{{Type: RuleActionAccept}},
+			[]Tag{{ID: 1}, {ID: 2}, {ID: 3}},
+			RuleNames{Tags: map[uint64]string{1: "not a name", 2: "team", 3: "team"}},
+			"tag tag_1\n  id 1\n;\n\ntag team\n  id 2\n;\n\ntag tag_3\n  id 3\n;\n\naccept;",
+		},
+	}
+	for _, testCase := range testCases {
+		source, err := DecompileRules(testCase.rules, nil, testCase.tags, testCase.names)
+		if err != nil {
+			t.Errorf("%s: couldn't decompile: %s", testCase.name, err)
+			continue
+		}
+		if source != testCase.want {
+			t.Errorf("%s: got %q, want %q", testCase.name, source, testCase.want)
+		}
+	}
+}
+
+func TestDecompileRulesErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		rules []Rule
+	}{
+		{
+			"leading or",
+			[]Rule{
+				{Type: RuleMatchEtherType, Or: true, EtherType: newTestRuleNumber(0x0800)},
+				{Type: RuleActionAccept},
+			},
+		},
+		{"priority", []Rule{{Type: RuleActionPriority, QoSBucket: newTestRuleNumber(1)}}},
+		{"match without action", []Rule{{Type: RuleMatchEtherType, EtherType: newTestRuleNumber(1)}}},
+	}
+	for _, testCase := range testCases {
+		if _, err := DecompileRules(testCase.rules, nil, nil, RuleNames{}); err == nil {
+			t.Errorf("%s: decompiled without an error", testCase.name)
+		}
+	}
+}
+
+func TestRulesRoundTrip(t *testing.T) {
+	const source = `
+tag department
+  id 1000
+  enum 100 engineering
+  enum 200 sales
+  flag 3 remote
+  default engineering
+;
+
+cap superuser
+  id 1
+  default
+  accept teq department sales;
+;
+
+drop
+  not ethertype ipv4
+  and not ethertype arp
+  or ethertype ipv6
+;
+accept tor department remote;
+accept ipdest 10.0.0.0/8 and icmp 8 -;
+accept dport 80-443 and chr tcp_syn;
+tee 128 0123456789;
+accept;
+`
+	compiled, err := CompileRules(source)
+	if err != nil {
+		t.Fatalf("couldn't compile: %s", err)
+	}
+	decompiled, err := DecompileRules(
+		compiled.Rules, compiled.Capabilities, compiled.Tags, compiled.Names,
+	)
+	if err != nil {
+		t.Fatalf("couldn't decompile: %s", err)
+	}
+	recompiled, err := CompileRules(decompiled)
+	if err != nil {
+		t.Fatalf("couldn't compile decompiled rules: %s\n%s", err, decompiled)
+	}
+	if !reflect.DeepEqual(recompiled, compiled) {
+		t.Errorf(
+			"rules changed in a round trip:\ngot  %+v\nwant %+v\ndecompiled:\n%s",
+			recompiled, compiled, decompiled,
+		)
+	}
+
+	// Without names, the rules should still round-trip, but under generated names
+	decompiled, err = DecompileRules(
+		compiled.Rules, compiled.Capabilities, compiled.Tags, RuleNames{},
+	)
+	if err != nil {
+		t.Fatalf("couldn't decompile without names: %s", err)
+	}
+	recompiled, err = CompileRules(decompiled)
+	if err != nil {
+		t.Fatalf("couldn't compile rules decompiled without names: %s\n%s", err, decompiled)
+	}
+	if !reflect.DeepEqual(recompiled.Rules, compiled.Rules) ||
+		!reflect.DeepEqual(recompiled.Capabilities, compiled.Capabilities) ||
+		!reflect.DeepEqual(recompiled.Tags, compiled.Tags) {
+		t.Errorf("rules changed in a round trip without names:\n%s", decompiled)
+	}
+	wantNames := RuleNames{
+		Capabilities: map[uint64]string{1: "cap_1"},
+		Tags:         map[uint64]string{1000: "tag_1000"},
+		TagValues:    map[uint64]map[uint64]string{1000: {}},
+	}
+	if !reflect.DeepEqual(recompiled.Names, wantNames) {
+		t.Errorf("got names %+v, want %+v", recompiled.Names, wantNames)
+	}
+}
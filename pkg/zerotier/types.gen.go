@@ -11,6 +11,7 @@ const (
 type ControllerNetwork struct {
 	Capabilities      *[]map[string]interface{} `json:"capabilities,omitempty"`
 	CreationTime      *float32                  `json:"creationTime,omitempty"`
+	Dns               *DNS                      `json:"dns,omitempty"`
 	EnableBroadcast   *bool                     `json:"enableBroadcast,omitempty"`
 	Id                *string                   `json:"id,omitempty"`
 	IpAssignmentPools *[]IpAssignmentPool       `json:"ipAssignmentPools,omitempty"`
@@ -35,11 +36,13 @@ type ControllerNetworkMember struct {
 	ActiveBridge  *bool     `json:"activeBridge,omitempty"`
 	Address       *string   `json:"address,omitempty"`
 	Authorized    *bool     `json:"authorized,omitempty"`
+	Capabilities  *[]int    `json:"capabilities,omitempty"`
 	Id            *string   `json:"id,omitempty"`
 	Identity      *string   `json:"identity,omitempty"`
 	IpAssignments *[]string `json:"ipAssignments,omitempty"`
 	Nwid          *string   `json:"nwid,omitempty"`
 	Revision      *int      `json:"revision,omitempty"`
+	Tags          *[][]int  `json:"tags,omitempty"`
 	VMajor        *int      `json:"vMajor,omitempty"`
 	VMinor        *int      `json:"vMinor,omitempty"`
 	VProto        *int      `json:"vProto,omitempty"`
@@ -53,6 +56,12 @@ type ControllerStatus struct {
 	Controller *bool  `json:"controller,omitempty"`
 }
 
+// DNS defines model for DNS.
+type DNS struct {
+	Domain  *string   `json:"domain,omitempty"`
+	Servers *[]string `json:"servers,omitempty"`
+}
+
 // IpAssignmentPool defines model for IpAssignmentPool.
 type IpAssignmentPool struct {
 	IpRangeEnd   *string `json:"ipRangeEnd,omitempty"`